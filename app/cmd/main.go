@@ -1,10 +1,18 @@
 package main
 
 import (
+	"app/domain"
+	"app/gates/mailer"
+	"app/gates/proofs"
 	"app/gates/server"
 	storage "app/gates/storage/postgres"
+	"app/gates/verifier"
+	"app/gates/webhook"
 	"app/iternal/config"
 	"app/iternal/logger"
+	"app/iternal/pkg"
+	"app/iternal/ratelimit"
+	"context"
 	"fmt"
 	chi "github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
@@ -12,6 +20,7 @@ import (
 	goose "github.com/pressly/goose/v3"
 	"net/http"
 	"os"
+	"time"
 )
 
 func main() {
@@ -48,9 +57,127 @@ func main() {
 		panic(err)
 	}
 
+	//планировщик сезонов: закрывает закончившиеся сезоны и заводит новые
+	if cfg.Seasons.Enabled {
+		seasons := domain.NewSeasonService(db, log, cfg, pkg.NormalClock{})
+		go seasons.Run(context.Background(), cfg.Seasons.CheckInterval)
+	}
+
 	//Настройка роутера и запуск REST сервера
+	//воркер доставки вебхуков
+	if cfg.Webhooks.Enabled {
+		sender := webhook.NewSender(&http.Client{Timeout: cfg.Webhooks.Timeout}, pkg.NormalClock{})
+		webhooks := domain.NewWebhookService(db, sender, log, cfg, pkg.NormalClock{})
+		go webhooks.Run(context.Background(), cfg.Webhooks.PollInterval)
+	}
+
+	//relay событий из outbox
+	if cfg.Outbox.Enabled {
+		var publishers domain.MultiPublisher
+		for _, name := range cfg.Outbox.Publishers {
+			switch name {
+			case "log":
+				publishers = append(publishers, domain.LogPublisher{Log: log})
+			case "webhooks":
+				publishers = append(publishers, domain.NewWebhookService(db, nil, log, cfg, pkg.NormalClock{}))
+			case "http":
+				client := &http.Client{Timeout: cfg.Outbox.Timeout}
+				publishers = append(publishers, webhook.NewHTTPPublisher(client, cfg.Outbox.HTTPURL, cfg.Outbox.HTTPSecret, pkg.NormalClock{}))
+			default:
+				panic("unknown outbox publisher: " + name)
+			}
+		}
+		relay := domain.NewOutboxRelay(db, publishers, log, cfg, pkg.NormalClock{})
+		go relay.Run(context.Background(), cfg.Outbox.PollInterval)
+	}
+
+	//сгорание очков, кэш лидерборды подхватит изменения при периодическом resync
+	if cfg.PointsExpiration.Enabled {
+		expirer := domain.NewPointsExpirer(db, nil, log, cfg, pkg.NormalClock{})
+		go expirer.Run(context.Background(), cfg.PointsExpiration.CheckInterval)
+	}
+
+	//подведение итогов вызовов, кэш лидерборды подхватит изменения при периодическом resync
+	if cfg.Challenges.Enabled {
+		challenges := domain.NewChallengeService(db, nil, log, cfg, pkg.NormalClock{})
+		go challenges.Run(context.Background(), cfg.Challenges.CheckInterval)
+	}
+
+	//удаление истёкших Idempotency-Key
+	idempotency := domain.NewIdempotencyService(db, log, cfg, pkg.NormalClock{})
+	go idempotency.Run(context.Background(), cfg.Idempotency.CleanupInterval)
+
+	//кэш лидерборды в памяти, прогревается из бд до старта сервера
+	var board *domain.LeaderboardCache
+	if cfg.Leaderboard.Cache.Enabled {
+		board = domain.NewLeaderboardCache(db, log)
+		if _, err = board.Warm(context.Background()); err != nil {
+			panic(err)
+		}
+		go board.RunResync(context.Background(), cfg.Leaderboard.Cache.ResyncInterval)
+	}
+
+	if err = domain.ValidateAchievements(cfg); err != nil {
+		panic(err)
+	}
+	if err = domain.ValidateLevels(cfg.Levels); err != nil {
+		panic(err)
+	}
+	//уровни пользователей, заработавших опыт до появления уровней, до первого начисления
+	if _, err = domain.NewLevelService(db, log, cfg, pkg.NormalClock{}).Backfill(context.Background()); err != nil {
+		panic(err)
+	}
+	if err = domain.ValidateBonusEvents(cfg.BonusEvents); err != nil {
+		panic(err)
+	}
+	if err = domain.ValidateQuests(cfg); err != nil {
+		panic(err)
+	}
+
+	//проверяющие выполнение заданий через внешние сервисы
+	verifiers, err := verifier.FromConfig(cfg, &http.Client{Timeout: cfg.Verification.Timeout})
+	if err != nil {
+		panic(err)
+	}
+
+	//хранилище файлов доказательств для заявок на модерацию
+	var proofStore domain.ProofStore
+	switch cfg.Submissions.Storage {
+	case "local":
+		proofStore = proofs.NewLocal(cfg.Submissions.Dir)
+	case "s3":
+		s3 := cfg.Submissions.S3
+		proofStore = proofs.NewS3(&http.Client{Timeout: s3.Timeout}, s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey, pkg.NormalClock{})
+	default:
+		panic("unknown submissions storage: " + cfg.Submissions.Storage)
+	}
+
+	//ограничение частоты запросов, корзины хранятся в памяти
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limits := ratelimit.NewMemoryStore()
+		go limits.RunCleanup(context.Background(), time.Minute, time.Hour)
+		if limiter, err = ratelimit.New(limits, cfg.RateLimit); err != nil {
+			panic(err)
+		}
+	}
+
+	//письма пользователям: подтверждение email
+	var mail domain.Mailer
+	switch cfg.Email.Mailer {
+	case "log":
+		mail = mailer.NewLog(log)
+	case "file":
+		mail = mailer.NewFile(cfg.Email.Dir, cfg.Email.From)
+	case "smtp":
+		smtp := cfg.Email.SMTP
+		mail = mailer.NewSMTP(smtp.Host, smtp.Port, smtp.Username, smtp.Password, cfg.Email.From, smtp.Timeout)
+	default:
+		panic("unknown mailer: " + cfg.Email.Mailer)
+	}
+
 	router := chi.NewRouter()
-	_ = server.NewServer(db, cfg, log, router)
+	_ = server.NewServer(db, cfg, log, router, board, verifiers, proofStore, limiter, mail)
 	restServerAddr := cfg.Rest.Host + ":" + cfg.Rest.Port //получение адреса rest сервера из конфига
 	err = http.ListenAndServe(restServerAddr, router)
 	if err != nil {
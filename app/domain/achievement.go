@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// типы правил достижений
+const (
+	RuleCompletions = "completions" // задание Task выполнено не меньше Min раз
+	RuleScore       = "score"       // очков за всё время не меньше Min
+	RuleStreak      = "streak"      // самая длинная серия по заданию Task не меньше Min дней
+	RuleReferrals   = "referrals"   // приглашено не меньше Min пользователей
+	RuleRank        = "rank"        // место не ниже Top в лидерборде за период Period (day, week, month, all)
+)
+
+const EventAchievementEarned = "achievement.earned"
+
+// Achievement - полученное пользователем достижение
+type Achievement struct {
+	Key      string    `db:"achievement"`
+	Points   int       `db:"points"` // бонусные очки, начисленные за достижение
+	EarnedAt time.Time `db:"earned_at"`
+}
+
+// AchievementStats - всё, по чему проверяются правила достижений (кроме мест в лидерборде - они считаются отдельно)
+type AchievementStats struct {
+	Score       UserScore
+	Completions map[string]int // кол-во выполнений по заданиям (по журналу начислений)
+	Streaks     map[string]int // самая длинная серия по заданиям
+	Referrals   int
+	Earned      map[string]bool
+}
+
+type AchievementStore interface {
+	AchievementStats(ctx context.Context, id UserID) (AchievementStats, error)
+	// PeriodRank - место пользователя по очкам за window (nil - за всё время) и его очки за этот период
+	PeriodRank(ctx context.Context, id UserID, window *TimeWindow, ranking string) (int64, UserScore, error)
+	// GrantAchievement сохраняет достижение, если его ещё нет, и в той же транзакции начисляет award (если не nil)
+	// и пишет события. false - достижение уже было получено
+	GrantAchievement(ctx context.Context, id UserID, achievement Achievement, award *Award, events ...Event) (bool, error)
+	GetAchievements(ctx context.Context, id UserID) ([]Achievement, error)
+}
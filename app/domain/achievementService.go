@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// maxAchievementRounds - бонусные очки за достижение могут открыть следующее (по очкам), но не бесконечно
+const maxAchievementRounds = 5
+
+type AchievementService struct {
+	store AchievementStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewAchievementService(store AchievementStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *AchievementService {
+	return &AchievementService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// ValidateAchievements проверяет правила достижений из конфига
+func ValidateAchievements(cfg *config.Config) error {
+	for key, a := range cfg.Achievements {
+		rule := a.Rule
+		switch rule.Type {
+		case RuleCompletions, RuleStreak:
+			if _, ok := cfg.Rewards[rule.Task]; !ok {
+				return fmt.Errorf("achievement %q: unknown task %q", key, rule.Task)
+			}
+			if rule.Min <= 0 {
+				return fmt.Errorf("achievement %q: min must be positive", key)
+			}
+		case RuleScore, RuleReferrals:
+			if rule.Min <= 0 {
+				return fmt.Errorf("achievement %q: min must be positive", key)
+			}
+		case RuleRank:
+			if rule.Top <= 0 {
+				return fmt.Errorf("achievement %q: top must be positive", key)
+			}
+			switch rule.Period {
+			case PeriodAll, PeriodDay, PeriodWeek, PeriodMonth:
+			default:
+				return fmt.Errorf("achievement %q: period must be one of all, day, week, month", key)
+			}
+		default:
+			return fmt.Errorf("achievement %q: unknown rule type %q", key, rule.Type)
+		}
+		if a.Points < 0 {
+			return fmt.Errorf("achievement %q: points must not be negative", key)
+		}
+	}
+	return nil
+}
+
+// Evaluate выдаёт пользователю все достижения, условия которых выполнены, и возвращает начисленные за них бонусы
+func (s AchievementService) Evaluate(ctx context.Context, id UserID) ([]Award, error) {
+	const op = "AchievementService.Evaluate"
+	if len(s.cfg.Achievements) == 0 {
+		return nil, nil
+	}
+	var awards []Award
+	for round := 0; round < maxAchievementRounds; round++ {
+		stats, err := s.store.AchievementStats(ctx, id)
+		if err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return awards, err
+		}
+		granted := false
+		for _, key := range s.keys() {
+			if stats.Earned[key] {
+				continue
+			}
+			a := s.cfg.Achievements[key]
+			ok, err := s.satisfied(ctx, id, a.Rule, stats)
+			if err != nil {
+				s.log.Error(op, "user_id", id, "achievement", key, "error", err)
+				return awards, err
+			}
+			if !ok {
+				continue
+			}
+			award, earned, err := s.grant(ctx, id, key, a)
+			if err != nil {
+				return awards, err
+			}
+			if earned && award != nil {
+				awards = append(awards, *award)
+				granted = true
+			}
+		}
+		//новые очки могли открыть достижения по очкам и местам, иначе проверять ещё раз незачем
+		if !granted {
+			break
+		}
+	}
+	return awards, nil
+}
+
+// keys - достижения в постоянном порядке, чтобы выдача не зависела от порядка обхода map
+func (s AchievementService) keys() []string {
+	keys := make([]string, 0, len(s.cfg.Achievements))
+	for key := range s.cfg.Achievements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s AchievementService) satisfied(ctx context.Context, id UserID, rule config.AchievementRule, stats AchievementStats) (bool, error) {
+	switch rule.Type {
+	case RuleCompletions:
+		return stats.Completions[rule.Task] >= rule.Min, nil
+	case RuleScore:
+		return stats.Score >= UserScore(rule.Min), nil
+	case RuleStreak:
+		return stats.Streaks[rule.Task] >= rule.Min, nil
+	case RuleReferrals:
+		return stats.Referrals >= rule.Min, nil
+	case RuleRank:
+		loc, err := time.LoadLocation(s.cfg.Leaderboard.Timezone)
+		if err != nil {
+			return false, err
+		}
+		window, err := PeriodWindow(rule.Period, s.cl.Now(), loc, "", "")
+		if err != nil {
+			return false, err
+		}
+		rank, score, err := s.store.PeriodRank(ctx, id, window, rankingMode(s.cfg))
+		if err != nil {
+			return false, err
+		}
+		//без очков за период в топ не попадают, даже если у всех остальных тоже ноль
+		return score > 0 && rank <= int64(rule.Top), nil
+	}
+	return false, nil
+}
+
+func (s AchievementService) grant(ctx context.Context, id UserID, key string, a config.Achievement) (*Award, bool, error) {
+	const op = "AchievementService.grant"
+	now := s.cl.Now()
+	achievement := Achievement{Key: key, Points: a.Points, EarnedAt: now}
+	events := []Event{NewEvent(EventAchievementEarned, now, map[string]any{"user_id": id, "achievement": key, "points": a.Points})}
+	var award *Award
+	if a.Points > 0 {
+		award = &Award{UserID: id, Points: a.Points, Reason: "achievement:" + key, At: now}
+		events = append(events, PointsAdjustedEvent(*award))
+	}
+	earned, err := s.store.GrantAchievement(ctx, id, achievement, award, events...)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "achievement", key, "error", err)
+		return nil, false, err
+	}
+	if earned {
+		s.log.Info(op+": achievement earned", "user_id", id, "achievement", key)
+	}
+	return award, earned, nil
+}
+
+func (s AchievementService) List(ctx context.Context, id UserID) ([]Achievement, error) {
+	return s.store.GetAchievements(ctx, id)
+}
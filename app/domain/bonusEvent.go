@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type BonusEventID int64
+
+// правила для пересекающихся бонусных событий
+const (
+	BonusResolutionMax      = "max"      // действует наибольший множитель
+	BonusResolutionMultiply = "multiply" // множители перемножаются
+	BonusResolutionSum      = "sum"      // надбавки складываются: 2x и 3x дают 4x
+)
+
+// BonusEvent - акция с множителем очков за задания Tasks (пустой - за все задания) в окне [StartsAt, EndsAt)
+type BonusEvent struct {
+	ID         BonusEventID
+	Name       string
+	Multiplier float64
+	Tasks      []string
+	StartsAt   time.Time
+	EndsAt     time.Time
+	CreatedAt  time.Time
+}
+
+var ErrInvalidBonusEvent = errors.New("Invalid bonus event")
+var ErrBonusEventNotFound = errors.New("Bonus event not found")
+
+type BonusEventStore interface {
+	AddBonusEvent(ctx context.Context, event BonusEvent) (BonusEvent, error)
+	DeleteBonusEvent(ctx context.Context, id BonusEventID) error
+	// ListBonusEvents - все события, или только идущие в момент at, если at не nil
+	ListBonusEvents(ctx context.Context, at *time.Time) ([]BonusEvent, error)
+}
+
+// ValidateBonusEvents проверяет правило пересечения из конфига
+func ValidateBonusEvents(cfg config.BonusEvents) error {
+	switch cfg.Resolution {
+	case BonusResolutionMax, BonusResolutionMultiply, BonusResolutionSum:
+		return nil
+	}
+	return fmt.Errorf("bonus_events.resolution must be one of max, multiply, sum, got %q", cfg.Resolution)
+}
+
+func (e BonusEvent) Validate() error {
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidBonusEvent)
+	case e.Multiplier <= 0 || e.Multiplier > 100:
+		return fmt.Errorf("%w: multiplier must be between 0 and 100", ErrInvalidBonusEvent)
+	case e.StartsAt.IsZero() || !e.EndsAt.After(e.StartsAt):
+		return fmt.Errorf("%w: starts_at is required and ends_at must be after it", ErrInvalidBonusEvent)
+	}
+	return nil
+}
+
+// AppliesTo - событие действует на задание в момент at
+func (e BonusEvent) AppliesTo(task string, at time.Time) bool {
+	if at.Before(e.StartsAt) || !at.Before(e.EndsAt) {
+		return false
+	}
+	return len(e.Tasks) == 0 || slices.Contains(e.Tasks, task)
+}
+
+// EffectiveMultiplier - итоговый множитель задания по событиям, идущим в at, и id применённых событий
+func EffectiveMultiplier(events []BonusEvent, task string, at time.Time, resolution string) (float64, []BonusEventID) {
+	multiplier := 1.0
+	var applied []BonusEventID
+	for _, e := range events {
+		if !e.AppliesTo(task, at) {
+			continue
+		}
+		switch resolution {
+		case BonusResolutionMultiply:
+			multiplier *= e.Multiplier
+		case BonusResolutionSum:
+			multiplier += e.Multiplier - 1
+		default:
+			//при max учитываем только событие с наибольшим множителем
+			if len(applied) > 0 && e.Multiplier <= multiplier {
+				continue
+			}
+			multiplier = e.Multiplier
+			applied = applied[:0]
+		}
+		applied = append(applied, e.ID)
+	}
+	return max(multiplier, 0), applied
+}
+
+// multiplyPoints - очки с множителем, округление до целого
+func multiplyPoints(points int, multiplier float64) int {
+	if multiplier == 0 || multiplier == 1 {
+		return points
+	}
+	return int(math.Round(float64(points) * multiplier))
+}
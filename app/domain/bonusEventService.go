@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"log/slog"
+)
+
+type BonusEventService struct {
+	store BonusEventStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewBonusEventService(store BonusEventStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *BonusEventService {
+	return &BonusEventService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+func (s BonusEventService) Add(ctx context.Context, event BonusEvent) (BonusEvent, error) {
+	const op = "BonusEventService.Add"
+	if err := event.Validate(); err != nil {
+		return BonusEvent{}, err
+	}
+	for _, task := range event.Tasks {
+		if _, ok := s.cfg.Rewards[task]; !ok {
+			return BonusEvent{}, ErrNotExistingReward
+		}
+	}
+	event.CreatedAt = s.cl.Now()
+	created, err := s.store.AddBonusEvent(ctx, event)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return BonusEvent{}, err
+	}
+	s.log.Info(op+": bonus event created", "bonus_event_id", created.ID, "multiplier", created.Multiplier)
+	return created, nil
+}
+
+func (s BonusEventService) Delete(ctx context.Context, id BonusEventID) error {
+	const op = "BonusEventService.Delete"
+	if err := s.store.DeleteBonusEvent(ctx, id); err != nil {
+		s.log.Error(op, "bonus_event_id", id, "error", err)
+		return err
+	}
+	s.log.Info(op+": bonus event deleted", "bonus_event_id", id)
+	return nil
+}
+
+// List - все события, или только идущие сейчас, если active
+func (s BonusEventService) List(ctx context.Context, active bool) ([]BonusEvent, error) {
+	if !active {
+		return s.store.ListBonusEvents(ctx, nil)
+	}
+	now := s.cl.Now()
+	return s.store.ListBonusEvents(ctx, &now)
+}
+
+// Multipliers - итоговые множители заданий на текущий момент, задания без событий в ответ не попадают
+func (s BonusEventService) Multipliers(ctx context.Context, tasks ...string) (map[string]float64, map[string][]BonusEventID, error) {
+	now := s.cl.Now()
+	events, err := s.store.ListBonusEvents(ctx, &now)
+	if err != nil {
+		return nil, nil, err
+	}
+	multipliers := make(map[string]float64)
+	applied := make(map[string][]BonusEventID)
+	for _, task := range tasks {
+		if m, ids := EffectiveMultiplier(events, task, now, s.cfg.BonusEvents.Resolution); len(ids) > 0 {
+			multipliers[task] = m
+			applied[task] = ids
+		}
+	}
+	return multipliers, applied, nil
+}
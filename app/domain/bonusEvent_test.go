@@ -0,0 +1,67 @@
+package domain_test
+
+import (
+	"app/domain"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEffectiveMultiplier(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	event := func(id domain.BonusEventID, multiplier float64, tasks ...string) domain.BonusEvent {
+		return domain.BonusEvent{ID: id, Multiplier: multiplier, Tasks: tasks, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}
+	}
+	double := event(1, 2)
+	triple := event(2, 3, "morning_exercise")
+	half := event(3, 0.5)
+	quarter := event(4, 0.25, "morning_exercise")
+	otherTask := event(5, 10, "reading")
+	ended := event(6, 5)
+	ended.EndsAt = now
+	upcoming := event(7, 5)
+	upcoming.StartsAt = now.Add(time.Second)
+	tests := []struct {
+		name       string
+		resolution string
+		events     []domain.BonusEvent
+		want       float64
+		applied    []domain.BonusEventID
+	}{
+		{"no events", domain.BonusResolutionMax, nil, 1, nil},
+		{"max takes the largest", domain.BonusResolutionMax, []domain.BonusEvent{double, triple}, 3, []domain.BonusEventID{2}},
+		{"max keeps the earlier on a tie", domain.BonusResolutionMax, []domain.BonusEvent{double, event(8, 2)}, 2, []domain.BonusEventID{1}},
+		{"multiply", domain.BonusResolutionMultiply, []domain.BonusEvent{double, triple}, 6, []domain.BonusEventID{1, 2}},
+		{"sum adds bonuses", domain.BonusResolutionSum, []domain.BonusEvent{double, triple}, 4, []domain.BonusEventID{1, 2}},
+		//множители меньше 1 уменьшают очки
+		{"max of penalties", domain.BonusResolutionMax, []domain.BonusEvent{quarter, half}, 0.5, []domain.BonusEventID{3}},
+		{"max prefers bonus over penalty", domain.BonusResolutionMax, []domain.BonusEvent{half, double}, 2, []domain.BonusEventID{1}},
+		{"multiply with penalty", domain.BonusResolutionMultiply, []domain.BonusEvent{double, half}, 1, []domain.BonusEventID{1, 3}},
+		{"sum with penalty", domain.BonusResolutionSum, []domain.BonusEvent{triple, half}, 2.5, []domain.BonusEventID{2, 3}},
+		{"sum not below zero", domain.BonusResolutionSum, []domain.BonusEvent{half, quarter}, 0, []domain.BonusEventID{3, 4}},
+		//события другого задания и вне окна не действуют
+		{"other task event", domain.BonusResolutionMax, []domain.BonusEvent{otherTask, double}, 2, []domain.BonusEventID{1}},
+		{"outside the window", domain.BonusResolutionMultiply, []domain.BonusEvent{ended, upcoming, triple}, 3, []domain.BonusEventID{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, applied := domain.EffectiveMultiplier(tt.events, "morning_exercise", now, tt.resolution)
+			if got != tt.want || !slices.Equal(applied, tt.applied) {
+				t.Fatalf("EffectiveMultiplier() = %v, %v, want %v, %v", got, applied, tt.want, tt.applied)
+			}
+		})
+	}
+}
+
+func TestEffectiveMultiplierGlobalEventForAnyTask(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	events := []domain.BonusEvent{
+		{ID: 1, Multiplier: 2, StartsAt: now, EndsAt: now.Add(time.Hour)},
+		{ID: 2, Multiplier: 3, Tasks: []string{"reading"}, StartsAt: now, EndsAt: now.Add(time.Hour)},
+	}
+	for task, want := range map[string]float64{"reading": 6, "morning_exercise": 2} {
+		if got, _ := domain.EffectiveMultiplier(events, task, now, domain.BonusResolutionMultiply); got != want {
+			t.Errorf("EffectiveMultiplier(%q) = %v, want %v", task, got, want)
+		}
+	}
+}
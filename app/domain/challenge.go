@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type ChallengeID int64
+
+const (
+	ChallengePending   = "pending"   // ждёт ответа соперника, ставка вызывающего уже списана
+	ChallengeActive    = "active"    // принят, ставки обоих списаны, выполнения считаются до ends_at
+	ChallengeDeclined  = "declined"  // отклонён соперником
+	ChallengeCancelled = "cancelled" // отменён вызывающим до принятия или администратором
+	ChallengeExpired   = "expired"   // соперник не ответил до ends_at
+	ChallengeCompleted = "completed" // подведён итог, WinnerID nil - ничья
+)
+
+const (
+	EventChallengeCreated  = "challenge.created"
+	EventChallengeAccepted = "challenge.accepted"
+	EventChallengeClosed   = "challenge.closed" // отклонён, отменён или истёк без ответа
+	EventChallengeResolved = "challenge.resolved"
+)
+
+// Challenge - вызов: кто больше раз выполнит задание с принятия до EndsAt, тот забирает обе ставки
+type Challenge struct {
+	ID              ChallengeID `db:"id"`
+	ChallengerID    UserID      `db:"challenger_id"`
+	OpponentID      UserID      `db:"opponent_id"`
+	Task            string      `db:"task"`
+	Stake           int         `db:"stake"`
+	Status          string      `db:"status"`
+	EndsAt          time.Time   `db:"ends_at"`
+	AcceptedAt      *time.Time  `db:"accepted_at"`
+	WinnerID        *UserID     `db:"winner_id"`
+	ChallengerCount int         `db:"challenger_count"` // выполнения задания за время вызова, считаются при подведении итога
+	OpponentCount   int         `db:"opponent_count"`
+	CreatedAt       time.Time   `db:"created_at"`
+	ClosedAt        *time.Time  `db:"closed_at"`
+}
+
+// Open - вызов ещё не закрыт: ждёт ответа или идёт
+func (c Challenge) Open() bool {
+	return c.Status == ChallengePending || c.Status == ChallengeActive
+}
+
+var ErrChallengesDisabled = errors.New("Challenges are disabled")
+var ErrInvalidChallenge = errors.New("Invalid challenge")
+var ErrChallengeNotFound = errors.New("Challenge not found")
+var ErrChallengeClosed = errors.New("Challenge is no longer open")
+var ErrNotChallengeParticipant = errors.New("Only the challenged user can do this")
+
+type ChallengeStore interface {
+	// CreateChallenge создаёт вызов и списывает ставку, которую create вернёт по созданному вызову,
+	// всё одной транзакцией (ErrInsufficientPoints, ErrUserNotFound для несуществующего соперника)
+	CreateChallenge(ctx context.Context, c Challenge, create func(c Challenge) (Award, []Event)) (Challenge, error)
+	GetChallenge(ctx context.Context, id ChallengeID) (Challenge, error)
+	// ListChallenges - вызовы пользователя (с любой стороны) от новых к старым, status пустой - любые
+	ListChallenges(ctx context.Context, id UserID, status string, page int, size int) ([]Challenge, error)
+	// DueChallenges - не больше limit открытых вызовов со сроком не позже at, от старых сроков к новым
+	DueChallenges(ctx context.Context, at time.Time, limit int) ([]Challenge, error)
+	// CountCompletions - сколько раз каждый из users выполнил задание в [from, to) по журналу начислений
+	CountCompletions(ctx context.Context, task string, users []UserID, from time.Time, to time.Time) (map[UserID]int, error)
+	// UpdateChallenge блокирует вызов, update по текущему состоянию возвращает новое, начисления и события,
+	// всё пишется одной транзакцией. Ошибка update откатывает транзакцию и возвращается как есть
+	UpdateChallenge(ctx context.Context, id ChallengeID, update func(c Challenge) (Challenge, []Award, []Event, error)) (Challenge, error)
+}
+
+func challengeEvent(eventType string, c Challenge, at time.Time) Event {
+	data := map[string]any{
+		"challenge_id":  c.ID,
+		"challenger_id": c.ChallengerID,
+		"opponent_id":   c.OpponentID,
+		"task":          c.Task,
+		"stake":         c.Stake,
+		"status":        c.Status,
+	}
+	if c.Status == ChallengeCompleted {
+		data["winner_id"] = c.WinnerID
+		data["challenger_count"] = c.ChallengerCount
+		data["opponent_count"] = c.OpponentCount
+	}
+	return NewEvent(eventType, at, data)
+}
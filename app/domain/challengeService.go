@@ -0,0 +1,333 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type ChallengeService struct {
+	store ChallengeStore
+	users *UserService // nil - кэш лидерборды догонит периодический resync
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewChallengeService(store ChallengeStore, users *UserService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *ChallengeService {
+	return &ChallengeService{
+		store: store,
+		users: users,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Create - вызов opponent на задание task до endsAt, ставка вызывающего списывается сразу и хранится до итога
+func (s ChallengeService) Create(ctx context.Context, challenger UserID, opponent UserID, task string, stake int, endsAt time.Time) (Challenge, error) {
+	const op = "ChallengeService.Create"
+	cfg := s.cfg.Challenges
+	if !cfg.Enabled {
+		return Challenge{}, ErrChallengesDisabled
+	}
+	now := s.cl.Now()
+	_, known := s.cfg.Rewards[task]
+	switch {
+	case challenger == opponent:
+		return Challenge{}, fmt.Errorf("%w: can't challenge yourself", ErrInvalidChallenge)
+	case !known || task == RewardInviting || task == RewardInvited:
+		return Challenge{}, fmt.Errorf("%w: unknown task %q", ErrInvalidChallenge, task)
+	case stake < max(cfg.MinStake, 1):
+		return Challenge{}, fmt.Errorf("%w: stake must be at least %d", ErrInvalidChallenge, max(cfg.MinStake, 1))
+	case cfg.MaxStake > 0 && stake > cfg.MaxStake:
+		return Challenge{}, fmt.Errorf("%w: stake must be at most %d", ErrInvalidChallenge, cfg.MaxStake)
+	case !endsAt.After(now):
+		return Challenge{}, fmt.Errorf("%w: ends_at must be in the future", ErrInvalidChallenge)
+	case endsAt.Sub(now) > cfg.MaxDuration:
+		return Challenge{}, fmt.Errorf("%w: challenge can last at most %s", ErrInvalidChallenge, cfg.MaxDuration)
+	}
+	var debit Award
+	created, err := s.store.CreateChallenge(ctx, Challenge{
+		ChallengerID: challenger,
+		OpponentID:   opponent,
+		Task:         task,
+		Stake:        stake,
+		Status:       ChallengePending,
+		EndsAt:       endsAt,
+		CreatedAt:    now,
+	}, func(c Challenge) (Award, []Event) {
+		debit = Award{UserID: challenger, Points: -stake, Reason: fmt.Sprintf("challenge_stake:%d", c.ID), At: now, BalanceOnly: true}
+		return debit, []Event{challengeEvent(EventChallengeCreated, c, now), PointsAdjustedEvent(debit)}
+	})
+	if err != nil {
+		s.log.Error(op, "user_id", challenger, "opponent_id", opponent, "error", err)
+		return Challenge{}, err
+	}
+	s.pointsChanged(ctx, debit)
+	s.log.Info(op+": challenge created", "challenge_id", created.ID, "user_id", challenger, "opponent_id", opponent)
+	return created, nil
+}
+
+func (s ChallengeService) Get(ctx context.Context, id ChallengeID) (Challenge, error) {
+	return s.store.GetChallenge(ctx, id)
+}
+
+// List - вызовы пользователя с любой стороны, status пустой - любые
+func (s ChallengeService) List(ctx context.Context, id UserID, status string, page int, size int) ([]Challenge, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidChallenge, MaxLeaderboardSize)
+	}
+	switch status {
+	case "", ChallengePending, ChallengeActive, ChallengeDeclined, ChallengeCancelled, ChallengeExpired, ChallengeCompleted:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidChallenge, status)
+	}
+	return s.store.ListChallenges(ctx, id, status, page, size)
+}
+
+// Accept - соперник принимает вызов, его ставка списывается, выполнения считаются с этого момента
+func (s ChallengeService) Accept(ctx context.Context, id ChallengeID, userID UserID) (Challenge, error) {
+	const op = "ChallengeService.Accept"
+	now := s.cl.Now()
+	var debit Award
+	c, err := s.store.UpdateChallenge(ctx, id, func(c Challenge) (Challenge, []Award, []Event, error) {
+		if c.OpponentID != userID {
+			return c, nil, nil, fmt.Errorf("%w: only the challenged user can accept", ErrNotChallengeParticipant)
+		}
+		//истёкший, но ещё не закрытый планировщиком вызов принять уже нельзя
+		if c.Status != ChallengePending || !now.Before(c.EndsAt) {
+			return c, nil, nil, ErrChallengeClosed
+		}
+		c.Status = ChallengeActive
+		c.AcceptedAt = &now
+		debit = Award{UserID: userID, Points: -c.Stake, Reason: fmt.Sprintf("challenge_stake:%d", c.ID), At: now, BalanceOnly: true}
+		return c, []Award{debit}, []Event{challengeEvent(EventChallengeAccepted, c, now), PointsAdjustedEvent(debit)}, nil
+	})
+	if err != nil {
+		s.log.Error(op, "challenge_id", id, "user_id", userID, "error", err)
+		return Challenge{}, err
+	}
+	s.pointsChanged(ctx, debit)
+	s.log.Info(op+": challenge accepted", "challenge_id", id)
+	return c, nil
+}
+
+// Decline - соперник отказывается, ставка возвращается вызывающему
+func (s ChallengeService) Decline(ctx context.Context, id ChallengeID, userID UserID) (Challenge, error) {
+	return s.close(ctx, id, ChallengeDeclined, func(c Challenge) error {
+		if c.OpponentID != userID {
+			return fmt.Errorf("%w: only the challenged user can decline", ErrNotChallengeParticipant)
+		}
+		if c.Status != ChallengePending {
+			return ErrChallengeClosed
+		}
+		return nil
+	})
+}
+
+// Cancel - вызывающий отзывает вызов, пока соперник не принял его
+func (s ChallengeService) Cancel(ctx context.Context, id ChallengeID, userID UserID) (Challenge, error) {
+	return s.close(ctx, id, ChallengeCancelled, func(c Challenge) error {
+		if c.ChallengerID != userID {
+			return fmt.Errorf("%w: only the challenger can cancel", ErrNotChallengeParticipant)
+		}
+		if c.Status != ChallengePending {
+			return ErrChallengeClosed
+		}
+		return nil
+	})
+}
+
+// AdminCancel - отмена администратором любого открытого вызова, ставки возвращаются обоим
+func (s ChallengeService) AdminCancel(ctx context.Context, id ChallengeID) (Challenge, error) {
+	return s.close(ctx, id, ChallengeCancelled, func(c Challenge) error {
+		if !c.Open() {
+			return ErrChallengeClosed
+		}
+		return nil
+	})
+}
+
+// CancelAll отменяет все открытые вызовы пользователя с возвратом ставок (при удалении аккаунта)
+func (s ChallengeService) CancelAll(ctx context.Context, id UserID) error {
+	for _, status := range []string{ChallengePending, ChallengeActive} {
+		for {
+			open, err := s.store.ListChallenges(ctx, id, status, 1, MaxLeaderboardSize)
+			if err != nil {
+				return err
+			}
+			for _, c := range open {
+				if _, err := s.AdminCancel(ctx, c.ID); err != nil && !errors.Is(err, ErrChallengeClosed) {
+					return err
+				}
+			}
+			if len(open) < MaxLeaderboardSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// close закрывает вызов без итога со статусом status, если allowed разрешит, и возвращает внесённые ставки
+func (s ChallengeService) close(ctx context.Context, id ChallengeID, status string, allowed func(c Challenge) error) (Challenge, error) {
+	const op = "ChallengeService.close"
+	now := s.cl.Now()
+	var refunds []Award
+	c, err := s.store.UpdateChallenge(ctx, id, func(c Challenge) (Challenge, []Award, []Event, error) {
+		if err := allowed(c); err != nil {
+			return c, nil, nil, err
+		}
+		refunds = stakeRefunds(c, now)
+		c.Status = status
+		c.ClosedAt = &now
+		events := []Event{challengeEvent(EventChallengeClosed, c, now)}
+		for _, refund := range refunds {
+			events = append(events, PointsAdjustedEvent(refund))
+		}
+		return c, refunds, events, nil
+	})
+	if err != nil {
+		s.log.Error(op, "challenge_id", id, "status", status, "error", err)
+		return Challenge{}, err
+	}
+	s.pointsChanged(ctx, refunds...)
+	s.log.Info(op+": challenge closed", "challenge_id", id, "status", status)
+	return c, nil
+}
+
+// stakeRefunds - возврат ставок: вызывающий платит при создании, соперник - при принятии.
+// Ставка возвращается с датами сгорания списанных очков
+func stakeRefunds(c Challenge, at time.Time) []Award {
+	reason := fmt.Sprintf("challenge_refund:%d", c.ID)
+	stake := fmt.Sprintf("challenge_stake:%d", c.ID)
+	refunds := []Award{{UserID: c.ChallengerID, Points: c.Stake, Reason: reason, At: at, BalanceOnly: true,
+		Restores: &DebitRef{UserID: c.ChallengerID, Reason: stake}}}
+	if c.AcceptedAt != nil {
+		refunds = append(refunds, Award{UserID: c.OpponentID, Points: c.Stake, Reason: reason, At: at, BalanceOnly: true,
+			Restores: &DebitRef{UserID: c.OpponentID, Reason: stake}})
+	}
+	return refunds
+}
+
+// Run подводит итоги вызовов раз в interval, пока есть полные пачки - без паузы, до отмены ctx.
+// Сроки сравниваются с часами сервиса, поэтому с pkg.StubClock время можно перематывать и вызывать Resolve напрямую
+func (s ChallengeService) Run(ctx context.Context, interval time.Duration) {
+	const op = "ChallengeService.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		resolved, err := s.Resolve(ctx)
+		if err != nil {
+			s.log.Error(op, "error", err)
+		}
+		if err == nil && resolved == s.cfg.Challenges.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resolve закрывает вызовы с наступившим сроком: непринятые истекают с возвратом ставки, по принятым
+// подводится итог - больше выполнений забирает обе ставки, при равенстве ставки возвращаются.
+// Ошибка по одному вызову логируется и не мешает остальным, вызов повторится в следующий проход.
+// Возвращает сколько вызовов обработано
+func (s ChallengeService) Resolve(ctx context.Context) (int, error) {
+	const op = "ChallengeService.Resolve"
+	now := s.cl.Now()
+	due, err := s.store.DueChallenges(ctx, now, s.cfg.Challenges.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, c := range due {
+		if c.Status == ChallengePending {
+			_, err = s.close(ctx, c.ID, ChallengeExpired, func(c Challenge) error {
+				if c.Status != ChallengePending {
+					return ErrChallengeClosed
+				}
+				return nil
+			})
+		} else {
+			err = s.complete(ctx, c, now)
+		}
+		//вызов успели закрыть параллельно (администратор или другой экземпляр планировщика)
+		if err != nil && !errors.Is(err, ErrChallengeClosed) {
+			s.log.Error(op, "challenge_id", c.ID, "error", err)
+			failed++
+		}
+	}
+	resolved := len(due) - failed
+	if resolved > 0 {
+		s.log.Info(op+": challenges resolved", "count", resolved)
+	}
+	if failed > 0 {
+		//неполная пачка: Run не будет крутить упавшие вызовы без паузы
+		return resolved, fmt.Errorf("%d of %d due challenges failed to resolve", failed, len(due))
+	}
+	return resolved, nil
+}
+
+// complete подводит итог принятого вызова по выполнениям задания с принятия до срока
+func (s ChallengeService) complete(ctx context.Context, c Challenge, now time.Time) error {
+	const op = "ChallengeService.complete"
+	counts, err := s.store.CountCompletions(ctx, c.Task, []UserID{c.ChallengerID, c.OpponentID}, *c.AcceptedAt, c.EndsAt)
+	if err != nil {
+		return err
+	}
+	var awards []Award
+	c, err = s.store.UpdateChallenge(ctx, c.ID, func(c Challenge) (Challenge, []Award, []Event, error) {
+		if c.Status != ChallengeActive {
+			return c, nil, nil, ErrChallengeClosed
+		}
+		c.Status = ChallengeCompleted
+		c.ClosedAt = &now
+		c.ChallengerCount = counts[c.ChallengerID]
+		c.OpponentCount = counts[c.OpponentID]
+		switch {
+		case c.ChallengerCount > c.OpponentCount:
+			c.WinnerID = &c.ChallengerID
+		case c.OpponentCount > c.ChallengerCount:
+			c.WinnerID = &c.OpponentID
+		}
+		//выигрыш - новые заработанные очки, их срок сгорания считается от подведения итогов
+		if c.WinnerID != nil {
+			awards = []Award{{UserID: *c.WinnerID, Points: 2 * c.Stake, Reason: fmt.Sprintf("challenge_win:%d", c.ID), At: now, BalanceOnly: true}}
+		} else {
+			awards = stakeRefunds(c, now)
+		}
+		events := []Event{challengeEvent(EventChallengeResolved, c, now)}
+		for _, award := range awards {
+			events = append(events, PointsAdjustedEvent(award))
+		}
+		return c, awards, events, nil
+	})
+	if err != nil {
+		s.log.Error(op, "challenge_id", c.ID, "error", err)
+		return err
+	}
+	s.pointsChanged(ctx, awards...)
+	s.log.Info(op+": challenge completed", "challenge_id", c.ID, "winner_id", c.WinnerID)
+	return nil
+}
+
+func (s ChallengeService) pointsChanged(ctx context.Context, awards ...Award) {
+	if s.users != nil && len(awards) > 0 {
+		s.users.pointsChanged(ctx, awards...)
+	}
+}
@@ -0,0 +1,253 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memChallenges - вызовы и начисления по ним в памяти, выполнения заданий задаются тестом
+type memChallenges struct {
+	domain.ChallengeStore
+	challenges  map[domain.ChallengeID]domain.Challenge
+	awards      []domain.Award
+	completions map[domain.UserID]int
+	broken      map[domain.ChallengeID]bool // UpdateChallenge по этим вызовам падает
+}
+
+func newMemChallenges() *memChallenges {
+	return &memChallenges{challenges: map[domain.ChallengeID]domain.Challenge{}, completions: map[domain.UserID]int{},
+		broken: map[domain.ChallengeID]bool{}}
+}
+
+func (m *memChallenges) CreateChallenge(ctx context.Context, c domain.Challenge, create func(c domain.Challenge) (domain.Award, []domain.Event)) (domain.Challenge, error) {
+	c.ID = domain.ChallengeID(len(m.challenges) + 1)
+	award, _ := create(c)
+	m.challenges[c.ID] = c
+	m.awards = append(m.awards, award)
+	return c, nil
+}
+
+func (m *memChallenges) DueChallenges(ctx context.Context, at time.Time, limit int) ([]domain.Challenge, error) {
+	var due []domain.Challenge
+	for id := domain.ChallengeID(1); int(id) <= len(m.challenges) && len(due) < limit; id++ {
+		if c := m.challenges[id]; c.Open() && !c.EndsAt.After(at) {
+			due = append(due, c)
+		}
+	}
+	return due, nil
+}
+
+func (m *memChallenges) CountCompletions(ctx context.Context, task string, users []domain.UserID, from time.Time, to time.Time) (map[domain.UserID]int, error) {
+	return m.completions, nil
+}
+
+func (m *memChallenges) UpdateChallenge(ctx context.Context, id domain.ChallengeID, update func(c domain.Challenge) (domain.Challenge, []domain.Award, []domain.Event, error)) (domain.Challenge, error) {
+	if m.broken[id] {
+		return domain.Challenge{}, errors.New("connection reset")
+	}
+	c, ok := m.challenges[id]
+	if !ok {
+		return domain.Challenge{}, domain.ErrChallengeNotFound
+	}
+	c, awards, _, err := update(c)
+	if err != nil {
+		return domain.Challenge{}, err
+	}
+	m.challenges[id] = c
+	m.awards = append(m.awards, awards...)
+	return c, nil
+}
+
+// balance - сумма начислений пользователю по вызовам
+func (m *memChallenges) balance(id domain.UserID) int {
+	total := 0
+	for _, award := range m.awards {
+		if award.UserID == id {
+			total += award.Points
+		}
+	}
+	return total
+}
+
+const (
+	challenger domain.UserID = 1
+	opponent   domain.UserID = 2
+)
+
+func newChallengeService() (*domain.ChallengeService, *memChallenges, *pkg.StubClock) {
+	cfg := &config.Config{
+		Rewards:    map[string]int{"morning_exercise": 10},
+		Challenges: config.Challenges{Enabled: true, MinStake: 1, MaxDuration: 72 * time.Hour, BatchSize: 10},
+	}
+	store := newMemChallenges()
+	cl := &pkg.StubClock{Time: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	return domain.NewChallengeService(store, nil, discardLog(), cfg, cl), store, cl
+}
+
+// accepted - вызов со ставкой 10 на сутки, принятый соперником
+func accepted(t *testing.T, s *domain.ChallengeService, cl *pkg.StubClock) domain.Challenge {
+	ctx := context.Background()
+	c, err := s.Create(ctx, challenger, opponent, "morning_exercise", 10, cl.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = s.Accept(ctx, c.ID, opponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestChallengeAccept(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c, err := s.Create(ctx, challenger, opponent, "morning_exercise", 10, cl.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Accept(ctx, c.ID, challenger); !errors.Is(err, domain.ErrNotChallengeParticipant) {
+		t.Fatalf("challenger accepted own challenge, err = %v", err)
+	}
+	c, err = s.Accept(ctx, c.ID, opponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Status != domain.ChallengeActive || c.AcceptedAt == nil || !c.AcceptedAt.Equal(cl.Now()) {
+		t.Fatalf("challenge is %s accepted at %v, want active accepted now", c.Status, c.AcceptedAt)
+	}
+	if store.balance(challenger) != -10 || store.balance(opponent) != -10 {
+		t.Fatalf("stakes: challenger %d, opponent %d, want -10 each", store.balance(challenger), store.balance(opponent))
+	}
+	if _, err := s.Accept(ctx, c.ID, opponent); !errors.Is(err, domain.ErrChallengeClosed) {
+		t.Fatalf("second accept err = %v, want ErrChallengeClosed", err)
+	}
+}
+
+func TestChallengeAcceptAfterDeadline(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c, err := s.Create(ctx, challenger, opponent, "morning_exercise", 10, cl.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//срок прошёл, планировщик ещё не закрыл вызов
+	cl.Time = cl.Time.Add(time.Hour)
+	if _, err := s.Accept(ctx, c.ID, opponent); !errors.Is(err, domain.ErrChallengeClosed) {
+		t.Fatalf("accept after deadline err = %v, want ErrChallengeClosed", err)
+	}
+	if store.balance(opponent) != 0 {
+		t.Fatalf("opponent paid %d for a challenge they couldn't accept", -store.balance(opponent))
+	}
+}
+
+func TestChallengeDecline(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c, err := s.Create(ctx, challenger, opponent, "morning_exercise", 10, cl.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = s.Decline(ctx, c.ID, opponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Status != domain.ChallengeDeclined || store.balance(challenger) != 0 {
+		t.Fatalf("challenge is %s, challenger balance %d, want declined with stake refunded", c.Status, store.balance(challenger))
+	}
+	//ставка возвращается с датами сгорания списанных очков
+	refund := store.awards[len(store.awards)-1]
+	if refund.Restores == nil || *refund.Restores != (domain.DebitRef{UserID: challenger, Reason: fmt.Sprintf("challenge_stake:%d", c.ID)}) {
+		t.Fatalf("refund restores %+v, want the challenger's stake", refund.Restores)
+	}
+	if _, err := s.Accept(ctx, c.ID, opponent); !errors.Is(err, domain.ErrChallengeClosed) {
+		t.Fatalf("accept after decline err = %v, want ErrChallengeClosed", err)
+	}
+}
+
+func TestChallengeExpire(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c, err := s.Create(ctx, challenger, opponent, "morning_exercise", 10, cl.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved, err := s.Resolve(ctx); err != nil || resolved != 0 {
+		t.Fatalf("Resolve() before deadline = %d, %v", resolved, err)
+	}
+	cl.Time = cl.Time.Add(time.Hour)
+	if resolved, err := s.Resolve(ctx); err != nil || resolved != 1 {
+		t.Fatalf("Resolve() after deadline = %d, %v", resolved, err)
+	}
+	if got := store.challenges[c.ID]; got.Status != domain.ChallengeExpired || store.balance(challenger) != 0 {
+		t.Fatalf("challenge is %s, challenger balance %d, want expired with stake refunded", got.Status, store.balance(challenger))
+	}
+}
+
+func TestChallengeWin(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c := accepted(t, s, cl)
+	store.completions = map[domain.UserID]int{challenger: 1, opponent: 3}
+	cl.Time = c.EndsAt
+	if _, err := s.Resolve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := store.challenges[c.ID]
+	if got.Status != domain.ChallengeCompleted || got.WinnerID == nil || *got.WinnerID != opponent {
+		t.Fatalf("challenge is %s with winner %v, want completed won by opponent", got.Status, got.WinnerID)
+	}
+	if got.ChallengerCount != 1 || got.OpponentCount != 3 {
+		t.Fatalf("counts %d:%d, want 1:3", got.ChallengerCount, got.OpponentCount)
+	}
+	if store.balance(challenger) != -10 || store.balance(opponent) != 10 {
+		t.Fatalf("balances: challenger %d, opponent %d, want -10 and +10", store.balance(challenger), store.balance(opponent))
+	}
+}
+
+func TestChallengeDraw(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	c := accepted(t, s, cl)
+	store.completions = map[domain.UserID]int{challenger: 2, opponent: 2}
+	cl.Time = c.EndsAt
+	if _, err := s.Resolve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := store.challenges[c.ID]
+	if got.Status != domain.ChallengeCompleted || got.WinnerID != nil {
+		t.Fatalf("challenge is %s with winner %v, want a draw", got.Status, got.WinnerID)
+	}
+	if store.balance(challenger) != 0 || store.balance(opponent) != 0 {
+		t.Fatalf("balances: challenger %d, opponent %d, want both stakes refunded", store.balance(challenger), store.balance(opponent))
+	}
+}
+
+func TestChallengeResolveSkipsFailedChallenge(t *testing.T) {
+	ctx := context.Background()
+	s, store, cl := newChallengeService()
+	first := accepted(t, s, cl)
+	second := accepted(t, s, cl)
+	store.broken[first.ID] = true
+	cl.Time = second.EndsAt
+	resolved, err := s.Resolve(ctx)
+	if err == nil || resolved != 1 {
+		t.Fatalf("Resolve() = %d, %v, want 1 resolved and an error for the failed one", resolved, err)
+	}
+	if got := store.challenges[second.ID]; got.Status != domain.ChallengeCompleted {
+		t.Fatalf("challenge after the failed one is %s, want completed", got.Status)
+	}
+	//упавший вызов подводится в следующий проход
+	delete(store.broken, first.ID)
+	if resolved, err := s.Resolve(ctx); err != nil || resolved != 1 {
+		t.Fatalf("retry Resolve() = %d, %v", resolved, err)
+	}
+	if got := store.challenges[first.ID]; got.Status != domain.ChallengeCompleted {
+		t.Fatalf("failed challenge is %s after retry, want completed", got.Status)
+	}
+}
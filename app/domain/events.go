@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	EventUserRegistered  = "user.registered"
+	EventTaskCompleted   = "task.completed"
+	EventReferralApplied = "referral.applied"
+	EventPointsAdjusted  = "points.adjusted"
+)
+
+// Event - доменное событие, ID уникален и используется получателями для дедупликации
+type Event struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Data       map[string]any `json:"data"`
+}
+
+// EventPublisher - получатель доменных событий (вебхуки и т.д.)
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+func NewEvent(eventType string, at time.Time, data map[string]any) Event {
+	return Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: at,
+		Data:       data,
+	}
+}
+
+func UserRegisteredEvent(user User) Event {
+	return NewEvent(EventUserRegistered, user.Registered, map[string]any{"user_id": user.ID, "nickname": user.Nickname})
+}
+
+func PointsAdjustedEvent(award Award) Event {
+	return NewEvent(EventPointsAdjusted, award.At, map[string]any{"user_id": award.UserID, "points": award.Points, "reason": award.Reason})
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const EventPointsExpired = "points.expired"
+
+// PointBatch - партия начисленных очков, Remaining - ещё не потрачено и не сгорело
+type PointBatch struct {
+	ID        int64     `db:"id"`
+	Points    int       `db:"points"`
+	Remaining int       `db:"remaining"`
+	EarnedAt  time.Time `db:"earned_at"`
+	ExpiresAt time.Time `db:"-"` // считается по points_expiration.months
+}
+
+// BatchDebit - сколько очков списание взяло из партии BatchID
+type BatchDebit struct {
+	BatchID  int64     `db:"batch_id"`
+	Points   int       `db:"points"`
+	EarnedAt time.Time `db:"earned_at"`
+}
+
+// ConsumeBatches - какие партии расходует списание points: batches упорядочены от старых к новым (FIFO),
+// Remaining партии уменьшается на взятые из неё очки. Если партий не хватает, остаток списания не привязан к партиям
+func ConsumeBatches(batches []PointBatch, points int) []BatchDebit {
+	var debits []BatchDebit
+	for _, b := range batches {
+		if points <= 0 {
+			break
+		}
+		take := min(b.Remaining, points)
+		if take <= 0 {
+			continue
+		}
+		debits = append(debits, BatchDebit{BatchID: b.ID, Points: take, EarnedAt: b.EarnedAt})
+		points -= take
+	}
+	return debits
+}
+
+// RestoreBatches - партии для начисления points, возвращающего списание debits: очки получают даты списанных партий,
+// начиная с самых новых (списание отменяется с конца), часть сверх списанного - новая партия с датой at.
+// Вернувшиеся очки с истёкшим сроком сгорят при следующей проверке, как сгорели бы без списания
+func RestoreBatches(debits []BatchDebit, points int, at time.Time) []PointBatch {
+	var batches []PointBatch
+	for i := len(debits) - 1; i >= 0 && points > 0; i-- {
+		restored := min(debits[i].Points, points)
+		batches = append(batches, PointBatch{Points: restored, Remaining: restored, EarnedAt: debits[i].EarnedAt})
+		points -= restored
+	}
+	if points > 0 {
+		batches = append(batches, PointBatch{Points: points, Remaining: points, EarnedAt: at})
+	}
+	return batches
+}
+
+// ExpiringPoints - очки, которые сгорят до Before
+type ExpiringPoints struct {
+	Total   int
+	Before  time.Time
+	Batches []PointBatch
+}
+
+var ErrInvalidExpiringQuery = errors.New("Invalid expiring points query")
+
+type ExpirationStore interface {
+	// ExpiringBatches - непотраченные партии пользователя, заработанные до earnedBefore, от старых к новым
+	ExpiringBatches(ctx context.Context, id UserID, earnedBefore time.Time) ([]PointBatch, error)
+	// ExpirePoints для не больше limit пользователей с партиями, заработанными до earnedBefore, списывает их остаток
+	// (не больше баланса) через expire и обнуляет эти партии, каждого пользователя в своей транзакции
+	ExpirePoints(ctx context.Context, earnedBefore time.Time, limit int, expire func(id UserID, points int) (Award, []Event)) ([]Award, error)
+}
+
+type PointsExpirer struct {
+	store ExpirationStore
+	users *UserService // nil - кэш лидерборды догонит периодический resync
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewPointsExpirer(store ExpirationStore, users *UserService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *PointsExpirer {
+	return &PointsExpirer{
+		store: store,
+		users: users,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Run сжигает просроченные очки раз в interval, пока есть полные пачки - без паузы, до отмены ctx
+func (e *PointsExpirer) Run(ctx context.Context, interval time.Duration) {
+	const op = "PointsExpirer.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		expired, err := e.Expire(ctx)
+		if err != nil {
+			e.log.Error(op, "error", err)
+		}
+		if err == nil && expired == e.cfg.PointsExpiration.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expire сжигает очки, заработанные больше months месяцев назад, возвращает сколько пользователей обработано
+func (e *PointsExpirer) Expire(ctx context.Context) (int, error) {
+	const op = "PointsExpirer.Expire"
+	now := e.cl.Now()
+	cutoff := now.AddDate(0, -e.cfg.PointsExpiration.Months, 0)
+	awards, err := e.store.ExpirePoints(ctx, cutoff, e.cfg.PointsExpiration.BatchSize, func(id UserID, points int) (Award, []Event) {
+		award := Award{UserID: id, Points: -points, Reason: "points_expired", At: now, BalanceOnly: true}
+		return award, []Event{NewEvent(EventPointsExpired, now, map[string]any{"user_id": id, "points": points}), PointsAdjustedEvent(award)}
+	})
+	if len(awards) > 0 {
+		if e.users != nil {
+			e.users.pointsChanged(ctx, awards...)
+		}
+		e.log.Info(op+": points expired", "users", len(awards))
+	}
+	return len(awards), err
+}
+
+// Expiring - очки пользователя, которые сгорят в ближайшие within (0 - points_expiration.notify_within)
+func (e *PointsExpirer) Expiring(ctx context.Context, id UserID, within time.Duration) (ExpiringPoints, error) {
+	if within == 0 {
+		within = e.cfg.PointsExpiration.NotifyWithin
+	}
+	if within < 0 {
+		return ExpiringPoints{}, fmt.Errorf("%w: within must be positive", ErrInvalidExpiringQuery)
+	}
+	before := e.cl.Now().Add(within)
+	resp := ExpiringPoints{Before: before, Batches: []PointBatch{}}
+	if !e.cfg.PointsExpiration.Enabled {
+		return resp, nil
+	}
+	months := e.cfg.PointsExpiration.Months
+	batches, err := e.store.ExpiringBatches(ctx, id, before.AddDate(0, -months, 0))
+	if err != nil {
+		return ExpiringPoints{}, err
+	}
+	for _, b := range batches {
+		b.ExpiresAt = b.EarnedAt.AddDate(0, months, 0)
+		resp.Total += b.Remaining
+		resp.Batches = append(resp.Batches, b)
+	}
+	return resp, nil
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+	"sync"
+)
+
+/*In-process pub/sub для живой лидерборды. Подписчику приходит не каждое изменение очков,
+а только сигнал "очки поменялись": у каждой подписки буфер на один сигнал, и если подписчик
+не успел забрать предыдущий, новый с ним сливается. Так медленный клиент не копит очередь
+и не тормозит публикацию, а при следующем чтении просто получает актуальное состояние.
+Общий для всех подписчиков топ лидерборды хранится в хабе и пересчитывается один раз на публикацию
+*/
+
+type ScoreHub struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	version uint64 // номер последней публикации
+
+	topMu      sync.Mutex
+	top        []LeaderboardEntry
+	topVersion uint64
+	topLoaded  bool
+}
+
+type Subscription struct {
+	hub *ScoreHub
+	c   chan struct{}
+}
+
+func NewScoreHub() *ScoreHub {
+	return &ScoreHub{subs: make(map[*Subscription]struct{})}
+}
+
+func (h *ScoreHub) Subscribe() *Subscription {
+	sub := &Subscription{hub: h, c: make(chan struct{}, 1)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Publish сообщает всем подписчикам что очки изменились, никогда не блокируется
+func (h *ScoreHub) Publish() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.version++
+	for sub := range h.subs {
+		select {
+		case sub.c <- struct{}{}:
+		default: //сигнал уже ждёт подписчика
+		}
+	}
+}
+
+// Top - снимок топа лидерборды после последней публикации. load вызывается только если снимок устарел,
+// поэтому на одну публикацию приходится один запрос топа, сколько бы ни было подписчиков
+func (h *ScoreHub) Top(ctx context.Context, load func(ctx context.Context) ([]LeaderboardEntry, error)) ([]LeaderboardEntry, error) {
+	h.mu.Lock()
+	version := h.version
+	h.mu.Unlock()
+	h.topMu.Lock()
+	defer h.topMu.Unlock()
+	if h.topLoaded && h.topVersion == version {
+		return h.top, nil
+	}
+	top, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.top, h.topVersion, h.topLoaded = top, version, true
+	return top, nil
+}
+
+// C - канал сигналов об изменении очков
+func (s *Subscription) C() <-chan struct{} {
+	return s.c
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+}
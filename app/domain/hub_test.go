@@ -0,0 +1,39 @@
+package domain_test
+
+import (
+	"app/domain"
+	"context"
+	"testing"
+)
+
+func TestScoreHubTopLoadsOncePerPublish(t *testing.T) {
+	ctx := context.Background()
+	hub := domain.NewScoreHub()
+	loads := 0
+	load := func(ctx context.Context) ([]domain.LeaderboardEntry, error) {
+		loads++
+		return []domain.LeaderboardEntry{{User: domain.User{ID: domain.UserID(loads)}, Rank: 1}}, nil
+	}
+	//несколько подписчиков читают топ после одной публикации
+	for i := 0; i < 5; i++ {
+		if _, err := hub.Top(ctx, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("top loaded %d times before publish, want 1", loads)
+	}
+	hub.Publish()
+	for i := 0; i < 5; i++ {
+		top, err := hub.Top(ctx, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if top[0].ID != 2 {
+			t.Fatalf("subscriber got stale top with user %d, want 2", top[0].ID)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("top loaded %d times after one publish, want 2", loads)
+	}
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+/*Idempotency-Key: первый ответ на запрос с ключом сохраняется (по пользователю и ключу) на idempotency.ttl,
+повторы с тем же ключом получают сохранённый ответ, а не выполняются ещё раз. Пока первый запрос
+выполняется, повтор получает 409. Ответы 5xx не сохраняются - ключ освобождается и запрос можно повторить
+*/
+
+const MaxIdempotencyKeyLength = 255
+
+var ErrInvalidIdempotencyKey = errors.New("Invalid Idempotency-Key")
+var ErrIdempotencyInFlight = errors.New("Request with this Idempotency-Key is still in progress")
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+
+// IdempotentResponse - сохранённый ответ для повтора
+type IdempotentResponse struct {
+	StatusCode  int    `db:"status_code"`
+	ContentType string `db:"content_type"`
+	Body        []byte `db:"response_body"`
+}
+
+type IdempotencyStore interface {
+	// AcquireIdempotencyKey занимает ключ под выполнение запроса. Возвращает nil, nil если ключ занят этим вызовом,
+	// сохранённый ответ если запрос уже выполнен, ErrIdempotencyInFlight если он ещё выполняется и
+	// ErrIdempotencyKeyReused если с ключом был другой запрос. Истёкшие ключи и ключи, которые заняты дольше lockTimeout
+	// (упавший запрос), занимаются заново
+	AcquireIdempotencyKey(ctx context.Context, userID UserID, key string, fingerprint string, now time.Time, expiresAt time.Time, lockTimeout time.Duration) (*IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, userID UserID, key string, resp IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, userID UserID, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+type IdempotencyService struct {
+	store IdempotencyStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewIdempotencyService(store IdempotencyStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *IdempotencyService {
+	return &IdempotencyService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Acquire - fingerprint описывает запрос (метод, путь, тело), чтобы ключ нельзя было переиспользовать для другого запроса
+func (s IdempotencyService) Acquire(ctx context.Context, userID UserID, key string, fingerprint string) (*IdempotentResponse, error) {
+	const op = "IdempotencyService.Acquire"
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%w: key must be 1 to %d characters", ErrInvalidIdempotencyKey, MaxIdempotencyKeyLength)
+	}
+	now := s.cl.Now()
+	resp, err := s.store.AcquireIdempotencyKey(ctx, userID, key, fingerprint, now, now.Add(s.cfg.Idempotency.TTL), s.cfg.Idempotency.LockTimeout)
+	if err != nil && !errors.Is(err, ErrIdempotencyInFlight) && !errors.Is(err, ErrIdempotencyKeyReused) {
+		s.log.Error(op, "user_id", userID, "error", err)
+	}
+	return resp, err
+}
+
+func (s IdempotencyService) Save(ctx context.Context, userID UserID, key string, resp IdempotentResponse) error {
+	return s.store.SaveIdempotentResponse(ctx, userID, key, resp)
+}
+
+func (s IdempotencyService) Release(ctx context.Context, userID UserID, key string) error {
+	return s.store.ReleaseIdempotencyKey(ctx, userID, key)
+}
+
+// Run удаляет истёкшие ключи раз в interval, до отмены ctx
+func (s IdempotencyService) Run(ctx context.Context, interval time.Duration) {
+	const op = "IdempotencyService.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if purged, err := s.store.PurgeIdempotencyKeys(ctx, s.cl.Now()); err != nil {
+			s.log.Error(op, "error", err)
+		} else if purged > 0 {
+			s.log.Debug(op+": purged expired keys", "count", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	SortByScore    = "score"
+	SortByID       = "id"
+	SortByNickname = "nickname"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	DefaultLeaderboardSize = 20
+	MaxLeaderboardSize     = 100
+
+	RankingCompetition = "competition" // 1,2,2,4
+	RankingDense       = "dense"       // 1,2,2,3
+
+	DefaultRankNeighbours = 5
+	MaxRankNeighbours     = 50
+)
+
+var ErrInvalidLeaderboardQuery = errors.New("Invalid leaderboard parameters")
+
+// LeaderboardQuery - параметры запроса лидерборды. Если передан Cursor, то Page игнорируется и используется keyset пагинация
+type LeaderboardQuery struct {
+	SortBy  string
+	Order   string
+	Page    int
+	Size    int
+	Cursor  *LeaderboardCursor
+	Ranking string // задаётся сервисом из конфига
+
+	//лидерборда за период (day, week, month, custom с From/To), по умолчанию за всё время
+	Period   string
+	Timezone string
+	From     string
+	To       string
+	Window   *TimeWindow // считается сервисом из Period
+}
+
+// LeaderboardEntry - пользователь вместе с его местом в рейтинге по очкам
+type LeaderboardEntry struct {
+	User
+	Rank  int64 `db:"rank"`
+	Level int   `db:"-"` // уровень по опыту, заполняется в UserService
+}
+
+// UserRank - место пользователя, процентиль (какой процент остальных пользователей набрал меньше очков) и соседи сверху и снизу
+type UserRank struct {
+	LeaderboardEntry
+	Percentile float64
+	Total      int
+	Above      []LeaderboardEntry
+	Below      []LeaderboardEntry
+}
+
+// LeaderboardCursor - позиция последней записи на странице, по ней достаётся следующая страница
+type LeaderboardCursor struct {
+	SortBy   string    `json:"s"`
+	Order    string    `json:"o"`
+	Period   string    `json:"p,omitempty"`
+	ID       UserID    `json:"id"`
+	Score    UserScore `json:"sc,omitempty"`
+	Nickname Nickname  `json:"n,omitempty"`
+}
+
+// LeaderboardPage - страница лидерборды вместе с курсором на следующую страницу и общим кол-вом пользователей
+type LeaderboardPage struct {
+	Users      []LeaderboardEntry
+	NextCursor string
+	Total      int
+}
+
+// Validate проверяет параметры и проставляет значения по умолчанию
+func (q *LeaderboardQuery) Validate() error {
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByID
+	case SortByScore, SortByID, SortByNickname:
+	default:
+		return fmt.Errorf("%w: sort_by must be one of score, id, nickname", ErrInvalidLeaderboardQuery)
+	}
+	switch q.Order {
+	case "":
+		//по рейтингу логичнее по убыванию, всё остальное по возрастанию
+		q.Order = OrderAsc
+		if q.SortBy == SortByScore {
+			q.Order = OrderDesc
+		}
+	case OrderAsc, OrderDesc:
+	default:
+		return fmt.Errorf("%w: order must be asc or desc", ErrInvalidLeaderboardQuery)
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Page < 0 {
+		return fmt.Errorf("%w: page must be positive", ErrInvalidLeaderboardQuery)
+	}
+	if q.Size == 0 {
+		q.Size = DefaultLeaderboardSize
+	}
+	if q.Size < 0 || q.Size > MaxLeaderboardSize {
+		return fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidLeaderboardQuery, MaxLeaderboardSize)
+	}
+	if q.Period == "" {
+		q.Period = PeriodAll
+	}
+	if q.Cursor != nil && (q.Cursor.SortBy != q.SortBy || q.Cursor.Order != q.Order || q.Cursor.Period != q.Period) {
+		return fmt.Errorf("%w: cursor doesn't match sort_by, order and period", ErrInvalidLeaderboardQuery)
+	}
+	return nil
+}
+
+// rankingMode - способ нумерации мест из конфига, по умолчанию competition
+func rankingMode(cfg *config.Config) string {
+	if cfg.Leaderboard.Ranking == RankingDense {
+		return RankingDense
+	}
+	return RankingCompetition
+}
+
+// cursorAfter - курсор указывающий на пользователя, после которого начинается следующая страница
+func cursorAfter(q LeaderboardQuery, user User) LeaderboardCursor {
+	return LeaderboardCursor{
+		SortBy:   q.SortBy,
+		Order:    q.Order,
+		Period:   q.Period,
+		ID:       user.ID,
+		Score:    user.Score,
+		Nickname: user.Nickname,
+	}
+}
+
+// Encode упаковывает курсор в непрозрачную для клиента строку
+func (c LeaderboardCursor) Encode() string {
+	raw, _ := json.Marshal(c) //структура из простых полей, ошибки тут быть не может
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeLeaderboardCursor - обратная операция к Encode
+func DecodeLeaderboardCursor(s string) (*LeaderboardCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidLeaderboardQuery)
+	}
+	var c LeaderboardCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidLeaderboardQuery)
+	}
+	return &c, nil
+}
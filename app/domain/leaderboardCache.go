@@ -0,0 +1,322 @@
+package domain
+
+import (
+	"app/iternal/skiplist"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+/*Кэш лидерборды по очкам за всё время в памяти процесса.
+Пользователи лежат в skip list упорядоченными по (score DESC, id ASC), поэтому страница лидерборды
+и место пользователя считаются за O(log n) без похода в бд. Кэш прогревается из UserStore.GetUsers
+при старте, обновляется после каждого успешного начисления очков и периодически сверяется с бд
+(на случай если очки поменялись в обход сервиса или на другой реплике)
+*/
+
+const cacheWarmBatch = 10000
+
+// сколько раз Warm перечитывает из бд пользователей, изменённых во время прогрева, прежде чем перенести их из текущей копии
+const cacheWarmRefreshRounds = 3
+
+type rankKey struct {
+	score UserScore
+	id    UserID
+}
+
+func rankLess(a, b rankKey) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.id < b.id
+}
+
+// rankIndex - одна согласованная копия данных кэша, при пересинхронизации строится новая и подменяет старую
+type rankIndex struct {
+	list       *skiplist.SkipList[rankKey]
+	scores     *skiplist.SkipList[UserScore] // различные значения очков по убыванию, для dense нумерации
+	scoreCount map[UserScore]int
+	users      map[UserID]User
+}
+
+func newRankIndex() *rankIndex {
+	return &rankIndex{
+		list:       skiplist.New(rankLess),
+		scores:     skiplist.New(func(a, b UserScore) bool { return a > b }),
+		scoreCount: make(map[UserScore]int),
+		users:      make(map[UserID]User),
+	}
+}
+
+func (idx *rankIndex) put(user User) {
+	idx.remove(user.ID)
+	idx.users[user.ID] = user
+	idx.list.Insert(rankKey{score: user.Score, id: user.ID})
+	if idx.scoreCount[user.Score] == 0 {
+		idx.scores.Insert(user.Score)
+	}
+	idx.scoreCount[user.Score]++
+}
+
+func (idx *rankIndex) remove(id UserID) {
+	user, ok := idx.users[id]
+	if !ok {
+		return
+	}
+	delete(idx.users, id)
+	idx.list.Delete(rankKey{score: user.Score, id: id})
+	idx.scoreCount[user.Score]--
+	if idx.scoreCount[user.Score] == 0 {
+		delete(idx.scoreCount, user.Score)
+		idx.scores.Delete(user.Score)
+	}
+}
+
+// rank - место для очков score: competition - 1 + кол-во пользователей с большим кол-вом очков,
+// dense - 1 + кол-во различных бОльших значений очков
+func (idx *rankIndex) rank(score UserScore, ranking string) int64 {
+	if ranking == RankingDense {
+		return int64(idx.scores.CountLess(score)) + 1
+	}
+	return int64(idx.list.CountLess(rankKey{score: score, id: math.MinInt64})) + 1
+}
+
+func (idx *rankIndex) entries(keys []rankKey, ranking string) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, LeaderboardEntry{User: idx.users[key.id], Rank: idx.rank(key.score, ranking)})
+	}
+	return entries
+}
+
+type LeaderboardCache struct {
+	store  UserStore
+	log    *slog.Logger
+	warmMu sync.Mutex // Warm не идут параллельно
+	mu     sync.RWMutex
+	idx    *rankIndex
+	dirty  map[UserID]bool // пользователи, изменённые во время Warm, nil если Warm не идёт
+}
+
+func NewLeaderboardCache(store UserStore, log *slog.Logger) *LeaderboardCache {
+	return &LeaderboardCache{
+		store: store,
+		log:   log,
+		idx:   newRankIndex(),
+	}
+}
+
+// Serves - может ли кэш ответить на запрос: только сортировка по очкам по убыванию и за всё время
+func (c *LeaderboardCache) Serves(q LeaderboardQuery) bool {
+	return q.SortBy == SortByScore && q.Order == OrderDesc && q.Window == nil
+}
+
+// GetUsers - то же что UserStore.GetUsers, но из памяти. Вызывать только если Serves(q)
+func (c *LeaderboardCache) GetUsers(q LeaderboardQuery) []LeaderboardEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	offset := (q.Page - 1) * q.Size
+	if q.Cursor != nil {
+		//следующая страница начинается сразу после позиции курсора
+		offset = c.idx.list.CountLess(rankKey{score: q.Cursor.Score, id: q.Cursor.ID + 1})
+	}
+	return c.idx.entries(c.idx.list.Range(offset, q.Size), q.Ranking)
+}
+
+func (c *LeaderboardCache) CountUsers() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.idx.list.Len()
+}
+
+// GetUserRank - то же что UserStore.GetUserRank, но из памяти
+func (c *LeaderboardCache) GetUserRank(id UserID, ranking string, neighbours int) (UserRank, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var rank UserRank
+	user, ok := c.idx.users[id]
+	if !ok {
+		return rank, ErrUserNotFound
+	}
+	total := c.idx.list.Len()
+	pos := c.idx.list.CountLess(rankKey{score: user.Score, id: id})
+	from := max(0, pos-neighbours)
+	window := c.idx.entries(c.idx.list.Range(from, pos-from+neighbours+1), ranking)
+	rank.LeaderboardEntry = window[pos-from]
+	rank.Above = window[:pos-from]
+	rank.Below = window[pos-from+1:]
+	rank.Total = total
+	//как PERCENT_RANK() OVER (ORDER BY score ASC): доля остальных пользователей с меньшим кол-вом очков
+	if total > 1 {
+		lower := total - c.idx.list.CountLess(rankKey{score: user.Score - 1, id: math.MinInt64})
+		rank.Percentile = float64(lower) / float64(total-1) * 100
+	}
+	return rank, nil
+}
+
+// AddUser - новый пользователь попадает в кэш сразу после регистрации
+func (c *LeaderboardCache) AddUser(user User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idx.put(user)
+	c.touch(user.ID)
+}
+
+// RemoveUser - удалённый пользователь пропадает из кэша сразу, не дожидаясь resync
+func (c *LeaderboardCache) RemoveUser(id UserID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idx.remove(id)
+	c.touch(id)
+}
+
+// touch запоминает изменение пользователя, чтобы идущий Warm не затёр его данными, прочитанными раньше. Вызывать под c.mu
+func (c *LeaderboardCache) touch(id UserID) {
+	if c.dirty != nil {
+		c.dirty[id] = true
+	}
+}
+
+// AddPoints - инкрементальное обновление после успешного AddPoints в бд
+func (c *LeaderboardCache) AddPoints(ctx context.Context, award Award) {
+	const op = "LeaderboardCache.AddPoints"
+	id := award.UserID
+	c.mu.Lock()
+	user, ok := c.idx.users[id]
+	if ok {
+		user.Score += UserScore(award.Points)
+		if !award.BalanceOnly {
+			user.XP += int64(max(award.Points, 0))
+		}
+		c.idx.put(user)
+		c.touch(id)
+	}
+	c.mu.Unlock()
+	if ok {
+		return
+	}
+	//пользователя ещё нет в кэше (например зарегистрирован на другой реплике) - берём актуальные данные из бд
+	user, err := c.store.GetUser(ctx, id)
+	if err != nil {
+		c.log.Error(op, "user_id", id, "error", err)
+		return
+	}
+	c.AddUser(user)
+}
+
+// Warm загружает всех пользователей из бд и подменяет ими содержимое кэша.
+// Начисления, пришедшие во время загрузки, не теряются: изменённые пользователи перечитываются из бд перед подменой.
+// Возвращает кол-во пользователей, у которых кэш расходился с бд
+func (c *LeaderboardCache) Warm(ctx context.Context) (int, error) {
+	const op = "LeaderboardCache.Warm"
+	c.warmMu.Lock()
+	defer c.warmMu.Unlock()
+	c.mu.Lock()
+	c.dirty = make(map[UserID]bool)
+	c.mu.Unlock()
+	idx, err := c.load(ctx)
+	for round := 0; err == nil; round++ {
+		c.mu.Lock()
+		dirty := c.dirty
+		if len(dirty) == 0 || round == cacheWarmRefreshRounds {
+			//пользователей, которые всё ещё меняются, переносим из текущей копии вместе с применёнными к ней начислениями
+			for id := range dirty {
+				if user, ok := c.idx.users[id]; ok {
+					idx.put(user)
+				} else {
+					idx.remove(id)
+				}
+			}
+			drift := c.idx.diff(idx)
+			c.idx = idx
+			c.dirty = nil
+			c.mu.Unlock()
+			return drift, nil
+		}
+		c.dirty = make(map[UserID]bool)
+		c.mu.Unlock()
+		err = c.refresh(ctx, idx, dirty)
+	}
+	c.mu.Lock()
+	c.dirty = nil
+	c.mu.Unlock()
+	c.log.Error(op, "error", err)
+	return 0, err
+}
+
+// load читает всех пользователей из бд в новую копию данных кэша
+func (c *LeaderboardCache) load(ctx context.Context) (*rankIndex, error) {
+	idx := newRankIndex()
+	q := LeaderboardQuery{SortBy: SortByScore, Order: OrderDesc, Page: 1, Size: cacheWarmBatch, Ranking: RankingCompetition}
+	for {
+		users, err := c.store.GetUsers(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range users {
+			idx.put(entry.User)
+		}
+		if len(users) < q.Size {
+			return idx, nil
+		}
+		cursor := cursorAfter(q, users[len(users)-1].User)
+		q.Cursor = &cursor
+	}
+}
+
+// refresh перечитывает из бд пользователей ids в копию idx, удалённых убирает
+func (c *LeaderboardCache) refresh(ctx context.Context, idx *rankIndex, ids map[UserID]bool) error {
+	for id := range ids {
+		user, err := c.store.GetUser(ctx, id)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			idx.remove(id)
+		case err != nil:
+			return err
+		default:
+			idx.put(user)
+		}
+	}
+	return nil
+}
+
+// diff - кол-во пользователей, которых нет в одной из копий или у которых различаются очки
+func (idx *rankIndex) diff(other *rankIndex) int {
+	drift := 0
+	for id, user := range other.users {
+		if cached, ok := idx.users[id]; !ok || cached.Score != user.Score {
+			drift++
+		}
+	}
+	for id := range idx.users {
+		if _, ok := other.users[id]; !ok {
+			drift++
+		}
+	}
+	return drift
+}
+
+// RunResync раз в interval сверяет кэш с бд и перезагружает его до отмены ctx
+func (c *LeaderboardCache) RunResync(ctx context.Context, interval time.Duration) {
+	const op = "LeaderboardCache.RunResync"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		drift, err := c.Warm(ctx)
+		if err != nil {
+			c.log.Error(op, "error", err)
+			continue
+		}
+		if drift > 0 {
+			c.log.Warn(op+": leaderboard cache was out of sync with db", "users", drift)
+		}
+	}
+}
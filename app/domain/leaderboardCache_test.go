@@ -0,0 +1,189 @@
+package domain_test
+
+import (
+	"app/domain"
+	storage "app/gates/storage/postgres"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq" //драйвер postgres
+)
+
+// memUsers - пользователи в памяти вместо бд, остальные методы UserStore в тестах кэша не вызываются
+type memUsers struct {
+	domain.UserStore
+	mu    sync.Mutex
+	users map[domain.UserID]domain.User
+	// вызывается после того, как GetUsers прочитал страницу, но до её возврата
+	afterRead func()
+}
+
+func newMemUsers(n int) *memUsers {
+	m := &memUsers{users: make(map[domain.UserID]domain.User, n)}
+	for i := 1; i <= n; i++ {
+		id := domain.UserID(i)
+		m.users[id] = domain.User{ID: id, Score: domain.UserScore(i * 7919 % 1000)}
+	}
+	return m
+}
+
+func (m *memUsers) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// GetUsers - только сортировка по очкам по убыванию с keyset курсором, как её использует Warm
+func (m *memUsers) GetUsers(ctx context.Context, q domain.LeaderboardQuery) ([]domain.LeaderboardEntry, error) {
+	m.mu.Lock()
+	sorted := make([]domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		sorted = append(sorted, user)
+	}
+	m.mu.Unlock()
+	slices.SortFunc(sorted, func(a, b domain.User) int {
+		if a.Score != b.Score {
+			return int(b.Score - a.Score)
+		}
+		return int(a.ID - b.ID)
+	})
+	from := 0
+	if q.Cursor != nil {
+		from = slices.IndexFunc(sorted, func(u domain.User) bool {
+			return u.Score < q.Cursor.Score || (u.Score == q.Cursor.Score && u.ID > q.Cursor.ID)
+		})
+		if from < 0 {
+			from = len(sorted)
+		}
+	}
+	page := make([]domain.LeaderboardEntry, 0, q.Size)
+	for _, user := range sorted[from:min(from+q.Size, len(sorted))] {
+		page = append(page, domain.LeaderboardEntry{User: user})
+	}
+	if m.afterRead != nil {
+		m.afterRead()
+	}
+	return page, nil
+}
+
+// award - начисление в "бд", как его делает UserService перед обновлением кэша
+func (m *memUsers) award(award domain.Award) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.users[award.UserID]
+	user.Score += domain.UserScore(award.Points)
+	m.users[award.UserID] = user
+}
+
+func discardLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestLeaderboardCacheWarmKeepsAwardsMadeDuringWarm(t *testing.T) {
+	ctx := context.Background()
+	store := newMemUsers(10)
+	cache := domain.NewLeaderboardCache(store, discardLog())
+	if _, err := cache.Warm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	//начисление фиксируется в бд и применяется к кэшу уже после того, как прогрев прочитал пользователей
+	award := domain.Award{UserID: 3, Points: 5000, Reason: "task"}
+	store.afterRead = func() {
+		store.afterRead = nil
+		store.award(award)
+		cache.AddPoints(ctx, award)
+	}
+	if _, err := cache.Warm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := store.GetUser(ctx, award.UserID)
+	rank, err := cache.GetUserRank(award.UserID, domain.RankingCompetition, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank.Score != want.Score || rank.Rank != 1 {
+		t.Fatalf("after warm user has score %d and rank %d, want score %d and rank 1", rank.Score, rank.Rank, want.Score)
+	}
+	if drift, err := cache.Warm(ctx); err != nil || drift != 0 {
+		t.Fatalf("next warm found %d drifted users (err %v), want 0", drift, err)
+	}
+}
+
+func TestLeaderboardCacheWarmDropsUsersDeletedDuringWarm(t *testing.T) {
+	ctx := context.Background()
+	store := newMemUsers(10)
+	cache := domain.NewLeaderboardCache(store, discardLog())
+	store.afterRead = func() {
+		store.afterRead = nil
+		store.mu.Lock()
+		delete(store.users, 4)
+		store.mu.Unlock()
+		cache.RemoveUser(4)
+	}
+	if _, err := cache.Warm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserRank(4, domain.RankingCompetition, 0); err != domain.ErrUserNotFound {
+		t.Fatalf("deleted user is still cached, err = %v", err)
+	}
+	if got := cache.CountUsers(); got != 9 {
+		t.Fatalf("CountUsers() = %d, want 9", got)
+	}
+}
+
+// BenchmarkLeaderboard сравнивает страницу из середины лидерборды из кэша и из бд.
+// Путь через бд измеряется только если в LEADERBOARD_BENCH_DSN задана строка подключения к бд с накатанными миграциями
+func BenchmarkLeaderboard(b *testing.B) {
+	ctx := context.Background()
+	middle := func(total int) domain.LeaderboardQuery {
+		size := domain.MaxLeaderboardSize
+		return domain.LeaderboardQuery{SortBy: domain.SortByScore, Order: domain.OrderDesc, Page: total/size/2 + 1, Size: size,
+			Ranking: domain.RankingCompetition}
+	}
+
+	b.Run("cache", func(b *testing.B) {
+		cache := domain.NewLeaderboardCache(newMemUsers(100000), discardLog())
+		if _, err := cache.Warm(ctx); err != nil {
+			b.Fatal(err)
+		}
+		q := middle(cache.CountUsers())
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cache.GetUsers(q)
+		}
+	})
+
+	b.Run("store", func(b *testing.B) {
+		dsn := os.Getenv("LEADERBOARD_BENCH_DSN")
+		if dsn == "" {
+			b.Skip("LEADERBOARD_BENCH_DSN is not set")
+		}
+		conn, err := sqlx.Connect("postgres", dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		store := storage.NewDB(conn, discardLog())
+		total, err := store.CountUsers(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		q := middle(total)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetUsers(ctx, q); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
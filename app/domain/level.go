@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	CurveTable   = "table"
+	CurveFormula = "formula"
+)
+
+const EventLevelUp = "level.up"
+
+// Level - уровень пользователя по опыту (XP) и прогресс до следующего
+type Level struct {
+	Level       int     `json:"level"`
+	XP          int64   `json:"xp"`
+	LevelXP     int64   `json:"level_xp"`                // опыт, с которого начинается текущий уровень
+	NextLevelXP int64   `json:"next_level_xp,omitempty"` // опыт для следующего уровня, 0 если уровень максимальный
+	Progress    float64 `json:"progress"`                // доля пути до следующего уровня, от 0 до 1
+}
+
+type LevelStore interface {
+	GetUser(ctx context.Context, id UserID) (User, error)
+	// LastLevel - последний уровень, за который выдан бонус (1 если ни одного)
+	LastLevel(ctx context.Context, id UserID) (int, error)
+	// GrantLevel отмечает достижение уровня, если он ещё не отмечен, и в той же транзакции начисляет award (если не nil)
+	// и пишет события. false - уровень уже был отмечен
+	GrantLevel(ctx context.Context, id UserID, level int, at time.Time, award *Award, events ...Event) (bool, error)
+	// BackfillLevels берёт до limit пользователей, ожидающих переноса уровней, отмечает им уровни 2..level(xp)
+	// без бонуса и убирает их из очереди, всё одной транзакцией. Возвращает сколько пользователей обработано
+	BackfillLevels(ctx context.Context, limit int, level func(xp int64) int) (int, error)
+}
+
+// ValidateLevels проверяет кривую прогрессии из конфига
+func ValidateLevels(cfg config.Levels) error {
+	switch cfg.Curve {
+	case CurveTable:
+		for i, xp := range cfg.Table {
+			if xp <= 0 || (i > 0 && xp <= cfg.Table[i-1]) {
+				return fmt.Errorf("levels table must be positive and strictly increasing")
+			}
+		}
+	case CurveFormula:
+		if cfg.Base <= 0 || cfg.Growth < 1 || cfg.MaxLevel < 1 {
+			return fmt.Errorf("levels formula needs base > 0, growth >= 1 and max_level >= 1")
+		}
+	default:
+		return fmt.Errorf("levels curve must be table or formula")
+	}
+	return nil
+}
+
+// levelThreshold - опыт, нужный для уровня level (level >= 2), false если такого уровня нет.
+// formula: для уровня 2 нужно base опыта, каждый следующий уровень требует в growth раз больше, чем предыдущий
+func levelThreshold(cfg config.Levels, level int) (int64, bool) {
+	if level < 2 {
+		return 0, true
+	}
+	if cfg.Curve == CurveTable {
+		if level-2 >= len(cfg.Table) {
+			return 0, false
+		}
+		return cfg.Table[level-2], true
+	}
+	if level > cfg.MaxLevel {
+		return 0, false
+	}
+	var total float64
+	step := float64(cfg.Base)
+	for l := 2; l <= level; l++ {
+		total += step
+		step *= cfg.Growth
+	}
+	return int64(math.Round(total)), true
+}
+
+// LevelFor - уровень для опыта xp
+func LevelFor(cfg config.Levels, xp int64) Level {
+	lvl := Level{Level: 1, XP: xp}
+	for {
+		next, ok := levelThreshold(cfg, lvl.Level+1)
+		if !ok {
+			lvl.NextLevelXP = 0
+			lvl.Progress = 1
+			return lvl
+		}
+		if xp < next {
+			lvl.NextLevelXP = next
+			lvl.Progress = float64(xp-lvl.LevelXP) / float64(next-lvl.LevelXP)
+			return lvl
+		}
+		lvl.Level++
+		lvl.LevelXP = next
+	}
+}
+
+// levelBonus - очки за достижение уровня: отдельный бонус из bonuses или общий bonus
+func levelBonus(cfg config.Levels, level int) int {
+	if bonus, ok := cfg.Bonuses[level]; ok {
+		return bonus
+	}
+	return cfg.Bonus
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"log/slog"
+)
+
+type LevelService struct {
+	store LevelStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewLevelService(store LevelStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *LevelService {
+	return &LevelService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// backfillBatch - сколько пользователей переносится одной транзакцией
+const backfillBatch = 1000
+
+// Backfill отмечает без бонуса уровни, которых пользователи достигли до появления уровней, по кривой из конфига.
+// Вызывается при старте до приёма запросов: пока пользователь в очереди переноса, первое начисление выдало бы
+// ему бонусы за все прошлые уровни
+func (s LevelService) Backfill(ctx context.Context) (int, error) {
+	const op = "LevelService.Backfill"
+	total := 0
+	for {
+		n, err := s.store.BackfillLevels(ctx, backfillBatch, func(xp int64) int {
+			return LevelFor(s.cfg.Levels, xp).Level
+		})
+		total += n
+		if err != nil {
+			s.log.Error(op, "error", err)
+			return total, err
+		}
+		if n < backfillBatch {
+			break
+		}
+	}
+	if total > 0 {
+		s.log.Info(op+": levels backfilled", "users", total)
+	}
+	return total, nil
+}
+
+// Evaluate отмечает новые уровни пользователя (событие level.up) и начисляет бонусы за них, возвращает начисленные бонусы.
+// Бонус тоже добавляет опыт, поэтому проверяем заново, пока появляются новые уровни
+func (s LevelService) Evaluate(ctx context.Context, id UserID) ([]Award, error) {
+	const op = "LevelService.Evaluate"
+	var awards []Award
+	for {
+		user, err := s.store.GetUser(ctx, id)
+		if err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return awards, err
+		}
+		last, err := s.store.LastLevel(ctx, id)
+		if err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return awards, err
+		}
+		current := LevelFor(s.cfg.Levels, user.XP).Level
+		if current <= last {
+			return awards, nil
+		}
+		for level := last + 1; level <= current; level++ {
+			now := s.cl.Now()
+			events := []Event{NewEvent(EventLevelUp, now, map[string]any{"user_id": id, "level": level})}
+			var award *Award
+			if bonus := levelBonus(s.cfg.Levels, level); bonus > 0 {
+				award = &Award{UserID: id, Points: bonus, Reason: "level_up", At: now}
+				events = append(events, PointsAdjustedEvent(*award))
+			}
+			granted, err := s.store.GrantLevel(ctx, id, level, now, award, events...)
+			if err != nil {
+				s.log.Error(op, "user_id", id, "level", level, "error", err)
+				return awards, err
+			}
+			if granted {
+				s.log.Info(op+": level up", "user_id", id, "level", level)
+				if award != nil {
+					awards = append(awards, *award)
+				}
+			}
+		}
+	}
+}
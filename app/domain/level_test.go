@@ -0,0 +1,110 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"testing"
+)
+
+func TestLevelFor(t *testing.T) {
+	//для уровня 2 нужно 100, дальше шаги 150 и 225: пороги 100, 250, 475
+	formula := config.Levels{Curve: domain.CurveFormula, Base: 100, Growth: 1.5, MaxLevel: 4}
+	table := config.Levels{Curve: domain.CurveTable, Table: []int64{10, 30, 60}}
+	tests := []struct {
+		name string
+		cfg  config.Levels
+		xp   int64
+		want domain.Level
+	}{
+		{"formula no xp", formula, 0, domain.Level{Level: 1, LevelXP: 0, NextLevelXP: 100, Progress: 0}},
+		{"formula below level 2", formula, 50, domain.Level{Level: 1, XP: 50, NextLevelXP: 100, Progress: 0.5}},
+		{"formula exact threshold", formula, 100, domain.Level{Level: 2, XP: 100, LevelXP: 100, NextLevelXP: 250, Progress: 0}},
+		{"formula growing step", formula, 400, domain.Level{Level: 3, XP: 400, LevelXP: 250, NextLevelXP: 475, Progress: 150.0 / 225}},
+		{"formula max level", formula, 475, domain.Level{Level: 4, XP: 475, LevelXP: 475, Progress: 1}},
+		{"formula beyond max level", formula, 10000, domain.Level{Level: 4, XP: 10000, LevelXP: 475, Progress: 1}},
+		{"table first level", table, 9, domain.Level{Level: 1, XP: 9, NextLevelXP: 10, Progress: 0.9}},
+		{"table middle", table, 45, domain.Level{Level: 3, XP: 45, LevelXP: 30, NextLevelXP: 60, Progress: 0.5}},
+		{"table last", table, 60, domain.Level{Level: 4, XP: 60, LevelXP: 60, Progress: 1}},
+		{"empty table", config.Levels{Curve: domain.CurveTable}, 100, domain.Level{Level: 1, XP: 100, Progress: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := domain.LevelFor(tt.cfg, tt.xp); got != tt.want {
+				t.Fatalf("LevelFor(%d) = %+v, want %+v", tt.xp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelForRoundsFormulaThresholds(t *testing.T) {
+	//шаги 10, 12.5, 15.625: пороги 10, 22.5 -> 23 (округление вверх от .5), 38.125 -> 38
+	cfg := config.Levels{Curve: domain.CurveFormula, Base: 10, Growth: 1.25, MaxLevel: 10}
+	for xp, want := range map[int64]int{22: 2, 23: 3, 37: 3, 38: 4} {
+		if got := domain.LevelFor(cfg, xp).Level; got != want {
+			t.Errorf("LevelFor(%d).Level = %d, want %d", xp, got, want)
+		}
+	}
+}
+
+func TestValidateLevels(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Levels
+		ok   bool
+	}{
+		{"formula", config.Levels{Curve: domain.CurveFormula, Base: 100, Growth: 1.5, MaxLevel: 50}, true},
+		{"formula shrinking", config.Levels{Curve: domain.CurveFormula, Base: 100, Growth: 0.9, MaxLevel: 50}, false},
+		{"formula without base", config.Levels{Curve: domain.CurveFormula, Growth: 1.5, MaxLevel: 50}, false},
+		{"table", config.Levels{Curve: domain.CurveTable, Table: []int64{10, 30}}, true},
+		{"table not increasing", config.Levels{Curve: domain.CurveTable, Table: []int64{30, 30}}, false},
+		{"unknown curve", config.Levels{Curve: "linear"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := domain.ValidateLevels(tt.cfg); (err == nil) != tt.ok {
+				t.Fatalf("ValidateLevels() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// memBackfill - очередь переноса уровней в памяти: опыт пользователей и отмеченные уровни
+type memBackfill struct {
+	domain.LevelStore
+	queue  map[domain.UserID]int64
+	levels map[domain.UserID]int
+}
+
+func (m *memBackfill) BackfillLevels(ctx context.Context, limit int, level func(xp int64) int) (int, error) {
+	n := 0
+	for id, xp := range m.queue {
+		if n == limit {
+			break
+		}
+		m.levels[id] = level(xp)
+		delete(m.queue, id)
+		n++
+	}
+	return n, nil
+}
+
+func TestLevelBackfill(t *testing.T) {
+	store := &memBackfill{queue: map[domain.UserID]int64{}, levels: map[domain.UserID]int{}}
+	//больше одной пачки, чтобы перенос не остановился после первой
+	for id := domain.UserID(1); id <= 1500; id++ {
+		store.queue[id] = int64(id)
+	}
+	cfg := &config.Config{Levels: config.Levels{Curve: domain.CurveTable, Table: []int64{100, 1000}}}
+	s := domain.NewLevelService(store, discardLog(), cfg, &pkg.StubClock{})
+	n, err := s.Backfill(context.Background())
+	if err != nil || n != 1500 || len(store.queue) != 0 {
+		t.Fatalf("Backfill() = %d, %v with %d users left, want all 1500", n, err, len(store.queue))
+	}
+	for id, want := range map[domain.UserID]int{99: 1, 100: 2, 999: 2, 1000: 3} {
+		if store.levels[id] != want {
+			t.Errorf("user %d with %d xp backfilled to level %d, want %d", id, id, store.levels[id], want)
+		}
+	}
+}
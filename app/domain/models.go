@@ -13,17 +13,53 @@ type Email string
 type Nickname string
 
 type User struct {
-	ID         UserID    `db:"id"`
-	Nickname   Nickname  `db:"nickname"`
-	Email      Email     `db:"email"`
-	Score      UserScore `db:"score"`
-	Registered time.Time `db:"registered"`
-	InvitedBy  *UserID   `db:"invited_by"`
+	ID          UserID     `db:"id"`
+	Nickname    Nickname   `db:"nickname"`
+	Email       Email      `db:"email"`
+	Score       UserScore  `db:"score"`
+	SeasonScore UserScore  `db:"season_score"` // очки текущего сезона, обнуляются при закрытии сезона
+	XP          int64      `db:"xp"`           // все когда-либо заработанные очки, по ним считается уровень
+	Registered  time.Time  `db:"registered"`
+	InvitedBy   *UserID    `db:"invited_by"`
+	Timezone    *string    `db:"timezone"`     // часовой пояс для подсчёта дней, nil - пояс по умолчанию
+	SuspendedAt *time.Time `db:"suspended_at"` // время блокировки аккаунта администратором, nil - не заблокирован
+	//время подтверждения текущего email по ссылке из письма, nil - не подтверждён
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
 }
 
+// Award - начисление очков пользователю, Reason - название награды (задания) за которую начислены очки
+type Award struct {
+	UserID UserID
+	Points int
+	Reason string
+	At     time.Time
+	//списание или возврат: меняет только баланс (score), но не опыт и не очки сезона
+	BalanceOnly bool
+	Multiplier  float64 // множитель бонусных событий, с которым посчитаны Points, 0 - без множителя
+	//начисление возвращает очки списания Restores и получает даты партий, из которых они были списаны:
+	//возврат - исходные даты, перевод - даты очков отправителя. nil - новая партия с датой At
+	Restores *DebitRef
+}
+
+// DebitRef - списание очков пользователя UserID с причиной Reason
+type DebitRef struct {
+	UserID UserID
+	Reason string
+}
+
+const (
+	RewardInviting = "inviting_a_friend"
+	RewardInvited  = "being_invited"
+)
+
 var ErrNotEmail = errors.New("Wrong format of email")
+var ErrInvalidTimezone = errors.New("Unknown timezone")
 var ErrNotExistingReward = errors.New("This reward does not exist")
 var ErrNoRewardRef = errors.New("No reward for inviting found")
+var ErrUserNotFound = errors.New("User not found")
+var ErrUserAlreadyInvited = errors.New("User already invited")
+var ErrNicknameTaken = errors.New("Nickname is already taken")
+var ErrEmailTaken = errors.New("Email is already taken")
 
 // функция инициализирующая мапу наград из конфига. Размер награды можно изменять config.yaml
 func initRewards(cfg *config.Config) map[string]int {
@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/*Transactional outbox: события пишутся в таблицу outbox в той же транзакции, что и изменения
+очков и рефералов, поэтому падение сервиса после записи в бд не теряет событие. OutboxRelay
+периодически арендует неопубликованные события и отдаёт их EventPublisher уже вне транзакции, так что
+медленный получатель не держит блокировки строк. Доставка at-least-once: если relay упадёт после публикации,
+но до отметки published_at, событие уйдёт ещё раз по истечении аренды, получатели должны дедуплицировать по Event.ID.
+Неудачная публикация повторяется с экспоненциальной задержкой, после outbox.max_attempts событие помечается failed
+и больше не мешает остальным
+*/
+
+// OutboxEvent - арендованное relay событие
+type OutboxEvent struct {
+	ID       int64  `db:"id"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+type OutboxStore interface {
+	// ClaimOutbox забирает до limit готовых к публикации событий (FOR UPDATE SKIP LOCKED) и откладывает их следующую
+	// попытку на lease, чтобы их не взял relay другой реплики. Транзакция завершается до возврата
+	ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, id int64, at time.Time) error
+	RetryOutbox(ctx context.Context, id int64, lastErr string, next time.Time) error
+	// MarkOutboxFailed - событие исчерпало попытки и больше не публикуется
+	MarkOutboxFailed(ctx context.Context, id int64, lastErr string, at time.Time) error
+}
+
+type OutboxRelay struct {
+	store     OutboxStore
+	publisher EventPublisher
+	log       *slog.Logger
+	cfg       *config.Config
+	cl        pkg.Clock
+}
+
+func NewOutboxRelay(store OutboxStore, publisher EventPublisher, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *OutboxRelay {
+	return &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		log:       log,
+		cfg:       cfg,
+		cl:        cl,
+	}
+}
+
+// Run публикует события раз в interval, пока есть полные пачки - без паузы, до отмены ctx
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	const op = "OutboxRelay.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := r.RelayDue(ctx)
+		if err != nil {
+			r.log.Error(op, "error", err)
+		}
+		if err == nil && claimed == r.cfg.Outbox.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayDue публикует одну пачку готовых событий, возвращает сколько событий было взято в работу
+func (r *OutboxRelay) RelayDue(ctx context.Context) (int, error) {
+	const op = "OutboxRelay.RelayDue"
+	//аренда покрывает публикацию всей пачки: события публикуются по очереди, каждое не дольше outbox.timeout
+	lease := r.cfg.Outbox.Timeout * time.Duration(r.cfg.Outbox.BatchSize+1)
+	events, err := r.store.ClaimOutbox(ctx, r.cl.Now(), lease, r.cfg.Outbox.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if err := r.relay(ctx, e); err != nil {
+			r.log.Error(op, "outbox_id", e.ID, "error", err)
+		}
+	}
+	return len(events), nil
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, e OutboxEvent) error {
+	const op = "OutboxRelay.relay"
+	var event Event
+	err := json.Unmarshal(e.Payload, &event)
+	if err == nil {
+		publishCtx, cancel := context.WithTimeout(ctx, r.cfg.Outbox.Timeout)
+		err = r.publisher.Publish(publishCtx, event)
+		cancel()
+	}
+	now := r.cl.Now()
+	if err == nil {
+		return r.store.MarkOutboxPublished(ctx, e.ID, now)
+	}
+	attempts := e.Attempts + 1
+	if attempts >= r.cfg.Outbox.MaxAttempts {
+		r.log.Warn(op+": event failed", "outbox_id", e.ID, "attempts", attempts, "error", err)
+		return r.store.MarkOutboxFailed(ctx, e.ID, err.Error(), now)
+	}
+	r.log.Warn(op+": failed to publish event", "outbox_id", e.ID, "attempts", attempts, "error", err)
+	return r.store.RetryOutbox(ctx, e.ID, err.Error(), now.Add(expBackoff(r.cfg.Outbox.BackoffBase, r.cfg.Outbox.BackoffMax, attempts)))
+}
+
+// MultiPublisher отдаёт событие всем получателям по очереди, ошибка любого - ошибка всей публикации
+// (событие будет опубликовано повторно, в том числе тем получателям, которые его уже получили)
+type MultiPublisher []EventPublisher
+
+func (m MultiPublisher) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogPublisher пишет события в лог
+type LogPublisher struct {
+	Log *slog.Logger
+}
+
+func (l LogPublisher) Publish(ctx context.Context, event Event) error {
+	l.Log.Info("domain event", "event_id", event.ID, "type", event.Type, "occurred_at", event.OccurredAt, "data", event.Data)
+	return nil
+}
+
+// MemoryPublisher складывает события в память, для тестов и локальной разработки.
+// Повторно опубликованные события (с тем же ID) не дублируются
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+	seen   map[string]bool
+}
+
+func (m *MemoryPublisher) Publish(ctx context.Context, event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen == nil {
+		m.seen = make(map[string]bool)
+	}
+	if m.seen[event.ID] {
+		return nil
+	}
+	m.seen[event.ID] = true
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MemoryPublisher) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event(nil), m.events...)
+}
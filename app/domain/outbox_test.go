@@ -0,0 +1,116 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type outboxRow struct {
+	domain.OutboxEvent
+	next      time.Time
+	published bool
+	failed    bool
+}
+
+// memOutbox - таблица outbox в памяти
+type memOutbox struct {
+	rows []*outboxRow
+}
+
+func (m *memOutbox) add(t *testing.T, events ...domain.Event) {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.rows = append(m.rows, &outboxRow{OutboxEvent: domain.OutboxEvent{ID: int64(len(m.rows) + 1), Payload: payload}})
+	}
+}
+
+func (m *memOutbox) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error) {
+	var claimed []domain.OutboxEvent
+	for _, row := range m.rows {
+		if !row.published && !row.failed && !row.next.After(now) && len(claimed) < limit {
+			row.next = now.Add(lease)
+			claimed = append(claimed, row.OutboxEvent)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *memOutbox) MarkOutboxPublished(ctx context.Context, id int64, at time.Time) error {
+	m.rows[id-1].Attempts++
+	m.rows[id-1].published = true
+	return nil
+}
+
+func (m *memOutbox) RetryOutbox(ctx context.Context, id int64, lastErr string, next time.Time) error {
+	m.rows[id-1].Attempts++
+	m.rows[id-1].next = next
+	return nil
+}
+
+func (m *memOutbox) MarkOutboxFailed(ctx context.Context, id int64, lastErr string, at time.Time) error {
+	m.rows[id-1].Attempts++
+	m.rows[id-1].failed = true
+	return nil
+}
+
+// rejectingPublisher не принимает события из reject, остальные складывает в память
+type rejectingPublisher struct {
+	domain.MemoryPublisher
+	reject map[string]bool
+}
+
+func (p *rejectingPublisher) Publish(ctx context.Context, event domain.Event) error {
+	if p.reject[event.ID] {
+		return errors.New("400 bad request")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func TestOutboxRelayBacksOffAndFailsPoisonEvent(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cl := &pkg.StubClock{Time: start}
+	cfg := &config.Config{Outbox: config.Outbox{BatchSize: 1, Timeout: time.Second, MaxAttempts: 3, BackoffBase: time.Second, BackoffMax: time.Minute}}
+	poison := domain.NewEvent(domain.EventTaskCompleted, start, map[string]any{"user_id": 1})
+	good := domain.NewEvent(domain.EventTaskCompleted, start, map[string]any{"user_id": 2})
+	store := &memOutbox{}
+	store.add(t, poison, good)
+	publisher := &rejectingPublisher{reject: map[string]bool{poison.ID: true}}
+	relay := domain.NewOutboxRelay(store, publisher, discardLog(), cfg, cl)
+
+	//пачка из одного события: ядовитое событие первым в очереди не блокирует следующее
+	for i := 0; i < 2; i++ {
+		if _, err := relay.RelayDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := publisher.Events(); len(got) != 1 || got[0].ID != good.ID {
+		t.Fatalf("published %v, want only the good event", got)
+	}
+	if row := store.rows[0]; row.Attempts != 1 || !row.next.Equal(start.Add(time.Second)) {
+		t.Fatalf("poison event has %d attempts and next attempt at %v, want 1 and %v", row.Attempts, row.next, start.Add(time.Second))
+	}
+	//повторы через 1s и 2s, третья неудача - failed
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		cl.Time = cl.Time.Add(delay)
+		if _, err := relay.RelayDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if row := store.rows[0]; !row.failed || row.Attempts != 3 {
+		t.Fatalf("poison event failed=%v after %d attempts, want failed after 3", row.failed, row.Attempts)
+	}
+	cl.Time = cl.Time.Add(time.Hour)
+	if claimed, err := relay.RelayDue(ctx); err != nil || claimed != 0 {
+		t.Fatalf("relay claimed %d events after all were published or failed (err %v)", claimed, err)
+	}
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	PeriodAll    = "all"
+	PeriodDay    = "day"
+	PeriodWeek   = "week"
+	PeriodMonth  = "month"
+	PeriodCustom = "custom"
+
+	dateLayout = "2006-01-02"
+)
+
+// TimeWindow - полуинтервал [From, To) в котором были заработаны очки
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// PeriodWindow считает границы периода относительно now в часовом поясе loc.
+// Неделя начинается с понедельника. Для custom нужен from, to по умолчанию - текущий момент
+func PeriodWindow(period string, now time.Time, loc *time.Location, from, to string) (*TimeWindow, error) {
+	now = now.In(loc)
+	today := StartOfDay(now)
+	switch period {
+	case "", PeriodAll:
+		return nil, nil
+	case PeriodDay:
+		return &TimeWindow{From: today, To: today.AddDate(0, 0, 1)}, nil
+	case PeriodWeek:
+		//в go неделя начинается с воскресенья, сдвигаем на понедельник
+		offset := (int(today.Weekday()) + 6) % 7
+		start := today.AddDate(0, 0, -offset)
+		return &TimeWindow{From: start, To: start.AddDate(0, 0, 7)}, nil
+	case PeriodMonth:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return &TimeWindow{From: start, To: start.AddDate(0, 1, 0)}, nil
+	case PeriodCustom:
+		if from == "" {
+			return nil, fmt.Errorf("%w: from is required for custom period", ErrInvalidLeaderboardQuery)
+		}
+		window := TimeWindow{To: now}
+		var err error
+		if window.From, err = parseWindowBound(from, loc); err != nil {
+			return nil, err
+		}
+		if to != "" {
+			if window.To, err = parseWindowBound(to, loc); err != nil {
+				return nil, err
+			}
+		}
+		if !window.From.Before(window.To) {
+			return nil, fmt.Errorf("%w: from must be before to", ErrInvalidLeaderboardQuery)
+		}
+		return &window, nil
+	}
+	return nil, fmt.Errorf("%w: period must be one of all, day, week, month, custom", ErrInvalidLeaderboardQuery)
+}
+
+// StartOfDay - полночь того дня, в котором находится t (в часовом поясе t)
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseWindowBound принимает дату (2006-01-02) в часовом поясе loc или RFC3339
+func parseWindowBound(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, s, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%w: dates must be in 2006-01-02 or RFC3339 format", ErrInvalidLeaderboardQuery)
+	}
+	return t, nil
+}
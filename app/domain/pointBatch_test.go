@@ -0,0 +1,85 @@
+package domain_test
+
+import (
+	"app/domain"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConsumeBatches(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	//от старых к новым, как их выбирает хранилище
+	batches := []domain.PointBatch{
+		{ID: 1, Points: 10, Remaining: 4, EarnedAt: jan},
+		{ID: 2, Points: 10, Remaining: 0, EarnedAt: feb},
+		{ID: 3, Points: 20, Remaining: 20, EarnedAt: feb},
+		{ID: 4, Points: 5, Remaining: 5, EarnedAt: mar},
+	}
+	tests := []struct {
+		name   string
+		points int
+		want   []domain.BatchDebit
+	}{
+		{"nothing", 0, nil},
+		{"part of the oldest", 3, []domain.BatchDebit{{BatchID: 1, Points: 3, EarnedAt: jan}}},
+		{"oldest exactly", 4, []domain.BatchDebit{{BatchID: 1, Points: 4, EarnedAt: jan}}},
+		{"skips spent batch", 10, []domain.BatchDebit{{BatchID: 1, Points: 4, EarnedAt: jan}, {BatchID: 3, Points: 6, EarnedAt: feb}}},
+		{"all batches", 29, []domain.BatchDebit{{BatchID: 1, Points: 4, EarnedAt: jan}, {BatchID: 3, Points: 20, EarnedAt: feb},
+			{BatchID: 4, Points: 5, EarnedAt: mar}}},
+		//баланс мог разойтись с партиями, лишнее просто не привязано к партиям
+		{"more than batches", 100, []domain.BatchDebit{{BatchID: 1, Points: 4, EarnedAt: jan}, {BatchID: 3, Points: 20, EarnedAt: feb},
+			{BatchID: 4, Points: 5, EarnedAt: mar}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := domain.ConsumeBatches(batches, tt.points); !slices.Equal(got, tt.want) {
+				t.Fatalf("ConsumeBatches(%d) = %+v, want %+v", tt.points, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreBatches(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	debits := []domain.BatchDebit{{BatchID: 1, Points: 4, EarnedAt: jan}, {BatchID: 3, Points: 6, EarnedAt: feb}}
+	batch := func(points int, at time.Time) domain.PointBatch {
+		return domain.PointBatch{Points: points, Remaining: points, EarnedAt: at}
+	}
+	tests := []struct {
+		name   string
+		debits []domain.BatchDebit
+		points int
+		want   []domain.PointBatch
+	}{
+		{"full refund keeps dates", debits, 10, []domain.PointBatch{batch(6, feb), batch(4, jan)}},
+		//частичный возврат отменяет списание с конца - сначала самые новые партии
+		{"partial refund", debits, 7, []domain.PointBatch{batch(6, feb), batch(1, jan)}},
+		{"more than debited", debits, 12, []domain.PointBatch{batch(6, feb), batch(4, jan), batch(2, now)}},
+		{"debit without batches", nil, 5, []domain.PointBatch{batch(5, now)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := domain.RestoreBatches(tt.debits, tt.points, now); !slices.Equal(got, tt.want) {
+				t.Fatalf("RestoreBatches(%d) = %+v, want %+v", tt.points, got, tt.want)
+			}
+		})
+	}
+}
+
+// consume и restore вместе: перевод сохраняет даты отправителя
+func TestTransferKeepsBatchDates(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	sender := []domain.PointBatch{{ID: 1, Points: 5, Remaining: 5, EarnedAt: jan}, {ID: 2, Points: 10, Remaining: 10, EarnedAt: mar}}
+	received := domain.RestoreBatches(domain.ConsumeBatches(sender, 8), 8, now)
+	want := []domain.PointBatch{{Points: 3, Remaining: 3, EarnedAt: mar}, {Points: 5, Remaining: 5, EarnedAt: jan}}
+	if !slices.Equal(received, want) {
+		t.Fatalf("received batches %+v, want %+v", received, want)
+	}
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	//email подтверждён, в данных события нет самого адреса
+	EventEmailVerified = "user.email_verified"
+)
+
+// DeletedNicknamePrefix - никнеймы удалённых аккаунтов, занять такой никнейм нельзя
+const DeletedNicknamePrefix = "deleted_"
+
+// максимальная длина никнейма и email (размер колонок users)
+const maxProfileField = 255
+
+// ProfileUpdate - изменения профиля, nil - поле не меняется
+type ProfileUpdate struct {
+	Nickname *Nickname
+	Email    *Email  // новый email применяется только после подтверждения по ссылке из письма
+	Timezone *string // пустая строка сбрасывает пояс на пояс по умолчанию
+}
+
+// EmailToken - одноразовый токен подтверждения email, в бд хранится только sha256 токена
+type EmailToken struct {
+	Hash      string     `db:"token_hash"`
+	UserID    UserID     `db:"user_id"`
+	Email     Email      `db:"email"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// Mail - письмо пользователю
+type Mail struct {
+	To      Email
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+var ErrInvalidProfile = errors.New("Invalid profile")
+var ErrInvalidEmailToken = errors.New("Email token is invalid, used or expired")
+var ErrEmailNotVerified = errors.New("Email is not verified")
+var ErrEmailAlreadyVerified = errors.New("Email is already verified")
+
+type ProfileStore interface {
+	GetUser(ctx context.Context, id UserID) (User, error)
+	// UpdateProfile сохраняет никнейм и часовой пояс user (ErrNicknameTaken) и пишет события одной транзакцией
+	UpdateProfile(ctx context.Context, user User, events ...Event) (User, error)
+	// EmailTaken - email уже у другого пользователя
+	EmailTaken(ctx context.Context, email Email, except UserID) (bool, error)
+	// AddEmailToken сохраняет токен, прежние неиспользованные токены пользователя перестают действовать
+	AddEmailToken(ctx context.Context, token EmailToken) error
+	// UseEmailToken помечает действующий токен использованным и ставит пользователю email из токена подтверждённым,
+	// события use пишутся в той же транзакции (ErrInvalidEmailToken, ErrEmailTaken)
+	UseEmailToken(ctx context.Context, hash string, at time.Time, use func(user User) []Event) (User, error)
+	// DeleteUser обезличивает пользователя: никнейм и email заменяются, часовой пояс и подтверждение email сбрасываются,
+	// пользователь выходит из команды и пропадает из лидерборд, непроверенные заявки отклоняются, доказательства
+	// убираются из заявок, привязки внешних аккаунтов удаляются. Возвращает ключи файлов доказательств, их удаляет
+	// вызывающий после транзакции. Очки, журнал начислений и ссылки invited_by остаются
+	DeleteUser(ctx context.Context, id UserID, at time.Time, events ...Event) ([]string, error)
+}
+
+// HashEmailToken - sha256 токена, по нему токен ищется в бд
+func HashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,207 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type ProfileService struct {
+	store      ProfileStore
+	mailer     Mailer
+	proofs     ProofStore        // может быть nil, если заявки с доказательствами не используются
+	users      *UserService      // для обновления кэша лидерборды
+	challenges *ChallengeService // может быть nil, тогда открытые вызовы удалённого пользователя закроет планировщик по сроку
+	log        *slog.Logger
+	cfg        *config.Config
+	cl         pkg.Clock
+}
+
+func NewProfileService(store ProfileStore, mailer Mailer, proofs ProofStore, users *UserService, challenges *ChallengeService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *ProfileService {
+	return &ProfileService{
+		store:      store,
+		mailer:     mailer,
+		proofs:     proofs,
+		users:      users,
+		challenges: challenges,
+		log:        log,
+		cfg:        cfg,
+		cl:         cl,
+	}
+}
+
+// ValidateNickname - никнейм не пустой, не длиннее колонки и не из зарезервированных для удалённых аккаунтов
+func ValidateNickname(nickname Nickname) error {
+	switch {
+	case strings.TrimSpace(string(nickname)) == "":
+		return fmt.Errorf("%w: nickname is required", ErrInvalidProfile)
+	case len(nickname) > maxProfileField:
+		return fmt.Errorf("%w: nickname must be at most %d bytes", ErrInvalidProfile, maxProfileField)
+	case strings.HasPrefix(string(nickname), DeletedNicknamePrefix):
+		return fmt.Errorf("%w: nickname can't start with %q", ErrInvalidProfile, DeletedNicknamePrefix)
+	}
+	return nil
+}
+
+// Update меняет профиль пользователя. Возвращает обновлённого пользователя и true, если на новый email
+// отправлено письмо для подтверждения - до подтверждения у пользователя остаётся прежний email
+func (s ProfileService) Update(ctx context.Context, id UserID, update ProfileUpdate) (User, bool, error) {
+	const op = "ProfileService.Update"
+	user, err := s.store.GetUser(ctx, id)
+	if err != nil {
+		return User{}, false, err
+	}
+	if update.Nickname != nil {
+		if err := ValidateNickname(*update.Nickname); err != nil {
+			return User{}, false, err
+		}
+		user.Nickname = *update.Nickname
+	}
+	if update.Timezone != nil {
+		if *update.Timezone == "" {
+			user.Timezone = nil
+		} else if _, err := time.LoadLocation(*update.Timezone); err != nil {
+			return User{}, false, fmt.Errorf("%w: %q", ErrInvalidTimezone, *update.Timezone)
+		} else {
+			user.Timezone = update.Timezone
+		}
+	}
+	emailChanged := update.Email != nil && *update.Email != user.Email
+	if emailChanged {
+		if err := VerifyEmail(*update.Email); err != nil {
+			return User{}, false, err
+		}
+		if len(*update.Email) > maxProfileField {
+			return User{}, false, fmt.Errorf("%w: email must be at most %d bytes", ErrInvalidProfile, maxProfileField)
+		}
+		//занятость проверяем сразу, чтобы не слать письмо зря, окончательно её проверит уникальный индекс при подтверждении
+		taken, err := s.store.EmailTaken(ctx, *update.Email, id)
+		if err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return User{}, false, err
+		}
+		if taken {
+			return User{}, false, ErrEmailTaken
+		}
+	}
+	if update.Nickname != nil || update.Timezone != nil {
+		user, err = s.store.UpdateProfile(ctx, user, NewEvent(EventUserUpdated, s.cl.Now(), map[string]any{"user_id": id, "nickname": user.Nickname}))
+		if err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return User{}, false, err
+		}
+		if s.users != nil && s.users.board != nil {
+			s.users.board.AddUser(user)
+		}
+	}
+	if emailChanged {
+		if err := s.sendToken(ctx, id, *update.Email); err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return User{}, false, err
+		}
+	}
+	s.log.Info(op+": profile updated", "user_id", id, "email_change", emailChanged)
+	return user, emailChanged, nil
+}
+
+// SendVerification отправляет письмо для подтверждения текущего email пользователя (после регистрации или повторно)
+func (s ProfileService) SendVerification(ctx context.Context, id UserID) error {
+	const op = "ProfileService.SendVerification"
+	user, err := s.store.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	if err := s.sendToken(ctx, id, user.Email); err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return err
+	}
+	s.log.Info(op+": verification sent", "user_id", id)
+	return nil
+}
+
+// sendToken выпускает токен подтверждения email и отправляет его письмом на этот email
+func (s ProfileService) sendToken(ctx context.Context, id UserID, email Email) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	now := s.cl.Now()
+	err := s.store.AddEmailToken(ctx, EmailToken{
+		Hash:      HashEmailToken(token),
+		UserID:    id,
+		Email:     email,
+		ExpiresAt: now.Add(s.cfg.Email.TokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	link := s.cfg.Email.VerifyURL + "?token=" + token
+	return s.mailer.Send(ctx, Mail{
+		To:      email,
+		Subject: "Confirm your email",
+		Body:    fmt.Sprintf("Open %s or send the code %s to POST /auth/verify-email. The link expires in %s.", link, token, s.cfg.Email.TokenTTL),
+	})
+}
+
+// VerifyEmail подтверждает email одноразовым токеном из письма: после регистрации - текущий, при смене - новый
+func (s ProfileService) VerifyEmail(ctx context.Context, token string) (User, error) {
+	const op = "ProfileService.VerifyEmail"
+	now := s.cl.Now()
+	user, err := s.store.UseEmailToken(ctx, HashEmailToken(token), now, func(user User) []Event {
+		return []Event{NewEvent(EventEmailVerified, now, map[string]any{"user_id": user.ID})}
+	})
+	if err != nil {
+		s.log.Info(op+": email not verified", "error", err)
+		return User{}, err
+	}
+	s.log.Info(op+": email verified", "user_id", user.ID)
+	return user, nil
+}
+
+// Delete удаляет аккаунт: открытые вызовы отменяются с возвратом ставок, затем данные пользователя обезличиваются
+// и удаляются файлы доказательств
+func (s ProfileService) Delete(ctx context.Context, id UserID) error {
+	const op = "ProfileService.Delete"
+	if _, err := s.store.GetUser(ctx, id); err != nil {
+		return err
+	}
+	if s.challenges != nil {
+		if err := s.challenges.CancelAll(ctx, id); err != nil {
+			s.log.Error(op, "user_id", id, "error", err)
+			return err
+		}
+	}
+	now := s.cl.Now()
+	keys, err := s.store.DeleteUser(ctx, id, now, NewEvent(EventUserDeleted, now, map[string]any{"user_id": id}))
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return err
+	}
+	//аккаунт уже удалён, неудалённый файл только логируем
+	for _, key := range keys {
+		if s.proofs != nil {
+			if err := s.proofs.Delete(context.WithoutCancel(ctx), key); err != nil {
+				s.log.Error(op, "user_id", id, "proof_key", key, "error", err)
+			}
+		}
+	}
+	if s.users != nil && s.users.board != nil {
+		s.users.board.RemoveUser(id)
+	}
+	if s.users != nil && s.users.hub != nil {
+		s.users.hub.Publish()
+	}
+	s.log.Info(op+": user deleted", "user_id", id)
+	return nil
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	QuestOnce = "once"
+	QuestDay  = "day"
+)
+
+const EventQuestCompleted = "quest.completed"
+
+// questOnce - период одноразовых квестов
+var questOnce = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// maxQuestSteps - выполненные шаги хранятся битами в BIGINT
+const maxQuestSteps = 63
+
+// QuestProgress - какие шаги квеста пройдены за период (день или единственный период для once)
+type QuestProgress struct {
+	Quest       string     `db:"quest"`
+	Period      time.Time  `db:"period"`
+	Step        int        `db:"step"` // кол-во выполненных шагов
+	Done        int64      `db:"done"` // выполненные шаги: бит i - шаг i
+	CompletedAt *time.Time `db:"completed_at"`
+}
+
+// StepDone - шаг step выполнен за период
+func (p QuestProgress) StepDone(step int) bool {
+	return p.Done&(1<<step) != 0
+}
+
+// QuestStatus - квест и прогресс пользователя в текущем периоде
+type QuestStatus struct {
+	Key       string
+	Quest     config.Quest
+	Step      int
+	Done      []string // выполненные задания в порядке шагов
+	Completed bool
+	Next      string // следующее невыполненное задание, пусто если квест пройден
+}
+
+var ErrQuestPrerequisite = errors.New("Previous quest steps must be completed first")
+
+type QuestStore interface {
+	// GetQuestProgress - прогресс пользователя по квестам за периоды: ключ - квест, значение - период
+	GetQuestProgress(ctx context.Context, id UserID, periods map[string]time.Time) (map[string]QuestProgress, error)
+	// AdvanceQuest отмечает шаг step выполненным, если он ещё не отмечен (иначе false). Если выполнены все total шагов,
+	// отмечает прохождение и в той же транзакции начисляет award из complete (если не nil) и пишет его события
+	AdvanceQuest(ctx context.Context, id UserID, quest string, period time.Time, step int, total int, at time.Time, complete func() (*Award, []Event)) (bool, error)
+}
+
+// ValidateQuests проверяет квесты из конфига
+func ValidateQuests(cfg *config.Config) error {
+	for key, q := range cfg.Quests {
+		if len(q.Steps) < 2 || len(q.Steps) > maxQuestSteps {
+			return fmt.Errorf("quest %q: from 2 to %d steps required", key, maxQuestSteps)
+		}
+		for i, step := range q.Steps {
+			if _, ok := cfg.Rewards[step]; !ok {
+				return fmt.Errorf("quest %q: unknown task %q", key, step)
+			}
+			if slices.Index(q.Steps, step) != i {
+				return fmt.Errorf("quest %q: task %q is repeated", key, step)
+			}
+		}
+		switch q.Period {
+		case QuestOnce, QuestDay:
+		default:
+			return fmt.Errorf("quest %q: period must be once or day", key)
+		}
+		if q.Bonus < 0 {
+			return fmt.Errorf("quest %q: bonus can't be negative", key)
+		}
+	}
+	return nil
+}
+
+// questPeriod - период квеста для дня пользователя today
+func questPeriod(q config.Quest, today time.Time) time.Time {
+	if q.Period == QuestDay {
+		return today
+	}
+	return questOnce
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+)
+
+type QuestService struct {
+	store QuestStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewQuestService(store QuestStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *QuestService {
+	return &QuestService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Has - задание входит хотя бы в один квест
+func (s QuestService) Has(task string) bool {
+	for _, q := range s.cfg.Quests {
+		if slices.Contains(q.Steps, task) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check - для строгих квестов задание засчитывается, только если предыдущие шаги уже выполнены за период
+func (s QuestService) Check(ctx context.Context, id UserID, task string, today time.Time) error {
+	periods := s.periods(task, today, true)
+	if len(periods) == 0 {
+		return nil
+	}
+	progress, err := s.store.GetQuestProgress(ctx, id, periods)
+	if err != nil {
+		return err
+	}
+	for key := range periods {
+		if step := slices.Index(s.cfg.Quests[key].Steps, task); progress[key].Step < step {
+			return fmt.Errorf("%w: %s in quest %s", ErrQuestPrerequisite, s.cfg.Quests[key].Steps[progress[key].Step], key)
+		}
+	}
+	return nil
+}
+
+// Advance отмечает task выполненным в квестах с этим заданием и возвращает бонусы за пройденные квесты.
+// В строгих квестах шаг засчитывается только следующим по порядку, в нестрогих - в любом порядке
+func (s QuestService) Advance(ctx context.Context, id UserID, task string, today time.Time) ([]Award, error) {
+	const op = "QuestService.Advance"
+	periods := s.periods(task, today, false)
+	if len(periods) == 0 {
+		return nil, nil
+	}
+	progress, err := s.store.GetQuestProgress(ctx, id, periods)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return nil, err
+	}
+	var awards []Award
+	for key, period := range periods {
+		q := s.cfg.Quests[key]
+		step := slices.Index(q.Steps, task)
+		if progress[key].StepDone(step) || (q.Strict && progress[key].Step != step) {
+			continue
+		}
+		now := s.cl.Now()
+		//какой шаг последний, решает хранилище: нестрогие шаги могут засчитываться параллельно
+		var award *Award
+		completed := false
+		_, err := s.store.AdvanceQuest(ctx, id, key, period, step, len(q.Steps), now, func() (*Award, []Event) {
+			completed = true
+			events := []Event{NewEvent(EventQuestCompleted, now, map[string]any{"user_id": id, "quest": key, "bonus": q.Bonus})}
+			if q.Bonus > 0 {
+				award = &Award{UserID: id, Points: q.Bonus, Reason: "quest:" + key, At: now}
+				events = append(events, PointsAdjustedEvent(*award))
+			}
+			return award, events
+		})
+		if err != nil {
+			s.log.Error(op, "user_id", id, "quest", key, "error", err)
+			return awards, err
+		}
+		if completed {
+			s.log.Info(op+": quest completed", "user_id", id, "quest", key)
+			if award != nil {
+				awards = append(awards, *award)
+			}
+		}
+	}
+	return awards, nil
+}
+
+// Progress - все квесты с прогрессом пользователя в текущем периоде, по ключу
+func (s QuestService) Progress(ctx context.Context, id UserID, today time.Time) ([]QuestStatus, error) {
+	periods := make(map[string]time.Time, len(s.cfg.Quests))
+	for key, q := range s.cfg.Quests {
+		periods[key] = questPeriod(q, today)
+	}
+	progress, err := s.store.GetQuestProgress(ctx, id, periods)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]QuestStatus, 0, len(s.cfg.Quests))
+	for key, q := range s.cfg.Quests {
+		status := QuestStatus{Key: key, Quest: q, Step: progress[key].Step, Done: []string{}, Completed: progress[key].CompletedAt != nil}
+		for i, task := range q.Steps {
+			if progress[key].StepDone(i) {
+				status.Done = append(status.Done, task)
+			} else if status.Next == "" {
+				status.Next = task
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses, nil
+}
+
+// periods - квесты с заданием task (только строгие, если strict) и их текущие периоды
+func (s QuestService) periods(task string, today time.Time, strict bool) map[string]time.Time {
+	periods := make(map[string]time.Time)
+	for key, q := range s.cfg.Quests {
+		if slices.Contains(q.Steps, task) && (q.Strict || !strict) {
+			periods[key] = questPeriod(q, today)
+		}
+	}
+	return periods
+}
@@ -0,0 +1,118 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memQuests - прогресс квестов в памяти, шаги отмечаются битами как в хранилище
+type memQuests struct {
+	progress map[string]domain.QuestProgress
+	awards   []domain.Award
+}
+
+func (m *memQuests) GetQuestProgress(ctx context.Context, id domain.UserID, periods map[string]time.Time) (map[string]domain.QuestProgress, error) {
+	progress := map[string]domain.QuestProgress{}
+	for quest, period := range periods {
+		if p, ok := m.progress[quest]; ok && p.Period.Equal(period) {
+			progress[quest] = p
+		}
+	}
+	return progress, nil
+}
+
+func (m *memQuests) AdvanceQuest(ctx context.Context, id domain.UserID, quest string, period time.Time, step int, total int, at time.Time, complete func() (*domain.Award, []domain.Event)) (bool, error) {
+	p := m.progress[quest]
+	if !p.Period.Equal(period) {
+		p = domain.QuestProgress{Quest: quest, Period: period}
+	}
+	if p.StepDone(step) {
+		return false, nil
+	}
+	p.Done |= 1 << step
+	p.Step++
+	if p.Step == total {
+		p.CompletedAt = &at
+		if award, _ := complete(); award != nil {
+			m.awards = append(m.awards, *award)
+		}
+	}
+	m.progress[quest] = p
+	return true, nil
+}
+
+func newQuestService(strict bool) (*domain.QuestService, *memQuests) {
+	cfg := &config.Config{Quests: map[string]config.Quest{"morning": {
+		Steps: []string{"wake_in_time", "morning_exercise", "10k_daily_steps"}, Period: domain.QuestDay, Strict: strict, Bonus: 5,
+	}}}
+	store := &memQuests{progress: map[string]domain.QuestProgress{}}
+	cl := &pkg.StubClock{Time: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	return domain.NewQuestService(store, discardLog(), cfg, cl), store
+}
+
+func TestQuestNonStrictAnyOrder(t *testing.T) {
+	ctx := context.Background()
+	s, store := newQuestService(false)
+	today := date(2026, 10, 19)
+	for _, task := range []string{"10k_daily_steps", "wake_in_time", "10k_daily_steps"} {
+		if err := s.Check(ctx, 1, task, today); err != nil {
+			t.Fatalf("Check(%s) = %v, non-strict quest has no prerequisites", task, err)
+		}
+		if awards, err := s.Advance(ctx, 1, task, today); err != nil || len(awards) != 0 {
+			t.Fatalf("Advance(%s) = %v, %v, want no bonus yet", task, awards, err)
+		}
+	}
+	statuses, err := s.Progress(ctx, 1, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := statuses[0]; st.Step != 2 || st.Next != "morning_exercise" || !slices.Equal(st.Done, []string{"wake_in_time", "10k_daily_steps"}) {
+		t.Fatalf("progress %+v, want wake_in_time and 10k_daily_steps done, morning_exercise next", st)
+	}
+	awards, err := s.Advance(ctx, 1, "morning_exercise", today)
+	if err != nil || len(awards) != 1 || awards[0].Points != 5 || awards[0].Reason != "quest:morning" {
+		t.Fatalf("Advance(last step) = %+v, %v, want bonus 5", awards, err)
+	}
+	//квест уже пройден, повтор шага бонус не начисляет
+	if awards, _ := s.Advance(ctx, 1, "morning_exercise", today); len(awards) != 0 || len(store.awards) != 1 {
+		t.Fatalf("repeated step awarded %+v", awards)
+	}
+	//ежедневный квест на следующий день проходится заново
+	if _, err := s.Advance(ctx, 1, "morning_exercise", today.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if p := store.progress["morning"]; p.Step != 1 || !p.StepDone(1) {
+		t.Fatalf("next day progress %+v, want only morning_exercise done", p)
+	}
+}
+
+func TestQuestStrictOrder(t *testing.T) {
+	ctx := context.Background()
+	s, store := newQuestService(true)
+	today := date(2026, 10, 19)
+	if err := s.Check(ctx, 1, "morning_exercise", today); !errors.Is(err, domain.ErrQuestPrerequisite) {
+		t.Fatalf("Check(second step first) = %v, want ErrQuestPrerequisite", err)
+	}
+	//шаг не по порядку не засчитывается, даже если задание выполнено в обход Check
+	if _, err := s.Advance(ctx, 1, "morning_exercise", today); err != nil || store.progress["morning"].Step != 0 {
+		t.Fatalf("out of order step counted: %+v, %v", store.progress["morning"], err)
+	}
+	for _, task := range []string{"wake_in_time", "morning_exercise"} {
+		if err := s.Check(ctx, 1, task, today); err != nil {
+			t.Fatalf("Check(%s) = %v", task, err)
+		}
+		if _, err := s.Advance(ctx, 1, task, today); err != nil {
+			t.Fatal(err)
+		}
+	}
+	awards, err := s.Advance(ctx, 1, "10k_daily_steps", today)
+	if err != nil || len(awards) != 1 || awards[0].Points != 5 {
+		t.Fatalf("Advance(last step) = %+v, %v, want bonus 5", awards, err)
+	}
+}
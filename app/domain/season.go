@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SeasonID int64
+
+// Season - сезон длиной в квартал, после закрытия (ClosedAt != nil) его итоговая таблица заморожена
+type Season struct {
+	ID       SeasonID   `db:"id"`
+	Name     string     `db:"name"`
+	StartsAt time.Time  `db:"starts_at"`
+	EndsAt   time.Time  `db:"ends_at"`
+	ClosedAt *time.Time `db:"closed_at"`
+}
+
+// SeasonStanding - место пользователя в итоговой таблице закрытого сезона
+type SeasonStanding struct {
+	SeasonID SeasonID  `db:"season_id"`
+	UserID   UserID    `db:"user_id"`
+	Nickname Nickname  `db:"nickname"`
+	Rank     int64     `db:"rank"`
+	Score    UserScore `db:"score"`
+	Prize    int       `db:"prize"`
+}
+
+var ErrSeasonNotFound = errors.New("Season not found")
+var ErrSeasonNotClosed = errors.New("Season is not finished yet")
+
+type SeasonStore interface {
+	// CreateSeason создаёт сезон, если сезона с таким началом ещё нет
+	CreateSeason(ctx context.Context, season Season) error
+	GetSeason(ctx context.Context, id SeasonID) (Season, error)
+	ListSeasons(ctx context.Context) ([]Season, error)
+	// DueSeasons - незакрытые сезоны, которые закончились к моменту now
+	DueSeasons(ctx context.Context, now time.Time) ([]Season, error)
+	// CloseSeason архивирует итоговую таблицу по очкам, заработанным в пределах сезона, распределяет призы
+	// и оставляет в очках текущего сезона только заработанное после его конца.
+	// Возвращает false, если сезон уже закрыт (например другой репликой)
+	CloseSeason(ctx context.Context, id SeasonID, closedAt time.Time, ranking string, prizes []int) (bool, error)
+	GetStandings(ctx context.Context, id SeasonID, page int, size int) ([]SeasonStanding, error)
+}
+
+// QuarterSeason - сезон, в который попадает момент now (кварталы считаются в часовом поясе loc)
+func QuarterSeason(now time.Time, loc *time.Location) Season {
+	now = now.In(loc)
+	quarter := (int(now.Month()) - 1) / 3
+	start := time.Date(now.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, loc)
+	return Season{
+		Name:     fmt.Sprintf("%d Q%d", now.Year(), quarter+1),
+		StartsAt: start,
+		EndsAt:   start.AddDate(0, 3, 0),
+	}
+}
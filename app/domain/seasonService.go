@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type SeasonService struct {
+	store SeasonStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewSeasonService(store SeasonStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *SeasonService {
+	return &SeasonService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+func (s SeasonService) List(ctx context.Context) ([]Season, error) {
+	const op = "SeasonService.List"
+	seasons, err := s.store.ListSeasons(ctx)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return nil, err
+	}
+	return seasons, nil
+}
+
+// Standings - итоговая таблица сезона, доступна только после его закрытия
+func (s SeasonService) Standings(ctx context.Context, id SeasonID, page int, size int) ([]SeasonStanding, error) {
+	const op = "SeasonService.Standings"
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, ErrInvalidLeaderboardQuery
+	}
+	season, err := s.store.GetSeason(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if season.ClosedAt == nil {
+		return nil, ErrSeasonNotClosed
+	}
+	standings, err := s.store.GetStandings(ctx, id, page, size)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return nil, err
+	}
+	return standings, nil
+}
+
+// Tick закрывает закончившиеся сезоны и заводит текущий, если его ещё нет.
+// Закрытие идемпотентно на уровне бд, поэтому Tick можно запускать на нескольких репликах одновременно
+func (s SeasonService) Tick(ctx context.Context) error {
+	const op = "SeasonService.Tick"
+	now := s.cl.Now()
+	due, err := s.store.DueSeasons(ctx, now)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return err
+	}
+	for _, season := range due {
+		closed, err := s.store.CloseSeason(ctx, season.ID, now, rankingMode(s.cfg), s.cfg.Seasons.Prizes)
+		if err != nil {
+			s.log.Error(op, "season_id", season.ID, "error", err)
+			return err
+		}
+		if closed {
+			s.log.Info(op+": season closed", "season_id", season.ID, "name", season.Name)
+		}
+	}
+	loc, err := time.LoadLocation(s.cfg.Leaderboard.Timezone)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return err
+	}
+	return s.store.CreateSeason(ctx, QuarterSeason(now, loc))
+}
+
+// Run - планировщик сезонов, вызывает Tick раз в interval до отмены ctx
+func (s SeasonService) Run(ctx context.Context, interval time.Duration) {
+	const op = "SeasonService.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx); err != nil {
+			s.log.Error(op, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
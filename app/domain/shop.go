@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ShopItemID int64
+type OrderID int64
+
+const (
+	OrderPending   = "pending"
+	OrderFulfilled = "fulfilled"
+	OrderCancelled = "cancelled"
+)
+
+const (
+	EventOrderPlaced    = "shop.order_placed"
+	EventOrderFulfilled = "shop.order_fulfilled"
+	EventOrderCancelled = "shop.order_cancelled"
+)
+
+// ShopItem - товар магазина за очки. Stock и PerUserLimit nil - без ограничений,
+// StartsAt и EndsAt задают окно продаж, nil - без границы
+type ShopItem struct {
+	ID           ShopItemID `db:"id"`
+	Name         string     `db:"name"`
+	Description  string     `db:"description"`
+	Cost         int        `db:"cost"`
+	Stock        *int       `db:"stock"`
+	PerUserLimit *int       `db:"per_user_limit"`
+	StartsAt     *time.Time `db:"starts_at"`
+	EndsAt       *time.Time `db:"ends_at"`
+	Active       bool       `db:"active"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// Available - товар включён и сейчас идёт окно продаж (остаток проверяется отдельно)
+func (i ShopItem) Available(at time.Time) bool {
+	if !i.Active {
+		return false
+	}
+	if i.StartsAt != nil && at.Before(*i.StartsAt) {
+		return false
+	}
+	return i.EndsAt == nil || at.Before(*i.EndsAt)
+}
+
+// Order - заказ товара, Cost - списанные очки за весь заказ, при отмене возвращаются
+type Order struct {
+	ID        OrderID    `db:"id"`
+	UserID    UserID     `db:"user_id"`
+	ItemID    ShopItemID `db:"item_id"`
+	Quantity  int        `db:"quantity"`
+	Cost      int        `db:"cost"`
+	Status    string     `db:"status"`
+	Comment   *string    `db:"comment"` // причина отмены
+	CreatedAt time.Time  `db:"created_at"`
+	ClosedAt  *time.Time `db:"closed_at"` // время выдачи или отмены
+}
+
+// OrderFilter - фильтр списка заказов, пустые поля не фильтруют
+type OrderFilter struct {
+	UserID *UserID
+	Status string
+}
+
+var ErrInvalidShopItem = errors.New("Invalid shop item")
+var ErrShopItemNotFound = errors.New("Shop item not found")
+var ErrShopItemUnavailable = errors.New("Shop item is not available now")
+var ErrOutOfStock = errors.New("Shop item is out of stock")
+var ErrPurchaseLimit = errors.New("Purchase limit for this item is reached")
+var ErrInsufficientPoints = errors.New("Not enough points")
+var ErrInvalidOrder = errors.New("Invalid order")
+var ErrOrderNotFound = errors.New("Order not found")
+var ErrOrderClosed = errors.New("Order is already fulfilled or cancelled")
+
+type ShopStore interface {
+	AddShopItem(ctx context.Context, item ShopItem) (ShopItem, error)
+	UpdateShopItem(ctx context.Context, item ShopItem) (ShopItem, error)
+	// ListShopItems - все товары, или только доступные на момент at, если available
+	ListShopItems(ctx context.Context, available bool, at time.Time) ([]ShopItem, error)
+	// PlaceOrder блокирует товар, создаёт заказ с ценой по текущей стоимости товара и вызывает place с товаром,
+	// количеством уже купленного пользователем и заказом. Затем в той же транзакции уменьшает остаток
+	// (ErrOutOfStock), списывает очки (ErrInsufficientPoints) и пишет события. Ошибка place отменяет заказ
+	PlaceOrder(ctx context.Context, order Order, place func(item ShopItem, bought int, order Order) (Award, []Event, error)) (Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter, page int, size int) ([]Order, error)
+	// CloseOrder переводит заказ из pending в status (иначе ErrOrderClosed), при отмене возвращает остаток товара.
+	// close вызывается с закрытым заказом, его возврат (если не nil) и события пишутся в той же транзакции
+	CloseOrder(ctx context.Context, id OrderID, status string, comment *string, at time.Time, close func(order Order) (*Award, []Event)) (Order, error)
+}
+
+// Validate проверяет товар перед сохранением
+func (i ShopItem) Validate() error {
+	switch {
+	case i.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidShopItem)
+	case i.Cost <= 0:
+		return fmt.Errorf("%w: cost must be positive", ErrInvalidShopItem)
+	case i.Stock != nil && *i.Stock < 0:
+		return fmt.Errorf("%w: stock can't be negative", ErrInvalidShopItem)
+	case i.PerUserLimit != nil && *i.PerUserLimit <= 0:
+		return fmt.Errorf("%w: per_user_limit must be positive", ErrInvalidShopItem)
+	case i.StartsAt != nil && i.EndsAt != nil && !i.EndsAt.After(*i.StartsAt):
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidShopItem)
+	}
+	return nil
+}
+
+func orderEvent(eventType string, order Order, at time.Time) Event {
+	data := map[string]any{"order_id": order.ID, "user_id": order.UserID, "item_id": order.ItemID,
+		"quantity": order.Quantity, "cost": order.Cost}
+	if order.Comment != nil {
+		data["comment"] = *order.Comment
+	}
+	return NewEvent(eventType, at, data)
+}
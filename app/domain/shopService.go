@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+type ShopService struct {
+	store ShopStore
+	users *UserService // для обновления кэша лидерборды и уведомлений после списания и возврата очков
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewShopService(store ShopStore, users *UserService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *ShopService {
+	return &ShopService{
+		store: store,
+		users: users,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Items - каталог: для пользователей только доступные сейчас товары, для администраторов все
+func (s ShopService) Items(ctx context.Context, all bool) ([]ShopItem, error) {
+	return s.store.ListShopItems(ctx, !all, s.cl.Now())
+}
+
+func (s ShopService) AddItem(ctx context.Context, item ShopItem) (ShopItem, error) {
+	const op = "ShopService.AddItem"
+	if err := item.Validate(); err != nil {
+		return ShopItem{}, err
+	}
+	item.CreatedAt = s.cl.Now()
+	created, err := s.store.AddShopItem(ctx, item)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return ShopItem{}, err
+	}
+	s.log.Info(op+": shop item created", "item_id", created.ID, "cost", created.Cost)
+	return created, nil
+}
+
+// UpdateItem заменяет товар целиком, цена уже оформленных заказов не меняется
+func (s ShopService) UpdateItem(ctx context.Context, item ShopItem) (ShopItem, error) {
+	const op = "ShopService.UpdateItem"
+	if err := item.Validate(); err != nil {
+		return ShopItem{}, err
+	}
+	updated, err := s.store.UpdateShopItem(ctx, item)
+	if err != nil {
+		s.log.Error(op, "item_id", item.ID, "error", err)
+		return ShopItem{}, err
+	}
+	s.log.Info(op+": shop item updated", "item_id", updated.ID)
+	return updated, nil
+}
+
+// Order оформляет заказ: списывает очки и уменьшает остаток одной транзакцией, баланс не уходит ниже нуля
+func (s ShopService) Order(ctx context.Context, id UserID, itemID ShopItemID, quantity int) (Order, error) {
+	const op = "ShopService.Order"
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity < 0 || quantity > s.cfg.Shop.MaxQuantity {
+		return Order{}, fmt.Errorf("%w: quantity must be between 1 and %d", ErrInvalidOrder, s.cfg.Shop.MaxQuantity)
+	}
+	now := s.cl.Now()
+	var debit Award
+	order, err := s.store.PlaceOrder(ctx, Order{UserID: id, ItemID: itemID, Quantity: quantity, Status: OrderPending, CreatedAt: now},
+		func(item ShopItem, bought int, order Order) (Award, []Event, error) {
+			if !item.Available(now) {
+				return Award{}, nil, ErrShopItemUnavailable
+			}
+			if item.PerUserLimit != nil && bought+quantity > *item.PerUserLimit {
+				return Award{}, nil, fmt.Errorf("%w: %d of %d already bought", ErrPurchaseLimit, bought, *item.PerUserLimit)
+			}
+			debit = Award{UserID: id, Points: -order.Cost, Reason: fmt.Sprintf("shop_order:%d", order.ID), At: now, BalanceOnly: true}
+			return debit, []Event{orderEvent(EventOrderPlaced, order, now), PointsAdjustedEvent(debit)}, nil
+		})
+	if err != nil {
+		s.log.Error(op, "user_id", id, "item_id", itemID, "error", err)
+		return Order{}, err
+	}
+	if s.users != nil {
+		s.users.pointsChanged(ctx, debit)
+	}
+	s.log.Info(op+": order placed", "order_id", order.ID, "user_id", id, "item_id", itemID, "cost", order.Cost)
+	return order, nil
+}
+
+// Orders - заказы с фильтром, история пользователя и очередь выдачи для администраторов
+func (s ShopService) Orders(ctx context.Context, filter OrderFilter, page int, size int) ([]Order, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidOrder, MaxLeaderboardSize)
+	}
+	switch filter.Status {
+	case "", OrderPending, OrderFulfilled, OrderCancelled:
+	default:
+		return nil, fmt.Errorf("%w: status must be one of pending, fulfilled, cancelled", ErrInvalidOrder)
+	}
+	return s.store.ListOrders(ctx, filter, page, size)
+}
+
+// Fulfil отмечает заказ выданным
+func (s ShopService) Fulfil(ctx context.Context, id OrderID) (Order, error) {
+	const op = "ShopService.Fulfil"
+	now := s.cl.Now()
+	order, err := s.store.CloseOrder(ctx, id, OrderFulfilled, nil, now, func(order Order) (*Award, []Event) {
+		return nil, []Event{orderEvent(EventOrderFulfilled, order, now)}
+	})
+	if err != nil {
+		s.log.Error(op, "order_id", id, "error", err)
+		return Order{}, err
+	}
+	s.log.Info(op+": order fulfilled", "order_id", id)
+	return order, nil
+}
+
+// Cancel отменяет заказ, возвращает очки пользователю и товар на склад, comment - причина, её увидит пользователь
+func (s ShopService) Cancel(ctx context.Context, id OrderID, comment string) (Order, error) {
+	const op = "ShopService.Cancel"
+	var c *string
+	if comment != "" {
+		c = &comment
+	}
+	now := s.cl.Now()
+	var refund Award
+	order, err := s.store.CloseOrder(ctx, id, OrderCancelled, c, now, func(order Order) (*Award, []Event) {
+		refund = Award{UserID: order.UserID, Points: order.Cost, Reason: fmt.Sprintf("shop_refund:%d", order.ID), At: now, BalanceOnly: true,
+			Restores: &DebitRef{UserID: order.UserID, Reason: fmt.Sprintf("shop_order:%d", order.ID)}}
+		return &refund, []Event{orderEvent(EventOrderCancelled, order, now), PointsAdjustedEvent(refund)}
+	})
+	if err != nil {
+		s.log.Error(op, "order_id", id, "error", err)
+		return Order{}, err
+	}
+	if s.users != nil {
+		s.users.pointsChanged(ctx, refund)
+	}
+	s.log.Info(op+": order cancelled", "order_id", id, "refund", order.Cost)
+	return order, nil
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"math"
+	"time"
+)
+
+// Streak - серия дней подряд, в которые пользователь выполнял задание. Дни считаются в часовом поясе
+// пользователя и хранятся как даты (полночь UTC)
+type Streak struct {
+	Task    string     `db:"task"`
+	Current int        `db:"current"`
+	Longest int        `db:"longest"`
+	LastDay *time.Time `db:"last_day"`
+}
+
+// Day - календарный день момента t в часовом поясе loc
+func Day(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Advance - выполнение задания в day. Повтор в тот же день серию не меняет (второе значение false),
+// следующий день продлевает серию, пропуск хотя бы одного дня начинает серию заново
+func (s Streak) Advance(day time.Time) (Streak, bool) {
+	switch {
+	case s.LastDay != nil && !day.After(*s.LastDay):
+		return s, false
+	case s.LastDay != nil && s.LastDay.AddDate(0, 0, 1).Equal(day):
+		s.Current++
+	default:
+		s.Current = 1
+	}
+	s.Longest = max(s.Longest, s.Current)
+	s.LastDay = &day
+	return s, true
+}
+
+// Actual - серия на день today: если вчера и сегодня задание не выполнялось, текущая серия уже прервана
+func (s Streak) Actual(today time.Time) Streak {
+	if s.LastDay == nil || s.LastDay.AddDate(0, 0, 1).Before(today) {
+		s.Current = 0
+	}
+	return s
+}
+
+// streakPoints - очки за задание с учётом бонуса за серию: действует бонус с наибольшим Days, не превышающим серию
+func streakPoints(points int, current int, bonuses []config.StreakBonus) int {
+	multiplier := 1.0
+	best := 0
+	for _, b := range bonuses {
+		if b.Days <= current && b.Days > best {
+			best = b.Days
+			multiplier = b.Multiplier
+		}
+	}
+	return int(math.Round(float64(points) * multiplier))
+}
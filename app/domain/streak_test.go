@@ -0,0 +1,156 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStreakAdvance(t *testing.T) {
+	last := date(2026, 10, 19)
+	tests := []struct {
+		name    string
+		streak  domain.Streak
+		day     time.Time
+		current int
+		longest int
+		newDay  bool
+	}{
+		{"first day", domain.Streak{}, last, 1, 1, true},
+		{"next day", domain.Streak{Current: 3, Longest: 3, LastDay: &last}, date(2026, 10, 20), 4, 4, true},
+		{"same day repeat", domain.Streak{Current: 3, Longest: 5, LastDay: &last}, last, 3, 5, false},
+		{"earlier day", domain.Streak{Current: 3, Longest: 5, LastDay: &last}, date(2026, 10, 18), 3, 5, false},
+		{"missed day resets", domain.Streak{Current: 3, Longest: 5, LastDay: &last}, date(2026, 10, 21), 1, 5, true},
+		{"next day across month", domain.Streak{Current: 1, Longest: 1, LastDay: ptr(date(2026, 10, 31))}, date(2026, 11, 1), 2, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, newDay := tt.streak.Advance(tt.day)
+			if got.Current != tt.current || got.Longest != tt.longest || newDay != tt.newDay {
+				t.Fatalf("Advance() = %d/%d, %v, want %d/%d, %v", got.Current, got.Longest, newDay, tt.current, tt.longest, tt.newDay)
+			}
+			if newDay && !got.LastDay.Equal(tt.day) {
+				t.Fatalf("last day %v, want %v", got.LastDay, tt.day)
+			}
+		})
+	}
+}
+
+func TestStreakActual(t *testing.T) {
+	last := date(2026, 10, 19)
+	streak := domain.Streak{Current: 4, Longest: 6, LastDay: &last}
+	tests := []struct {
+		name    string
+		streak  domain.Streak
+		today   time.Time
+		current int
+	}{
+		{"done today", streak, last, 4},
+		//сегодня ещё можно продлить серию
+		{"done yesterday", streak, date(2026, 10, 20), 4},
+		{"missed yesterday", streak, date(2026, 10, 21), 0},
+		{"never done", domain.Streak{}, last, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.streak.Actual(tt.today)
+			if got.Current != tt.current || got.Longest != tt.streak.Longest {
+				t.Fatalf("Actual() = %d/%d, want %d/%d", got.Current, got.Longest, tt.current, tt.streak.Longest)
+			}
+		})
+	}
+}
+
+func TestDay(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//15:30 UTC - уже следующий день в Токио (UTC+9)
+	at := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+	if got := domain.Day(at, tokyo); !got.Equal(date(2026, 10, 20)) {
+		t.Fatalf("Day() in Tokyo = %v, want 2026-10-20", got)
+	}
+	if got := domain.Day(at, time.UTC); !got.Equal(date(2026, 10, 19)) {
+		t.Fatalf("Day() in UTC = %v, want 2026-10-19", got)
+	}
+}
+
+// memStreaks - серии и начисления в памяти, серия продлевается как в хранилище
+type memStreaks struct {
+	domain.UserStore
+	timezone string
+	streak   domain.Streak
+	awards   []domain.Award
+}
+
+func (m *memStreaks) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	return domain.User{ID: id, Timezone: &m.timezone}, nil
+}
+
+func (m *memStreaks) CompleteStreakTask(ctx context.Context, id domain.UserID, task string, day time.Time, complete func(streak domain.Streak, newDay bool) (domain.Award, []domain.Event)) (domain.Streak, domain.Award, error) {
+	streak, newDay := m.streak.Advance(day)
+	m.streak = streak
+	award, _ := complete(streak, newDay)
+	m.awards = append(m.awards, award)
+	return streak, award, nil
+}
+
+func (m *memStreaks) GetStreaks(ctx context.Context, id domain.UserID) ([]domain.Streak, error) {
+	return []domain.Streak{m.streak}, nil
+}
+
+func TestStreakTaskInUserTimezone(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Rewards: map[string]int{"morning_exercise": 10}}
+	cfg.Leaderboard.Timezone = "UTC"
+	//бонус с наибольшим подходящим Days: 2 дня - x1.5, 3 дня - x2
+	cfg.Streaks.Tasks = map[string]config.StreakTask{"morning_exercise": {Bonuses: []config.StreakBonus{{Days: 3, Multiplier: 2}, {Days: 2, Multiplier: 1.5}}}}
+	store := &memStreaks{timezone: "Asia/Tokyo", streak: domain.Streak{Task: "morning_exercise"}}
+	//23:30 по Токио 19 октября
+	cl := &pkg.StubClock{Time: time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)}
+	users := domain.NewUserService(store, discardLog(), cfg, cl, nil, nil, nil, nil, nil, nil, nil)
+	steps := []struct {
+		after   time.Duration
+		current int
+		points  int
+	}{
+		{0, 1, 10},
+		//00:30 по Токио - новый день пользователя, хотя в UTC ещё 19 октября
+		{time.Hour, 2, 15},
+		//повтор в тот же день: серия та же, бонус за серию не начисляется
+		{12 * time.Hour, 2, 10},
+		{24 * time.Hour, 3, 20},
+		//пропуск дня начинает серию заново
+		{48 * time.Hour, 1, 10},
+	}
+	for i, step := range steps {
+		cl.Time = cl.Time.Add(step.after)
+		if err := users.TaskComplete(ctx, 1, "morning_exercise", ""); err != nil {
+			t.Fatal(err)
+		}
+		if store.streak.Current != step.current || store.awards[i].Points != step.points {
+			t.Fatalf("step %d at %v: streak %d, points %d, want %d and %d", i, cl.Time, store.streak.Current, store.awards[i].Points, step.current, step.points)
+		}
+	}
+	if store.streak.Longest != 3 {
+		t.Fatalf("longest streak %d, want 3", store.streak.Longest)
+	}
+	//через два дня по Токио серия уже прервана
+	cl.Time = cl.Time.Add(48 * time.Hour)
+	streaks, err := users.Streaks(ctx, 1)
+	if err != nil || streaks[0].Current != 0 || streaks[0].Longest != 3 {
+		t.Fatalf("Streaks() = %+v, %v, want current 0 and longest 3", streaks, err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
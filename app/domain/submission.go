@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+type SubmissionID int64
+
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+const VerifierProof = "proof"
+
+// Submission - заявка о выполнении задания с доказательством (ссылка и/или загруженный файл), проверяется модератором.
+// Points - награда на момент подачи заявки, начисляется только при одобрении
+type Submission struct {
+	ID               SubmissionID `db:"id"`
+	UserID           UserID       `db:"user_id"`
+	Task             string       `db:"task"`
+	Points           int          `db:"points"`     // с множителем бонусных событий, идущих при подаче заявки
+	Multiplier       float64      `db:"multiplier"` // множитель бонусных событий, 1 - без множителя
+	ProofURL         *string      `db:"proof_url"`
+	ProofKey         *string      `db:"proof_key"` // ключ файла в хранилище доказательств
+	ProofContentType *string      `db:"proof_content_type"`
+	Status           string       `db:"status"`
+	Comment          *string      `db:"comment"` // комментарий модератора (причина отказа)
+	CreatedAt        time.Time    `db:"created_at"`
+	ReviewedAt       *time.Time   `db:"reviewed_at"`
+	ReviewedBy       *UserID      `db:"reviewed_by"`
+}
+
+// SubmissionFilter - фильтр списка заявок, пустые поля не фильтруют
+type SubmissionFilter struct {
+	UserID *UserID
+	Status string
+	Task   string
+}
+
+// Proof - файл доказательства, размер должен быть известен заранее (S3 требует Content-Length)
+type Proof struct {
+	Body        io.Reader
+	Size        int64
+	ContentType string
+}
+
+var ErrProofRequired = errors.New("Task requires proof, submit it for moderation")
+var ErrInvalidSubmission = errors.New("Invalid submission")
+var ErrSubmissionNotFound = errors.New("Submission not found")
+var ErrSubmissionPending = errors.New("Submission for this task is already waiting for review")
+var ErrSubmissionReviewed = errors.New("Submission is already reviewed")
+
+type SubmissionStore interface {
+	// AddSubmission возвращает ErrSubmissionPending, если у пользователя уже есть непроверенная заявка на это задание
+	AddSubmission(ctx context.Context, sub Submission) (Submission, error)
+	GetSubmission(ctx context.Context, id SubmissionID) (Submission, error)
+	ListSubmissions(ctx context.Context, filter SubmissionFilter, page int, size int) ([]Submission, error)
+	// ReviewSubmission меняет статус непроверенной заявки (иначе ErrSubmissionReviewed) и, если award не nil,
+	// начисляет очки и пишет события в одной транзакции
+	ReviewSubmission(ctx context.Context, id SubmissionID, status string, reviewer UserID, comment *string, at time.Time, award *Award, events ...Event) (Submission, error)
+}
+
+// ProofStore - хранилище файлов доказательств (локальный диск, S3-совместимое хранилище)
+type ProofStore interface {
+	Put(ctx context.Context, key string, proof Proof) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет файл, отсутствующий файл - не ошибка
+	Delete(ctx context.Context, key string) error
+}
+
+// ProofRequired - проверяющий для заданий, которые засчитываются только через модерацию заявки
+type ProofRequired struct{}
+
+func (ProofRequired) Verify(ctx context.Context, claim TaskClaim) error {
+	return ErrProofRequired
+}
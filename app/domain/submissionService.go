@@ -0,0 +1,220 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const EventSubmissionReviewed = "submission.reviewed"
+
+// допустимые типы файлов доказательств и расширения, с которыми они сохраняются
+var proofTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+type SubmissionService struct {
+	store  SubmissionStore
+	proofs ProofStore
+	users  *UserService // для обновления кэша лидерборды и уведомлений после начисления очков
+	log    *slog.Logger
+	cfg    *config.Config
+	cl     pkg.Clock
+}
+
+func NewSubmissionService(store SubmissionStore, proofs ProofStore, users *UserService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *SubmissionService {
+	return &SubmissionService{
+		store:  store,
+		proofs: proofs,
+		users:  users,
+		log:    log,
+		cfg:    cfg,
+		cl:     cl,
+	}
+}
+
+// Submit создаёт заявку на проверку. Нужна ссылка или файл (proof может быть nil), можно и то и другое
+func (s SubmissionService) Submit(ctx context.Context, id UserID, task string, proofURL string, proof *Proof) (Submission, error) {
+	const op = "SubmissionService.Submit"
+	points, ok := s.cfg.Rewards[task]
+	if !ok {
+		return Submission{}, ErrNotExistingReward
+	}
+	if s.cfg.Verification.Tasks[task].Verifier != VerifierProof {
+		return Submission{}, fmt.Errorf("%w: task %q doesn't require proof", ErrInvalidSubmission, task)
+	}
+	if proofURL == "" && proof == nil {
+		return Submission{}, fmt.Errorf("%w: url or file is required", ErrInvalidSubmission)
+	}
+	//множитель бонусных событий фиксируется при подаче: задание выполнено во время события, даже если одобрят позже
+	multiplier := 1.0
+	if s.users != nil {
+		if err := s.users.requireVerified(ctx, id); err != nil {
+			return Submission{}, err
+		}
+		var err error
+		if multiplier, _, err = s.users.multiplier(ctx, task); err != nil {
+			s.log.Error(op, "user_id", id, "task", task, "error", err)
+			return Submission{}, err
+		}
+	}
+	sub := Submission{UserID: id, Task: task, Points: multiplyPoints(points, multiplier), Multiplier: multiplier,
+		Status: SubmissionPending, CreatedAt: s.cl.Now()}
+	if proofURL != "" {
+		u, err := url.Parse(proofURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Submission{}, fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidSubmission)
+		}
+		sub.ProofURL = &proofURL
+	}
+	//файл не загружаем, если заявка всё равно упрётся в уже ожидающую проверки
+	pending, err := s.store.ListSubmissions(ctx, SubmissionFilter{UserID: &id, Status: SubmissionPending, Task: task}, 1, 1)
+	if err != nil {
+		return Submission{}, err
+	}
+	if len(pending) > 0 {
+		return Submission{}, ErrSubmissionPending
+	}
+	if proof != nil {
+		key, contentType, err := s.saveProof(ctx, id, *proof)
+		if err != nil {
+			return Submission{}, err
+		}
+		sub.ProofKey = &key
+		sub.ProofContentType = &contentType
+	}
+	created, err := s.store.AddSubmission(ctx, sub)
+	if err != nil {
+		//параллельная заявка успела раньше или вставка упала - загруженный файл больше никому не нужен
+		if sub.ProofKey != nil {
+			if err := s.proofs.Delete(context.WithoutCancel(ctx), *sub.ProofKey); err != nil {
+				s.log.Error(op+": failed to delete orphaned proof", "key", *sub.ProofKey, "error", err)
+			}
+		}
+		if errors.Is(err, ErrSubmissionPending) {
+			return Submission{}, err
+		}
+		s.log.Error(op, "user_id", id, "error", err)
+		return Submission{}, err
+	}
+	s.log.Info(op+": submission created", "submission_id", created.ID, "user_id", id, "task", task)
+	return created, nil
+}
+
+// saveProof проверяет размер и тип файла по содержимому и кладёт его в хранилище
+func (s SubmissionService) saveProof(ctx context.Context, id UserID, proof Proof) (string, string, error) {
+	maxSize := s.cfg.Submissions.MaxProofSize
+	if proof.Size <= 0 || proof.Size > maxSize {
+		return "", "", fmt.Errorf("%w: file must be non-empty and not larger than %d bytes", ErrInvalidSubmission, maxSize)
+	}
+	body := bufio.NewReaderSize(io.LimitReader(proof.Body, proof.Size), 512)
+	head, err := body.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+	contentType := strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0])
+	ext, ok := proofTypes[contentType]
+	if !ok {
+		return "", "", fmt.Errorf("%w: unsupported file type %s", ErrInvalidSubmission, contentType)
+	}
+	key := fmt.Sprintf("proofs/%d/%s%s", id, uuid.NewString(), ext)
+	if err := s.proofs.Put(ctx, key, Proof{Body: body, Size: proof.Size, ContentType: contentType}); err != nil {
+		return "", "", err
+	}
+	return key, contentType, nil
+}
+
+// List - заявки с фильтром, для модераторов и истории пользователя
+func (s SubmissionService) List(ctx context.Context, filter SubmissionFilter, page int, size int) ([]Submission, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidSubmission, MaxLeaderboardSize)
+	}
+	switch filter.Status {
+	case "", SubmissionPending, SubmissionApproved, SubmissionRejected:
+	default:
+		return nil, fmt.Errorf("%w: status must be one of pending, approved, rejected", ErrInvalidSubmission)
+	}
+	return s.store.ListSubmissions(ctx, filter, page, size)
+}
+
+// Proof - файл доказательства заявки для модератора
+func (s SubmissionService) Proof(ctx context.Context, id SubmissionID) (io.ReadCloser, string, error) {
+	sub, err := s.store.GetSubmission(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if sub.ProofKey == nil {
+		return nil, "", fmt.Errorf("%w: submission has no file", ErrSubmissionNotFound)
+	}
+	body, err := s.proofs.Open(ctx, *sub.ProofKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, *sub.ProofContentType, nil
+}
+
+// Approve одобряет заявку и начисляет очки за задание
+func (s SubmissionService) Approve(ctx context.Context, id SubmissionID, moderator UserID) (Submission, error) {
+	return s.review(ctx, id, SubmissionApproved, moderator, nil)
+}
+
+// Reject отклоняет заявку, comment - причина, её увидит пользователь
+func (s SubmissionService) Reject(ctx context.Context, id SubmissionID, moderator UserID, comment string) (Submission, error) {
+	var c *string
+	if comment != "" {
+		c = &comment
+	}
+	return s.review(ctx, id, SubmissionRejected, moderator, c)
+}
+
+func (s SubmissionService) review(ctx context.Context, id SubmissionID, status string, moderator UserID, comment *string) (Submission, error) {
+	const op = "SubmissionService.review"
+	sub, err := s.store.GetSubmission(ctx, id)
+	if err != nil {
+		return Submission{}, err
+	}
+	now := s.cl.Now()
+	data := map[string]any{"submission_id": id, "user_id": sub.UserID, "task": sub.Task, "status": status}
+	if comment != nil {
+		data["comment"] = *comment
+	}
+	events := []Event{NewEvent(EventSubmissionReviewed, now, data)}
+	var award *Award
+	if status == SubmissionApproved {
+		award = &Award{UserID: sub.UserID, Points: sub.Points, Reason: sub.Task, At: now, Multiplier: sub.Multiplier}
+		completed := map[string]any{"user_id": sub.UserID, "task": sub.Task, "points": sub.Points}
+		if sub.Multiplier != 1 {
+			completed["multiplier"] = sub.Multiplier
+		}
+		events = append(events, NewEvent(EventTaskCompleted, now, completed), PointsAdjustedEvent(*award))
+	}
+	reviewed, err := s.store.ReviewSubmission(ctx, id, status, moderator, comment, now, award, events...)
+	if err != nil {
+		s.log.Error(op, "submission_id", id, "error", err)
+		return Submission{}, err
+	}
+	if award != nil && s.users != nil {
+		s.users.pointsChanged(ctx, *award)
+		s.users.approvedTask(ctx, sub.UserID, sub.Task, sub.CreatedAt)
+	}
+	s.log.Info(op+": submission reviewed", "submission_id", id, "status", status, "moderator", moderator)
+	return reviewed, nil
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type TeamID int64
+
+const (
+	TeamScoreSum     = "sum"     // очки команды - сумма вкладов участников
+	TeamScoreAverage = "average" // очки команды - средний вклад участника
+)
+
+// Team - команда, владелец приглашает участников и может их исключать
+type Team struct {
+	ID        TeamID    `db:"id"`
+	Name      string    `db:"name"`
+	OwnerID   UserID    `db:"owner_id"`
+	MaxSize   int       `db:"max_size"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// TeamMember - участник команды, Contribution - очки, заработанные за время в команде (списания не учитываются)
+type TeamMember struct {
+	UserID       UserID    `db:"user_id"`
+	Nickname     Nickname  `db:"nickname"`
+	Contribution int64     `db:"contribution"`
+	JoinedAt     time.Time `db:"joined_at"`
+}
+
+// TeamEntry - запись лидерборды команд
+type TeamEntry struct {
+	ID      TeamID `db:"id"`
+	Name    string `db:"name"`
+	Members int    `db:"members"`
+	Score   int64  `db:"score"`
+	Rank    int64  `db:"rank"`
+}
+
+var ErrInvalidTeam = errors.New("Invalid team")
+var ErrTeamNotFound = errors.New("Team not found")
+var ErrTeamNameTaken = errors.New("Team name is already taken")
+var ErrAlreadyInTeam = errors.New("User is already in a team")
+var ErrNotTeamMember = errors.New("User is not a member of this team")
+var ErrNotTeamOwner = errors.New("Only the team owner can do this")
+var ErrTeamFull = errors.New("Team is full")
+var ErrTeamInviteRequired = errors.New("Team can be joined only by invite")
+
+type TeamStore interface {
+	// CreateTeam создаёт команду и добавляет владельца первым участником (ErrAlreadyInTeam, ErrTeamNameTaken)
+	CreateTeam(ctx context.Context, team Team) (Team, error)
+	GetTeam(ctx context.Context, id TeamID) (Team, error)
+	// GetTeamMembers - участники по убыванию вклада
+	GetTeamMembers(ctx context.Context, id TeamID) ([]TeamMember, error)
+	// InviteToTeam - повторное приглашение ничего не меняет
+	InviteToTeam(ctx context.Context, id TeamID, userID UserID, invitedBy UserID, at time.Time) error
+	// JoinTeam по приглашению (ErrTeamInviteRequired), команда блокируется на время проверки размера (ErrTeamFull)
+	JoinTeam(ctx context.Context, id TeamID, userID UserID, at time.Time) error
+	// LeaveTeam убирает участника, если ушёл владелец - владельцем становится самый давний участник,
+	// последний участник удаляет команду
+	LeaveTeam(ctx context.Context, id TeamID, userID UserID) error
+	// TeamLeaderboard - команды по убыванию очков, aggregate - TeamScoreSum или TeamScoreAverage
+	TeamLeaderboard(ctx context.Context, aggregate string, ranking string, page int, size int) ([]TeamEntry, error)
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	minTeamName = 3
+	maxTeamName = 64
+)
+
+type TeamService struct {
+	store TeamStore
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewTeamService(store TeamStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *TeamService {
+	return &TeamService{
+		store: store,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Create - команда с владельцем owner, maxSize 0 - teams.max_size
+func (s TeamService) Create(ctx context.Context, owner UserID, name string, maxSize int) (Team, error) {
+	const op = "TeamService.Create"
+	name = strings.TrimSpace(name)
+	if n := utf8.RuneCountInString(name); n < minTeamName || n > maxTeamName {
+		return Team{}, fmt.Errorf("%w: name must be from %d to %d characters", ErrInvalidTeam, minTeamName, maxTeamName)
+	}
+	if maxSize == 0 {
+		maxSize = s.cfg.Teams.MaxSize
+	}
+	if maxSize < 1 || maxSize > s.cfg.Teams.MaxSize {
+		return Team{}, fmt.Errorf("%w: max_size must be between 1 and %d", ErrInvalidTeam, s.cfg.Teams.MaxSize)
+	}
+	team, err := s.store.CreateTeam(ctx, Team{Name: name, OwnerID: owner, MaxSize: maxSize, CreatedAt: s.cl.Now()})
+	if err != nil {
+		s.log.Error(op, "user_id", owner, "error", err)
+		return Team{}, err
+	}
+	s.log.Info(op+": team created", "team_id", team.ID, "owner", owner)
+	return team, nil
+}
+
+// Team - команда и её участники
+func (s TeamService) Team(ctx context.Context, id TeamID) (Team, []TeamMember, error) {
+	team, err := s.store.GetTeam(ctx, id)
+	if err != nil {
+		return Team{}, nil, err
+	}
+	members, err := s.store.GetTeamMembers(ctx, id)
+	if err != nil {
+		return Team{}, nil, err
+	}
+	return team, members, nil
+}
+
+// Invite - приглашение от владельца команды
+func (s TeamService) Invite(ctx context.Context, id TeamID, owner UserID, userID UserID) error {
+	const op = "TeamService.Invite"
+	if err := s.ownerOnly(ctx, id, owner); err != nil {
+		return err
+	}
+	if err := s.store.InviteToTeam(ctx, id, userID, owner, s.cl.Now()); err != nil {
+		s.log.Error(op, "team_id", id, "user_id", userID, "error", err)
+		return err
+	}
+	s.log.Info(op+": user invited", "team_id", id, "user_id", userID)
+	return nil
+}
+
+func (s TeamService) Join(ctx context.Context, id TeamID, userID UserID) error {
+	const op = "TeamService.Join"
+	if err := s.store.JoinTeam(ctx, id, userID, s.cl.Now()); err != nil {
+		s.log.Error(op, "team_id", id, "user_id", userID, "error", err)
+		return err
+	}
+	s.log.Info(op+": user joined team", "team_id", id, "user_id", userID)
+	return nil
+}
+
+func (s TeamService) Leave(ctx context.Context, id TeamID, userID UserID) error {
+	const op = "TeamService.Leave"
+	if err := s.store.LeaveTeam(ctx, id, userID); err != nil {
+		s.log.Error(op, "team_id", id, "user_id", userID, "error", err)
+		return err
+	}
+	s.log.Info(op+": user left team", "team_id", id, "user_id", userID)
+	return nil
+}
+
+// Kick - исключение участника владельцем, сам себя владелец не исключает (для этого Leave)
+func (s TeamService) Kick(ctx context.Context, id TeamID, owner UserID, userID UserID) error {
+	if owner == userID {
+		return fmt.Errorf("%w: owner can't kick themselves, leave the team instead", ErrInvalidTeam)
+	}
+	if err := s.ownerOnly(ctx, id, owner); err != nil {
+		return err
+	}
+	return s.Leave(ctx, id, userID)
+}
+
+func (s TeamService) ownerOnly(ctx context.Context, id TeamID, userID UserID) error {
+	team, err := s.store.GetTeam(ctx, id)
+	if err != nil {
+		return err
+	}
+	if team.OwnerID != userID {
+		return ErrNotTeamOwner
+	}
+	return nil
+}
+
+// Leaderboard - лидерборда команд по teams.score (sum или average)
+func (s TeamService) Leaderboard(ctx context.Context, page int, size int) ([]TeamEntry, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidLeaderboardQuery, MaxLeaderboardSize)
+	}
+	return s.store.TeamLeaderboard(ctx, s.cfg.Teams.Score, s.cfg.Leaderboard.Ranking, page, size)
+}
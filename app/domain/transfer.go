@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type TransferID int64
+
+const EventTransferCompleted = "transfer.completed"
+
+// Transfer - перевод очков, получатель получает Amount, отправитель платит Amount + Fee
+type Transfer struct {
+	ID         TransferID `db:"id"`
+	FromUserID UserID     `db:"from_user_id"`
+	ToUserID   UserID     `db:"to_user_id"`
+	Amount     int        `db:"amount"`
+	Fee        int        `db:"fee"`
+	Comment    *string    `db:"comment"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// Transaction - запись истории баланса пользователя (points_log): начисления, списания, переводы
+type Transaction struct {
+	Points     int       `db:"points"`
+	Reason     string    `db:"reason"`
+	Multiplier float64   `db:"multiplier"` // множитель бонусных событий, 1 - без множителя
+	At         time.Time `db:"created_at"`
+}
+
+var ErrTransfersDisabled = errors.New("Transfers are disabled")
+var ErrInvalidTransfer = errors.New("Invalid transfer")
+var ErrAccountSuspended = errors.New("Account is suspended")
+var ErrAccountTooNew = errors.New("Account is too new to send transfers")
+var ErrTransferLimit = errors.New("Daily transfer limit is reached")
+
+type TransferStore interface {
+	GetUser(ctx context.Context, id UserID) (User, error)
+	// Transfer блокирует обоих пользователей в порядке id (без взаимных блокировок при встречных переводах),
+	// создаёт перевод и вызывает transfer с отправителем, получателем, суммой отправленного с since и переводом.
+	// Затем в той же транзакции применяет начисления и списания (ErrInsufficientPoints) и пишет события
+	Transfer(ctx context.Context, t Transfer, since time.Time, transfer func(from User, to User, sent int, t Transfer) ([]Award, []Event, error)) (Transfer, error)
+	// GetTransactions - история баланса пользователя от новых записей к старым
+	GetTransactions(ctx context.Context, id UserID, page int, size int) ([]Transaction, error)
+	// SuspendUser блокирует аккаунт с момента at, nil снимает блокировку
+	SuspendUser(ctx context.Context, id UserID, at *time.Time) (User, error)
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// максимальная длина комментария к переводу
+const maxTransferComment = 255
+
+type TransferService struct {
+	store TransferStore
+	users *UserService // для часового пояса отправителя и обновления кэша лидерборды после перевода
+	log   *slog.Logger
+	cfg   *config.Config
+	cl    pkg.Clock
+}
+
+func NewTransferService(store TransferStore, users *UserService, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *TransferService {
+	return &TransferService{
+		store: store,
+		users: users,
+		log:   log,
+		cfg:   cfg,
+		cl:    cl,
+	}
+}
+
+// Send переводит amount очков от from к to, с отправителя дополнительно списывается комиссия.
+// Переводы не дают опыта и очков сезона, обе стороны и комиссия видны в истории баланса
+func (s TransferService) Send(ctx context.Context, from UserID, to UserID, amount int, comment string) (Transfer, error) {
+	const op = "TransferService.Send"
+	cfg := s.cfg.Transfers
+	if !cfg.Enabled {
+		return Transfer{}, ErrTransfersDisabled
+	}
+	switch {
+	case from == to:
+		return Transfer{}, fmt.Errorf("%w: can't transfer to yourself", ErrInvalidTransfer)
+	case amount < max(cfg.MinAmount, 1):
+		return Transfer{}, fmt.Errorf("%w: amount must be at least %d", ErrInvalidTransfer, max(cfg.MinAmount, 1))
+	case len(comment) > maxTransferComment:
+		return Transfer{}, fmt.Errorf("%w: comment must be at most %d bytes", ErrInvalidTransfer, maxTransferComment)
+	}
+	sender, err := s.store.GetUser(ctx, from)
+	if err != nil {
+		return Transfer{}, err
+	}
+	since, err := s.dayStart(sender)
+	if err != nil {
+		return Transfer{}, err
+	}
+	now := s.cl.Now()
+	t := Transfer{FromUserID: from, ToUserID: to, Amount: amount, Fee: s.fee(amount), CreatedAt: now}
+	if comment != "" {
+		t.Comment = &comment
+	}
+	var awards []Award
+	created, err := s.store.Transfer(ctx, t, since, func(fromUser User, toUser User, sent int, t Transfer) ([]Award, []Event, error) {
+		if fromUser.SuspendedAt != nil || toUser.SuspendedAt != nil {
+			return nil, nil, ErrAccountSuspended
+		}
+		if now.Sub(fromUser.Registered) < cfg.MinAccountAge {
+			return nil, nil, fmt.Errorf("%w: account must be at least %s old", ErrAccountTooNew, cfg.MinAccountAge)
+		}
+		if cfg.DailyLimit > 0 && sent+t.Amount > cfg.DailyLimit {
+			return nil, nil, fmt.Errorf("%w: %d of %d already sent today", ErrTransferLimit, sent, cfg.DailyLimit)
+		}
+		//получатель получает очки с датами сгорания отправителя, иначе переводом можно продлевать срок жизни очков
+		out := fmt.Sprintf("transfer_out:%d", t.ID)
+		awards = []Award{
+			{UserID: t.FromUserID, Points: -t.Amount, Reason: out, At: now, BalanceOnly: true},
+			{UserID: t.ToUserID, Points: t.Amount, Reason: fmt.Sprintf("transfer_in:%d", t.ID), At: now, BalanceOnly: true,
+				Restores: &DebitRef{UserID: t.FromUserID, Reason: out}},
+		}
+		if t.Fee > 0 {
+			awards = append(awards, Award{UserID: t.FromUserID, Points: -t.Fee, Reason: fmt.Sprintf("transfer_fee:%d", t.ID), At: now, BalanceOnly: true})
+		}
+		events := []Event{NewEvent(EventTransferCompleted, now, map[string]any{"transfer_id": t.ID,
+			"from_user_id": t.FromUserID, "to_user_id": t.ToUserID, "amount": t.Amount, "fee": t.Fee})}
+		for _, award := range awards {
+			events = append(events, PointsAdjustedEvent(award))
+		}
+		return awards, events, nil
+	})
+	if err != nil {
+		s.log.Error(op, "from", from, "to", to, "error", err)
+		return Transfer{}, err
+	}
+	if s.users != nil {
+		s.users.pointsChanged(ctx, awards...)
+	}
+	s.log.Info(op+": transfer completed", "transfer_id", created.ID, "from", from, "to", to, "amount", amount, "fee", created.Fee)
+	return created, nil
+}
+
+// fee - комиссия с суммы перевода, округляется вверх
+func (s TransferService) fee(amount int) int {
+	if s.cfg.Transfers.FeePercent <= 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(amount) * s.cfg.Transfers.FeePercent / 100))
+}
+
+// dayStart - начало текущего дня в часовом поясе пользователя, с него считается дневной лимит
+func (s TransferService) dayStart(user User) (time.Time, error) {
+	tz := s.cfg.Leaderboard.Timezone
+	if user.Timezone != nil {
+		tz = *user.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTimezone, tz)
+	}
+	y, m, d := s.cl.Now().In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+}
+
+// History - история баланса пользователя, включая обе стороны переводов
+func (s TransferService) History(ctx context.Context, id UserID, page int, size int) ([]Transaction, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidTransfer, MaxLeaderboardSize)
+	}
+	return s.store.GetTransactions(ctx, id, page, size)
+}
+
+// Suspend блокирует (suspend true) или разблокирует аккаунт, заблокированные не могут отправлять и получать переводы
+func (s TransferService) Suspend(ctx context.Context, id UserID, suspend bool) (User, error) {
+	const op = "TransferService.Suspend"
+	var at *time.Time
+	if suspend {
+		now := s.cl.Now()
+		at = &now
+	}
+	user, err := s.store.SuspendUser(ctx, id, at)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return User{}, err
+	}
+	s.log.Info(op+": account suspension changed", "user_id", id, "suspended", suspend)
+	return user, nil
+}
@@ -2,41 +2,96 @@ package domain
 
 import (
 	"app/iternal/config"
+	"app/iternal/pkg"
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 )
 
 type UserService struct {
 	store UserStore
 	log   *slog.Logger
 	cfg   *config.Config
+	cl    pkg.Clock
+	board *LeaderboardCache // может быть nil, тогда лидерборда всегда берётся из бд
+	hub   *ScoreHub         // может быть nil, тогда об изменении очков никто не уведомляется
+	//проверяющие по ключу задания, задания без проверяющего засчитываются без проверки
+	verifiers    map[string]TaskVerifier
+	achievements *AchievementService // может быть nil, тогда достижения не выдаются
+	levels       *LevelService       // может быть nil, тогда уровни не отмечаются и бонусы за них не начисляются
+	bonuses      *BonusEventService  // может быть nil, тогда бонусные события не применяются
+	quests       *QuestService       // может быть nil, тогда квесты не проверяются и не продвигаются
 }
 
 type UserStore interface {
 	GetUser(ctx context.Context, id UserID) (User, error)
-	GetUsers(ctx context.Context, string string, page int, limit int) ([]User, error)
-	AddPoints(ctx context.Context, id UserID, points int) error
-	SetInvitedBy(ctx context.Context, userID UserID, invitedByID UserID) error
-	AddUser(ctx context.Context, user User) error
+	GetUsers(ctx context.Context, q LeaderboardQuery) ([]LeaderboardEntry, error)
+	CountUsers(ctx context.Context) (int, error)
+	GetUserRank(ctx context.Context, id UserID, ranking string, neighbours int) (UserRank, error)
+	// AddPoints начисляет очки и в той же транзакции пишет события в outbox
+	AddPoints(ctx context.Context, award Award, events ...Event) error
+	// ApplyReferral записывает пригласившего (только если он ещё не записан), начисляет награды и пишет события - всё одной транзакцией
+	ApplyReferral(ctx context.Context, userID UserID, invitedByID UserID, awards []Award, events ...Event) error
+	// AddUser создаёт пользователя и в той же транзакции пишет событие user.registered
+	AddUser(ctx context.Context, user User) (User, error)
+	GetStreaks(ctx context.Context, id UserID) ([]Streak, error)
+	// CompleteStreakTask блокирует серию пользователя по заданию, продлевает её на day и начисляет очки, которые
+	// complete посчитает по новой серии (newDay - день засчитан впервые), всё в одной транзакции
+	CompleteStreakTask(ctx context.Context, id UserID, task string, day time.Time, complete func(streak Streak, newDay bool) (Award, []Event)) (Streak, Award, error)
+	// CompleteAccountTask привязывает аккаунт к пользователю, отмечает задание выполненным и начисляет очки одной транзакцией.
+	// ErrAccountTaken/ErrAccountMismatch - привязка не совпадает, ErrTaskAlreadyCompleted - задание уже засчитано
+	CompleteAccountTask(ctx context.Context, link ExternalAccount, task string, award Award, events ...Event) error
 }
 
-func NewUserService(store UserStore, log *slog.Logger, cfg *config.Config) *UserService {
+func NewUserService(store UserStore, log *slog.Logger, cfg *config.Config, cl pkg.Clock, board *LeaderboardCache, hub *ScoreHub, verifiers map[string]TaskVerifier, achievements *AchievementService, levels *LevelService, bonuses *BonusEventService, quests *QuestService) *UserService {
 	return &UserService{
-		store: store,
-		log:   log,
-		cfg:   cfg,
+		store:        store,
+		log:          log,
+		cfg:          cfg,
+		cl:           cl,
+		board:        board,
+		hub:          hub,
+		verifiers:    verifiers,
+		achievements: achievements,
+		levels:       levels,
+		bonuses:      bonuses,
+		quests:       quests,
 	}
 }
 
-func (s UserService) AddUser(ctx context.Context, user User) error {
+func (s UserService) AddUser(ctx context.Context, user User) (User, error) {
 	const op = "UserService.AddUser"
-	s.log.Debug(op, "trying to add user")
-	err := s.store.AddUser(ctx, user)
+	s.log.Debug(op + ": trying to add user")
+	if user.Timezone != nil {
+		if _, err := time.LoadLocation(*user.Timezone); err != nil || *user.Timezone == "" {
+			return User{}, fmt.Errorf("%w: %q", ErrInvalidTimezone, *user.Timezone)
+		}
+	}
+	created, err := s.store.AddUser(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	if s.board != nil {
+		s.board.AddUser(created)
+	}
+	s.log.Debug(op + ": successfully added user")
+	return created, nil
+}
+
+// requireVerified - при email.require_verified очки начисляются только пользователям с подтверждённым email
+func (s UserService) requireVerified(ctx context.Context, id UserID) error {
+	if !s.cfg.Email.RequireVerified {
+		return nil
+	}
+	user, err := s.store.GetUser(ctx, id)
 	if err != nil {
 		return err
 	}
-	s.log.Debug(op, "successfully added user")
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
 	return nil
 }
 
@@ -44,58 +99,469 @@ func (s UserService) Status(ctx context.Context, id UserID) (User, error) {
 	const op = "UserService.Status"
 	user, err := s.store.GetUser(ctx, id)
 	if err != nil {
-		s.log.Error(op, err)
+		s.log.Error(op, "error", err)
 		return User{}, err
 	}
 	return user, err
 }
 
-func (s UserService) Leaderbord(ctx context.Context, filter string, page int, limit int) ([]User, error) {
+func (s UserService) Leaderbord(ctx context.Context, q LeaderboardQuery) (LeaderboardPage, error) {
 	const op = "UserService.Leaderbord"
+	var page LeaderboardPage
+	if err := q.Validate(); err != nil {
+		return page, err
+	}
+	loc, err := s.location(q.Timezone)
+	if err != nil {
+		return page, err
+	}
+	q.Window, err = PeriodWindow(q.Period, s.cl.Now(), loc, q.From, q.To)
+	if err != nil {
+		return page, err
+	}
+	//запрашиваем на одну запись больше, чтобы понять есть ли следующая страница
+	probe := q
+	probe.Size = q.Size + 1
+	probe.Ranking = rankingMode(s.cfg)
+	var users []LeaderboardEntry
+	if s.board != nil && s.board.Serves(q) {
+		users = s.board.GetUsers(probe)
+		page.Total = s.board.CountUsers()
+	} else {
+		users, err = s.store.GetUsers(ctx, probe)
+		if err != nil {
+			s.log.Error(op, "error", err)
+			return page, err
+		}
+		page.Total, err = s.store.CountUsers(ctx)
+		if err != nil {
+			s.log.Error(op, "error", err)
+			return page, err
+		}
+	}
+	if len(users) > q.Size {
+		users = users[:q.Size]
+		page.NextCursor = cursorAfter(q, users[len(users)-1].User).Encode()
+	}
+	page.Users = s.withLevels(users)
+	return page, nil
+}
 
-	users, err := s.store.GetUsers(ctx, filter, page, limit)
+// Rank - место пользователя в рейтинге и по neighbours соседей сверху и снизу
+func (s UserService) Rank(ctx context.Context, id UserID, neighbours int) (UserRank, error) {
+	const op = "UserService.Rank"
+	if neighbours == 0 {
+		neighbours = DefaultRankNeighbours
+	}
+	if neighbours < 0 || neighbours > MaxRankNeighbours {
+		return UserRank{}, fmt.Errorf("%w: neighbours must be between 1 and %d", ErrInvalidLeaderboardQuery, MaxRankNeighbours)
+	}
+	var rank UserRank
+	var err error
+	if s.board != nil {
+		rank, err = s.board.GetUserRank(id, rankingMode(s.cfg), neighbours)
+	} else {
+		rank, err = s.store.GetUserRank(ctx, id, rankingMode(s.cfg), neighbours)
+	}
 	if err != nil {
-		s.log.Error(op, err)
-		return nil, err
+		s.log.Error(op, "error", err)
+		return UserRank{}, err
 	}
-	return users, err
+	rank.Level = LevelFor(s.cfg.Levels, rank.XP).Level
+	rank.Above = s.withLevels(rank.Above)
+	rank.Below = s.withLevels(rank.Below)
+	return rank, nil
+}
+
+// Level - уровень пользователя и прогресс до следующего
+func (s UserService) Level(user User) Level {
+	return LevelFor(s.cfg.Levels, user.XP)
 }
 
-func (s UserService) TaskComplete(ctx context.Context, id UserID, task string) error {
+func (s UserService) withLevels(entries []LeaderboardEntry) []LeaderboardEntry {
+	for i := range entries {
+		entries[i].Level = LevelFor(s.cfg.Levels, entries[i].XP).Level
+	}
+	return entries
+}
+
+// location - часовой пояс из запроса, если не передан то из конфига
+func (s UserService) location(tz string) (*time.Location, error) {
+	if tz == "" {
+		tz = s.cfg.Leaderboard.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidLeaderboardQuery, tz)
+	}
+	return loc, nil
+}
+
+// account - аккаунт пользователя во внешнем сервисе, нужен только для заданий с внешней проверкой
+func (s UserService) TaskComplete(ctx context.Context, id UserID, task string, account string) error {
 	const op = "UserService.TaskComplete"
 	var err error
 	if points, inMap := s.cfg.Rewards[task]; inMap {
-		err = s.store.AddPoints(ctx, id, points)
+		if err = s.requireVerified(ctx, id); err != nil {
+			return err
+		}
+		//день пользователя нужен только заданиям из квестов, остальные не делают лишний запрос
+		var today time.Time
+		if s.quests != nil && s.quests.Has(task) {
+			if today, err = s.today(ctx, id); err != nil {
+				return err
+			}
+			if err = s.quests.Check(ctx, id, task, today); err != nil {
+				s.log.Info(op+": quest prerequisite not met", "user_id", id, "task", task, "error", err)
+				return err
+			}
+		}
+		verifier := s.verifier(task)
+		claim := TaskClaim{UserID: id, Task: task, Account: account}
+		accountVerifier, once := verifier.(AccountVerifier)
+		if once {
+			if claim.Account, err = accountVerifier.NormalizeAccount(account); err != nil {
+				return err
+			}
+		}
+		if err = verifier.Verify(ctx, claim); err != nil {
+			s.log.Info(op+": task not verified", "user_id", id, "task", task, "error", err)
+			return err
+		}
+		multiplier, bonusEvents, err := s.multiplier(ctx, task)
 		if err != nil {
+			s.log.Error(op, "user_id", id, "task", task, "error", err)
 			return err
 		}
+		if streak, tracked := s.cfg.Streaks.Tasks[task]; once {
+			link := ExternalAccount{UserID: id, Service: accountVerifier.Service(), Account: claim.Account}
+			err = s.completeAccountTask(ctx, link, task, points, multiplier, bonusEvents)
+		} else if tracked {
+			err = s.completeStreakTask(ctx, id, task, points, streak, multiplier, bonusEvents)
+		} else {
+			now := s.cl.Now()
+			award := Award{UserID: id, Points: multiplyPoints(points, multiplier), Reason: task, At: now, Multiplier: multiplier}
+			completed := NewEvent(EventTaskCompleted, now, completedData(award, bonusEvents))
+			err = s.addPoints(ctx, award, completed)
+		}
+		if err != nil {
+			return err
+		}
+		if !today.IsZero() {
+			s.advanceQuests(ctx, id, task, today)
+		}
 	} else {
-		s.log.Info(op, fmt.Sprintf("user %v tried to claim not existing reward", id))
+		s.log.Info(op+": tried to claim not existing reward", "user_id", id)
 		return ErrNotExistingReward
 	}
 	return nil
 }
 
+// advanceQuests - задание уже засчитано, поэтому ошибка продвижения квеста его не отменяет, только логируется
+func (s UserService) advanceQuests(ctx context.Context, id UserID, task string, today time.Time) {
+	const op = "UserService.advanceQuests"
+	awards, err := s.quests.Advance(ctx, id, task, today)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "task", task, "error", err)
+	}
+	if len(awards) > 0 {
+		s.pointsChanged(ctx, awards...)
+	}
+}
+
+// approvedTask - задание засчитано модератором: квесты продвигаются по дню пользователя, в который подана заявка,
+// а не по дню одобрения, иначе заявка, одобренная на следующий день, не попадёт в ежедневный квест
+func (s UserService) approvedTask(ctx context.Context, id UserID, task string, submitted time.Time) {
+	const op = "UserService.approvedTask"
+	if s.quests == nil || !s.quests.Has(task) {
+		return
+	}
+	day, err := s.dayOf(ctx, id, submitted)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return
+	}
+	s.advanceQuests(ctx, id, task, day)
+}
+
+// Quests - квесты с прогрессом пользователя в текущем периоде (для ежедневных - сегодняшний день пользователя)
+func (s UserService) Quests(ctx context.Context, id UserID) ([]QuestStatus, error) {
+	if s.quests == nil {
+		return []QuestStatus{}, nil
+	}
+	today, err := s.today(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.quests.Progress(ctx, id, today)
+}
+
+// multiplier - множитель бонусных событий, идущих сейчас для задания, и id применённых событий
+func (s UserService) multiplier(ctx context.Context, task string) (float64, []BonusEventID, error) {
+	if s.bonuses == nil {
+		return 1, nil, nil
+	}
+	multipliers, applied, err := s.bonuses.Multipliers(ctx, task)
+	if err != nil {
+		return 0, nil, err
+	}
+	if m, ok := multipliers[task]; ok {
+		return m, applied[task], nil
+	}
+	return 1, nil, nil
+}
+
+// TaskInfo - задание в каталоге: базовая награда и награда с учётом идущих бонусных событий
+type TaskInfo struct {
+	Task        string
+	Points      int
+	Multiplier  float64
+	BonusPoints int // награда с множителем, без учёта бонуса за серию
+	Verifier    string
+	Streak      bool // ежедневное задание с бонусом за серию
+	BonusEvents []BonusEventID
+}
+
+// Tasks - каталог заданий с действующими сейчас множителями
+func (s UserService) Tasks(ctx context.Context) ([]TaskInfo, error) {
+	const op = "UserService.Tasks"
+	keys := make([]string, 0, len(s.cfg.Rewards))
+	for task := range s.cfg.Rewards {
+		//награды за приглашение начисляются через referrer, это не задания
+		if task != RewardInviting && task != RewardInvited {
+			keys = append(keys, task)
+		}
+	}
+	slices.Sort(keys)
+	var multipliers map[string]float64
+	var applied map[string][]BonusEventID
+	if s.bonuses != nil {
+		var err error
+		if multipliers, applied, err = s.bonuses.Multipliers(ctx, keys...); err != nil {
+			s.log.Error(op, "error", err)
+			return nil, err
+		}
+	}
+	tasks := make([]TaskInfo, 0, len(keys))
+	for _, task := range keys {
+		points := s.cfg.Rewards[task]
+		multiplier, ok := multipliers[task]
+		if !ok {
+			multiplier = 1
+		}
+		verifier := s.cfg.Verification.Tasks[task].Verifier
+		if verifier == "" {
+			verifier = VerifierSelfReported
+		}
+		_, streak := s.cfg.Streaks.Tasks[task]
+		tasks = append(tasks, TaskInfo{
+			Task:        task,
+			Points:      points,
+			Multiplier:  multiplier,
+			BonusPoints: multiplyPoints(points, multiplier),
+			Verifier:    verifier,
+			Streak:      streak,
+			BonusEvents: applied[task],
+		})
+	}
+	return tasks, nil
+}
+
+// completedData - данные события task.completed, множитель и бонусные события только если они применились
+func completedData(award Award, bonusEvents []BonusEventID) map[string]any {
+	data := map[string]any{"user_id": award.UserID, "task": award.Reason, "points": award.Points}
+	if len(bonusEvents) > 0 {
+		data["multiplier"] = award.Multiplier
+		data["bonus_events"] = bonusEvents
+	}
+	return data
+}
+
+// completeAccountTask - задание с проверкой по внешнему аккаунту засчитывается один раз, серии по нему не ведутся
+func (s UserService) completeAccountTask(ctx context.Context, link ExternalAccount, task string, points int, multiplier float64, bonusEvents []BonusEventID) error {
+	const op = "UserService.completeAccountTask"
+	now := s.cl.Now()
+	award := Award{UserID: link.UserID, Points: multiplyPoints(points, multiplier), Reason: task, At: now, Multiplier: multiplier}
+	completed := NewEvent(EventTaskCompleted, now, completedData(award, bonusEvents))
+	err := s.store.CompleteAccountTask(ctx, link, task, award, completed, PointsAdjustedEvent(award))
+	if err != nil {
+		s.log.Info(op+": task not completed", "user_id", link.UserID, "task", task, "error", err)
+		return err
+	}
+	s.pointsChanged(ctx, award)
+	return nil
+}
+
+// completeStreakTask - выполнение ежедневного задания: серия продлевается на сегодняшний день пользователя,
+// бонус за серию начисляется только за первое выполнение задания в день, множитель бонусных событий - поверх него
+func (s UserService) completeStreakTask(ctx context.Context, id UserID, task string, points int, cfg config.StreakTask, multiplier float64, bonusEvents []BonusEventID) error {
+	const op = "UserService.completeStreakTask"
+	today, err := s.today(ctx, id)
+	if err != nil {
+		return err
+	}
+	streak, award, err := s.store.CompleteStreakTask(ctx, id, task, today, func(streak Streak, newDay bool) (Award, []Event) {
+		now := s.cl.Now()
+		award := Award{UserID: id, Points: points, Reason: task, At: now, Multiplier: multiplier}
+		if newDay {
+			award.Points = streakPoints(points, streak.Current, cfg.Bonuses)
+		}
+		award.Points = multiplyPoints(award.Points, multiplier)
+		data := completedData(award, bonusEvents)
+		data["streak"] = streak.Current
+		completed := NewEvent(EventTaskCompleted, now, data)
+		return award, []Event{completed, PointsAdjustedEvent(award)}
+	})
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return err
+	}
+	s.log.Debug(op+": task completed", "user_id", id, "task", task, "streak", streak.Current, "points", award.Points)
+	s.pointsChanged(ctx, award)
+	return nil
+}
+
+// Streaks - серии пользователя по ежедневным заданиям на сегодняшний день пользователя
+func (s UserService) Streaks(ctx context.Context, id UserID) ([]Streak, error) {
+	const op = "UserService.Streaks"
+	today, err := s.today(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	streaks, err := s.store.GetStreaks(ctx, id)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return nil, err
+	}
+	for i := range streaks {
+		streaks[i] = streaks[i].Actual(today)
+	}
+	return streaks, nil
+}
+
+// today - текущий день в часовом поясе пользователя
+func (s UserService) today(ctx context.Context, id UserID) (time.Time, error) {
+	return s.dayOf(ctx, id, s.cl.Now())
+}
+
+// dayOf - день момента at в часовом поясе пользователя
+func (s UserService) dayOf(ctx context.Context, id UserID, at time.Time) (time.Time, error) {
+	user, err := s.store.GetUser(ctx, id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tz := ""
+	if user.Timezone != nil {
+		tz = *user.Timezone
+	}
+	loc, err := s.location(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return Day(at, loc), nil
+}
+
+func (s UserService) verifier(task string) TaskVerifier {
+	if v, ok := s.verifiers[task]; ok {
+		return v
+	}
+	return SelfReported{}
+}
+
 func (s UserService) InvitedBy(ctx context.Context, id UserID, invitedBy UserID) error {
 	const op = "UserService.InvitedBy"
-	rewardInviter := s.cfg.Rewards["inviting_a_friend"]
-	rewardInvited := s.cfg.Rewards["being_invited"]
+	rewardInviter := s.cfg.Rewards[RewardInviting]
+	rewardInvited := s.cfg.Rewards[RewardInvited]
 	if rewardInviter == 0 {
 		s.log.Error("No reward for ref")
 		return ErrNoRewardRef
 	}
-	err := s.store.SetInvitedBy(ctx, id, invitedBy)
-	if err != nil {
+	//подтверждённый email нужен приглашённому, пригласивший подтверждал свой при выполнении заданий
+	if err := s.requireVerified(ctx, id); err != nil {
 		return err
 	}
-	err = s.store.AddPoints(ctx, id, rewardInvited)
+	now := s.cl.Now()
+	awards := []Award{
+		{UserID: id, Points: rewardInvited, Reason: RewardInvited, At: now},
+		{UserID: invitedBy, Points: rewardInviter, Reason: RewardInviting, At: now},
+	}
+	events := []Event{NewEvent(EventReferralApplied, now, map[string]any{
+		"user_id":         id,
+		"referrer_id":     invitedBy,
+		"user_points":     rewardInvited,
+		"referrer_points": rewardInviter,
+	})}
+	for _, award := range awards {
+		events = append(events, PointsAdjustedEvent(award))
+	}
+	//запись пригласившего, начисление очков обоим и события - одна транзакция
+	err := s.store.ApplyReferral(ctx, id, invitedBy, awards, events...)
 	if err != nil {
 		return err
 	}
-	err = s.store.AddPoints(ctx, invitedBy, rewardInviter)
-	if err != nil {
+	s.pointsChanged(ctx, awards...)
+	return nil
+}
+
+// addPoints - начисление очков в бд вместе с событиями (points.adjusted добавляется всегда)
+func (s UserService) addPoints(ctx context.Context, award Award, events ...Event) error {
+	events = append(events, PointsAdjustedEvent(award))
+	if err := s.store.AddPoints(ctx, award, events...); err != nil {
 		return err
 	}
-	//todo: По хорошему следовало бы объеденить все эти 3 запроса в одну транзакцию для снижения кол-во запросов к бд и атомарности
+	s.pointsChanged(ctx, award)
 	return nil
 }
+
+// pointsChanged - после записи в бд обновляем кэш лидерборды (если включен) и уведомляем подписчиков живой лидерборды
+func (s UserService) pointsChanged(ctx context.Context, awards ...Award) {
+	const op = "UserService.pointsChanged"
+	//после каждого начисления проверяем достижения и уровни затронутых пользователей, бонусы за них тоже попадают в кэш.
+	//Ошибка проверки не отменяет начисление: достижение или уровень отметятся при следующей проверке
+	checked := make(map[UserID]bool, len(awards))
+	for _, award := range awards {
+		if checked[award.UserID] {
+			continue
+		}
+		checked[award.UserID] = true
+		if s.achievements != nil {
+			bonuses, err := s.achievements.Evaluate(ctx, award.UserID)
+			if err != nil {
+				s.log.Error(op, "user_id", award.UserID, "error", err)
+			}
+			awards = append(awards, bonuses...)
+		}
+		if s.levels != nil {
+			bonuses, err := s.levels.Evaluate(ctx, award.UserID)
+			if err != nil {
+				s.log.Error(op, "user_id", award.UserID, "error", err)
+			}
+			awards = append(awards, bonuses...)
+		}
+	}
+	if s.board != nil {
+		for _, award := range awards {
+			s.board.AddPoints(ctx, award)
+		}
+	}
+	if s.hub != nil {
+		s.hub.Publish()
+	}
+}
+
+// Achievements - полученные пользователем достижения
+func (s UserService) Achievements(ctx context.Context, id UserID) ([]Achievement, error) {
+	const op = "UserService.Achievements"
+	if _, err := s.store.GetUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if s.achievements == nil {
+		return []Achievement{}, nil
+	}
+	achievements, err := s.achievements.List(ctx, id)
+	if err != nil {
+		s.log.Error(op, "user_id", id, "error", err)
+		return nil, err
+	}
+	return achievements, nil
+}
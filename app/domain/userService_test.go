@@ -0,0 +1,104 @@
+package domain_test
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memAccounts - привязки аккаунтов и засчитанные задания в памяти, как их ограничивают ключи external_accounts и verified_tasks
+type memAccounts struct {
+	domain.UserStore
+	owners    map[string]domain.UserID // service/account -> пользователь
+	accounts  map[string]string        // user/service -> account
+	completed map[string]bool          // user/task
+	awards    []domain.Award
+}
+
+func newMemAccounts() *memAccounts {
+	return &memAccounts{owners: map[string]domain.UserID{}, accounts: map[string]string{}, completed: map[string]bool{}}
+}
+
+func (m *memAccounts) CompleteAccountTask(ctx context.Context, link domain.ExternalAccount, task string, award domain.Award, events ...domain.Event) error {
+	userKey := fmt.Sprintf("%d/%s", link.UserID, link.Service)
+	taskKey := fmt.Sprintf("%d/%s", link.UserID, task)
+	if owner, ok := m.owners[link.Service+"/"+link.Account]; ok && owner != link.UserID {
+		return domain.ErrAccountTaken
+	}
+	if account, ok := m.accounts[userKey]; ok && account != link.Account {
+		return domain.ErrAccountMismatch
+	}
+	if m.completed[taskKey] {
+		return domain.ErrTaskAlreadyCompleted
+	}
+	m.owners[link.Service+"/"+link.Account] = link.UserID
+	m.accounts[userKey] = link.Account
+	m.completed[taskKey] = true
+	m.awards = append(m.awards, award)
+	return nil
+}
+
+// subscribers - проверяющий по аккаунту, подписчики перечислены заранее
+type subscribers map[string]bool
+
+func (s subscribers) Service() string {
+	return domain.VerifierTelegram
+}
+
+func (s subscribers) NormalizeAccount(account string) (string, error) {
+	account = strings.TrimSpace(account)
+	if account == "" {
+		return "", domain.ErrInvalidTaskClaim
+	}
+	return account, nil
+}
+
+func (s subscribers) Verify(ctx context.Context, claim domain.TaskClaim) error {
+	if !s[claim.Account] {
+		return domain.ErrTaskNotVerified
+	}
+	return nil
+}
+
+func TestTaskCompleteVerifiedTaskOncePerUserAndAccount(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Rewards: map[string]int{"subscribe": 100, "subscribe_news": 50}}
+	//серия по заданию с внешней проверкой не ведётся, задание засчитывается один раз
+	cfg.Streaks.Tasks = map[string]config.StreakTask{"subscribe": {}}
+	store := newMemAccounts()
+	verifiers := map[string]domain.TaskVerifier{"subscribe": subscribers{"111": true, "222": true}, "subscribe_news": subscribers{"111": true, "222": true}}
+	cl := &pkg.StubClock{Time: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	users := domain.NewUserService(store, discardLog(), cfg, cl, nil, nil, verifiers, nil, nil, nil, nil)
+
+	if err := users.TaskComplete(ctx, 1, "subscribe", " 111 "); err != nil {
+		t.Fatal(err)
+	}
+	cl.Time = cl.Time.Add(24 * time.Hour)
+	steps := []struct {
+		user    domain.UserID
+		task    string
+		account string
+		want    error
+	}{
+		{1, "subscribe", "111", domain.ErrTaskAlreadyCompleted},
+		{2, "subscribe", "111", domain.ErrAccountTaken},
+		{1, "subscribe_news", "222", domain.ErrAccountMismatch},
+		{2, "subscribe", "333", domain.ErrTaskNotVerified},
+		{1, "subscribe_news", "111", nil},
+		{2, "subscribe", "222", nil},
+	}
+	for _, step := range steps {
+		if err := users.TaskComplete(ctx, step.user, step.task, step.account); !errors.Is(err, step.want) {
+			t.Fatalf("user %d completing %s with account %q: err = %v, want %v", step.user, step.task, step.account, err, step.want)
+		}
+	}
+	if len(store.awards) != 3 || store.awards[0].Points != 100 || store.awards[1].Points != 50 {
+		t.Fatalf("awards %+v, want 100 and 50 to user 1 and 100 to user 2", store.awards)
+	}
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+/*Проверка выполнения заданий. Для каждого задания (ключ из rewards) можно указать проверяющего,
+задания без проверяющего засчитываются на слово пользователя (SelfReported), как и раньше
+*/
+
+const (
+	VerifierSelfReported = "self_reported"
+	VerifierTelegram     = "telegram"
+	VerifierTwitter      = "twitter"
+)
+
+var ErrTaskNotVerified = errors.New("Task completion is not confirmed")
+var ErrInvalidTaskClaim = errors.New("Invalid task claim")
+var ErrVerificationUnavailable = errors.New("Task verification service is unavailable")
+var ErrTaskAlreadyCompleted = errors.New("Task is already completed")
+var ErrAccountTaken = errors.New("Account is already bound to another user")
+var ErrAccountMismatch = errors.New("Another account of this service is already bound to the user")
+
+// TaskClaim - заявка пользователя о выполнении задания. Account - аккаунт пользователя во внешнем сервисе
+// (id в Telegram, имя в X/Twitter), нужен только для заданий с внешней проверкой
+type TaskClaim struct {
+	UserID  UserID
+	Task    string
+	Account string
+}
+
+// TaskVerifier подтверждает выполнение задания. nil - задание выполнено, ErrTaskNotVerified - не выполнено,
+// ErrVerificationUnavailable - внешний сервис не ответил, пользователь может повторить позже
+type TaskVerifier interface {
+	Verify(ctx context.Context, claim TaskClaim) error
+}
+
+// AccountVerifier - проверяющий по аккаунту во внешнем сервисе. Такое задание засчитывается пользователю один раз,
+// а аккаунт привязывается к пользователю: другой пользователь не может заявить его, а сам пользователь - другой аккаунт
+// этого сервиса
+type AccountVerifier interface {
+	TaskVerifier
+	// Service - внешний сервис аккаунта (telegram, twitter)
+	Service() string
+	// NormalizeAccount приводит аккаунт из заявки к виду, в котором он хранится в привязке, ErrInvalidTaskClaim - аккаунт некорректный
+	NormalizeAccount(account string) (string, error)
+}
+
+// ExternalAccount - привязка аккаунта во внешнем сервисе к пользователю
+type ExternalAccount struct {
+	UserID  UserID
+	Service string
+	Account string
+}
+
+// SelfReported засчитывает любое задание без проверки
+type SelfReported struct{}
+
+func (SelfReported) Verify(ctx context.Context, claim TaskClaim) error {
+	return nil
+}
@@ -1,10 +1,19 @@
 package domain
 
-import "strings"
+import (
+	"net/mail"
+	"strings"
+)
 
-func VerifyEmail(email Email) error { //todo Я хотел ещё проверитьчтобы email заканчивался на существующий домен верхнего типа, но потом загуглил и узнал что их больше 1500, хотел просто сделать мапу с ключами в виде названий всех доменов и значениями true bool и проверять что email заканчивается на стрингу которая есть в map, но их 1500 так что пускай пока без них, просто проверяем наличие собаки
-	contains := strings.Contains(string(email), "@")
-	if !contains {
+// VerifyEmail проверяет только формат адреса: голый адрес по RFC 5322 с точкой в домене.
+// Существование ящика подтверждается письмом со ссылкой (ProfileService.SendVerification)
+func VerifyEmail(email Email) error {
+	addr, err := mail.ParseAddress(string(email))
+	if err != nil || addr.Address != string(email) {
+		return ErrNotEmail
+	}
+	host := addr.Address[strings.LastIndex(addr.Address, "@")+1:]
+	if !strings.Contains(strings.Trim(host, "."), ".") {
 		return ErrNotEmail
 	}
 	return nil
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type WebhookID int64
+type DeliveryID int64
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription - адрес партнёра, на который отправляются события. Пустой Events - подписка на все события
+type WebhookSubscription struct {
+	ID        WebhookID `db:"id"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    []string  `db:"events"`
+	Active    bool      `db:"active"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// WebhookDelivery - доставка одного события одной подписке
+type WebhookDelivery struct {
+	ID             DeliveryID `db:"id"`
+	SubscriptionID WebhookID  `db:"subscription_id"`
+	EventID        string     `db:"event_id"`
+	EventType      string     `db:"event_type"`
+	Payload        []byte     `db:"payload"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code"`
+	LastError      *string    `db:"last_error"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+	//заполняются только при захвате доставки воркером
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+var ErrWebhookNotFound = errors.New("Webhook subscription not found")
+var ErrInvalidWebhook = errors.New("Invalid webhook subscription")
+
+type WebhookStore interface {
+	AddWebhook(ctx context.Context, sub WebhookSubscription) (WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id WebhookID) error
+	// EnqueueDeliveries создаёт доставки события для всех активных подписок на него (повторный вызов с тем же событием ничего не делает)
+	EnqueueDeliveries(ctx context.Context, event Event, payload []byte) error
+	// ClaimDeliveries забирает готовые к отправке доставки и откладывает их следующую попытку на lease,
+	// чтобы одну доставку одновременно не отправляли несколько воркеров
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id DeliveryID, statusCode int, at time.Time) error
+	ScheduleRetry(ctx context.Context, id DeliveryID, statusCode int, lastErr string, next time.Time) error
+	// MarkDead - доставка исчерпала попытки, копия уходит в webhook_dead_letters
+	MarkDead(ctx context.Context, id DeliveryID, statusCode int, lastErr string, at time.Time) error
+	GetDeliveries(ctx context.Context, id WebhookID, status string, page int, size int) ([]WebhookDelivery, error)
+}
+
+// WebhookSender - отправка доставки по HTTP, возвращает код ответа получателя
+type WebhookSender interface {
+	Send(ctx context.Context, delivery WebhookDelivery) (int, error)
+}
@@ -0,0 +1,197 @@
+package domain
+
+import (
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+const webhookBatch = 50
+
+var knownEvents = map[string]bool{
+	EventUserRegistered:     true,
+	EventTaskCompleted:      true,
+	EventReferralApplied:    true,
+	EventPointsAdjusted:     true,
+	EventSubmissionReviewed: true,
+	EventAchievementEarned:  true,
+	EventLevelUp:            true,
+	EventOrderPlaced:        true,
+	EventOrderFulfilled:     true,
+	EventOrderCancelled:     true,
+	EventTransferCompleted:  true,
+	EventPointsExpired:      true,
+	EventQuestCompleted:     true,
+	EventChallengeCreated:   true,
+	EventChallengeAccepted:  true,
+	EventChallengeClosed:    true,
+	EventChallengeResolved:  true,
+	EventUserUpdated:        true,
+	EventUserDeleted:        true,
+	EventEmailVerified:      true,
+}
+
+type WebhookService struct {
+	store  WebhookStore
+	sender WebhookSender
+	log    *slog.Logger
+	cfg    *config.Config
+	cl     pkg.Clock
+}
+
+// sender нужен только воркеру доставки (Run), для админского API и публикации событий можно передать nil
+func NewWebhookService(store WebhookStore, sender WebhookSender, log *slog.Logger, cfg *config.Config, cl pkg.Clock) *WebhookService {
+	return &WebhookService{
+		store:  store,
+		sender: sender,
+		log:    log,
+		cfg:    cfg,
+		cl:     cl,
+	}
+}
+
+// Subscribe регистрирует новую подписку, если secret не передан - генерируем его
+func (s WebhookService) Subscribe(ctx context.Context, rawURL string, secret string, events []string) (WebhookSubscription, error) {
+	const op = "WebhookService.Subscribe"
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return WebhookSubscription{}, fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	for _, event := range events {
+		if !knownEvents[event] {
+			return WebhookSubscription{}, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return WebhookSubscription{}, err
+		}
+		secret = hex.EncodeToString(raw)
+	}
+	if events == nil {
+		events = []string{}
+	}
+	sub, err := s.store.AddWebhook(ctx, WebhookSubscription{URL: rawURL, Secret: secret, Events: events, Active: true})
+	if err != nil {
+		s.log.Error(op, "error", err)
+		return sub, err
+	}
+	return sub, nil
+}
+
+func (s WebhookService) List(ctx context.Context) ([]WebhookSubscription, error) {
+	return s.store.ListWebhooks(ctx)
+}
+
+func (s WebhookService) Unsubscribe(ctx context.Context, id WebhookID) error {
+	return s.store.DeleteWebhook(ctx, id)
+}
+
+// Deliveries - журнал доставок подписки, status опциональный
+func (s WebhookService) Deliveries(ctx context.Context, id WebhookID, status string, page int, size int) ([]WebhookDelivery, error) {
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = DefaultLeaderboardSize
+	}
+	if page < 0 || size < 0 || size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidWebhook, MaxLeaderboardSize)
+	}
+	switch status {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: status must be one of pending, delivered, dead", ErrInvalidWebhook)
+	}
+	return s.store.GetDeliveries(ctx, id, status, page, size)
+}
+
+// Publish ставит событие в очередь доставки всем подписчикам, сама отправка происходит в Run
+func (s WebhookService) Publish(ctx context.Context, event Event) error {
+	const op = "WebhookService.Publish"
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.store.EnqueueDeliveries(ctx, event, payload); err != nil {
+		s.log.Error(op, "event_id", event.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Run - воркер доставки, раз в interval отправляет всё что готово к отправке, до отмены ctx
+func (s WebhookService) Run(ctx context.Context, interval time.Duration) {
+	const op = "WebhookService.Run"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.DeliverDue(ctx); err != nil {
+			s.log.Error(op, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue отправляет одну пачку готовых доставок
+func (s WebhookService) DeliverDue(ctx context.Context) error {
+	const op = "WebhookService.DeliverDue"
+	lease := s.cfg.Webhooks.Timeout * 2
+	deliveries, err := s.store.ClaimDeliveries(ctx, s.cl.Now(), lease, webhookBatch)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		if err := s.deliver(ctx, d); err != nil {
+			s.log.Error(op, "delivery_id", d.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s WebhookService) deliver(ctx context.Context, d WebhookDelivery) error {
+	const op = "WebhookService.deliver"
+	sendCtx, cancel := context.WithTimeout(ctx, s.cfg.Webhooks.Timeout)
+	code, err := s.sender.Send(sendCtx, d)
+	cancel()
+	now := s.cl.Now()
+	if err == nil && code >= 200 && code < 300 {
+		return s.store.MarkDelivered(ctx, d.ID, code, now)
+	}
+	lastErr := fmt.Sprintf("unexpected status code %d", code)
+	if err != nil {
+		lastErr = err.Error()
+	}
+	attempts := d.Attempts + 1
+	if attempts >= s.cfg.Webhooks.MaxAttempts {
+		s.log.Warn(op+": delivery moved to dead letters", "delivery_id", d.ID, "attempts", attempts, "error", lastErr)
+		return s.store.MarkDead(ctx, d.ID, code, lastErr, now)
+	}
+	return s.store.ScheduleRetry(ctx, d.ID, code, lastErr, now.Add(s.backoff(attempts)))
+}
+
+// backoff - задержка перед попыткой attempts+1
+func (s WebhookService) backoff(attempts int) time.Duration {
+	return expBackoff(s.cfg.Webhooks.BackoffBase, s.cfg.Webhooks.BackoffMax, attempts)
+}
+
+// expBackoff - экспоненциальная задержка после attempts неудачных попыток: base, 2*base, 4*base ... но не больше max
+func expBackoff(base time.Duration, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
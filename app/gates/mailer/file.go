@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"app/domain"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// File - каждое письмо сохраняется отдельным .eml файлом в dir, для локальной разработки и тестов
+type File struct {
+	dir  string
+	from string
+	seq  atomic.Int64 // письма в одну наносекунду не перезаписывают друг друга
+}
+
+func NewFile(dir string, from string) *File {
+	return &File{dir: dir, from: from}
+}
+
+func (m *File) Send(ctx context.Context, mail domain.Mail) error {
+	const op = "mailer.File.Send"
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	//имя файла - время и получатель, чтобы письма было удобно искать
+	to := strings.NewReplacer("/", "_", "\\", "_").Replace(string(mail.To))
+	name := fmt.Sprintf("%d_%d_%s.eml", time.Now().UnixNano(), m.seq.Add(1), to)
+	if err := os.WriteFile(filepath.Join(m.dir, name), message(m.from, mail), 0o644); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"app/domain"
+	"context"
+	"log/slog"
+)
+
+// Log - письма не отправляются, а пишутся в лог, для локальной разработки
+type Log struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *Log {
+	return &Log{log: log}
+}
+
+func (m *Log) Send(ctx context.Context, mail domain.Mail) error {
+	m.log.Info("mailer.Log.Send: mail", "to", mail.To, "subject", mail.Subject, "body", mail.Body)
+	return nil
+}
@@ -0,0 +1,107 @@
+package mailer
+
+import (
+	"app/domain"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTP - отправка писем через smtp сервер с PLAIN авторизацией (STARTTLS, если сервер его поддерживает)
+type SMTP struct {
+	host    string
+	addr    string
+	auth    smtp.Auth // nil - без авторизации
+	from    string
+	timeout time.Duration
+}
+
+func NewSMTP(host string, port int, username string, password string, from string, timeout time.Duration) *SMTP {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTP{host: host, addr: net.JoinHostPort(host, strconv.Itoa(port)), auth: auth, from: from, timeout: timeout}
+}
+
+// Send - то же, что smtp.SendMail, но соединение открывается с контекстом, а на весь диалог с сервером ставится
+// дедлайн: не позже timeout и дедлайна ctx, чтобы зависший сервер не держал запрос
+func (m *SMTP) Send(ctx context.Context, mail domain.Mail) error {
+	const op = "mailer.SMTP.Send"
+	deadline := time.Now().Add(m.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer c.Close()
+	if err := m.send(c, mail); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (m *SMTP) send(c *smtp.Client, mail domain.Mail) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(m.auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(string(mail.To)); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message(m.from, mail)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message - письмо в формате RFC 5322, тема кодируется для не ASCII символов
+func message(from string, mail domain.Mail) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(mail.Body)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
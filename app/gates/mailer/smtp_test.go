@@ -0,0 +1,41 @@
+package mailer_test
+
+import (
+	"app/domain"
+	"app/gates/mailer"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSMTPSendTimesOutOnSilentServer(t *testing.T) {
+	//сервер принимает соединение, но не отправляет приветствие
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	m := mailer.NewSMTP(host, p, "", "", "noreply@example.com", 100*time.Millisecond)
+
+	start := time.Now()
+	err = m.Send(context.Background(), domain.Mail{To: "user@example.com", Subject: "Verify", Body: "link"})
+	if err == nil {
+		t.Fatal("Send() to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Send() returned after %s, want about the 100ms timeout", elapsed)
+	}
+}
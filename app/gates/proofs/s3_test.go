@@ -0,0 +1,131 @@
+package proofs_test
+
+import (
+	"app/domain"
+	"app/gates/proofs"
+	"app/iternal/pkg"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	accessKey = "AKIDEXAMPLE"
+	secretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	region    = "eu-central-1"
+)
+
+// fakeS3 - хранилище объектов в памяти, которое как S3 проверяет подпись запроса по своей копии секрета
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	paths   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.URL.EscapedPath())
+	if err := f.verify(r); err != "" {
+		f.t.Errorf("%s %s: %s", r.Method, r.URL, err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify - проверка AWS Signature V4 со стороны сервера, пустая строка - подпись верна
+func (f *fakeS3) verify(r *http.Request) string {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate != "20261019T120000Z" {
+		return "X-Amz-Date = " + amzDate
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		return "X-Amz-Content-Sha256 = " + r.Header.Get("X-Amz-Content-Sha256")
+	}
+	scope := "20261019/" + region + "/s3/aws4_request"
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		"host:" + r.Host + "\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{"20261019", region, "s3", "aws4_request"} {
+		key = sum(key, part)
+	}
+	signature := hex.EncodeToString(sum(key, "AWS4-HMAC-SHA256\n"+amzDate+"\n"+scope+"\n"+hex.EncodeToString(hashed[:])))
+	want := "AWS4-HMAC-SHA256 Credential=" + accessKey + "/" + scope + ", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=" + signature
+	if got := r.Header.Get("Authorization"); got != want {
+		return "Authorization = " + got + ", want " + want
+	}
+	return ""
+}
+
+func sum(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cl := pkg.StubClock{Time: time.Date(2026, 10, 19, 15, 0, 0, 0, time.FixedZone("MSK", 3*60*60))}
+	s3 := proofs.NewS3(server.Client(), server.URL+"/", region, "proofs-bucket", accessKey, secretKey, cl)
+	ctx := context.Background()
+	key := "proofs/7/steps screenshot.png"
+	body := "\x89PNG fake image"
+
+	if err := s3.Put(ctx, key, domain.Proof{Body: strings.NewReader(body), Size: int64(len(body)), ContentType: "image/png"}); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := s3.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != body {
+		t.Fatalf("Open() = %q, want %q", got, body)
+	}
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Open(ctx, key); err == nil {
+		t.Fatal("Open() after Delete succeeded")
+	}
+	//path-style адресация: бакет первым сегментом пути, ключ экранирован
+	for _, path := range fake.paths {
+		if path != "/proofs-bucket/proofs/7/steps%20screenshot.png" {
+			t.Fatalf("request path %s, want path-style /proofs-bucket/<key>", path)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"app/domain"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// полученные пользователем достижения, названия и описания берутся из конфига
+func (s Server) achievementsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.achievementsHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	achievements, err := s.srv.Achievements(s.context, domain.UserID(id))
+	if errors.Is(err, domain.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]achievement, 0, len(achievements))
+	for _, a := range achievements {
+		def := s.cfg.Achievements[a.Key]
+		resp = append(resp, achievement{Key: a.Key, Title: def.Title, Description: def.Description, Points: a.Points, EarnedAt: a.EarnedAt})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
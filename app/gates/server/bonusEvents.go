@@ -0,0 +1,91 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// tasksHandler - каталог заданий с наградами и действующими сейчас множителями
+func (s Server) tasksHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.tasksHandler"
+	tasks, err := s.srv.Tasks(s.context)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]task, 0, len(tasks))
+	for _, t := range tasks {
+		resp = append(resp, task(t))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// бонусные события, active=true - только идущие сейчас
+func (s Server) listBonusEventsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.listBonusEventsHandler"
+	events, err := s.bonuses.List(s.context, r.URL.Query().Get("active") == "true")
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]bonusEvent, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, bonusEventFromDomain(e))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s Server) createBonusEventHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.createBonusEventHandler"
+	var req bonusEvent
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := s.bonuses.Add(s.context, domain.BonusEvent{
+		Name:       req.Name,
+		Multiplier: req.Multiplier,
+		Tasks:      req.Tasks,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+	})
+	switch {
+	case errors.Is(err, domain.ErrInvalidBonusEvent):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrNotExistingReward):
+		http.Error(w, "Invalid request: unknown task in tasks", http.StatusBadRequest)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, bonusEventFromDomain(created))
+}
+
+func (s Server) deleteBonusEventHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.deleteBonusEventHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Bonus event ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	err = s.bonuses.Delete(s.context, domain.BonusEventID(id))
+	if errors.Is(err, domain.ErrBonusEventNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
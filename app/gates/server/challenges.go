@@ -0,0 +1,145 @@
+package server
+
+import (
+	"app/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// createChallengeHandler - вызов другого пользователя, ставка списывается с пользователя из токена сразу
+func (s Server) createChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.createChallengeHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	var req challengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := s.challenges.Create(s.context, user.ID, req.OpponentID, req.Task, req.Stake, req.EndsAt)
+	if s.challengeError(w, op, err) {
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, challenge(created))
+}
+
+// challengesHandler - вызовы пользователя из токена, опционально status, page, size
+func (s Server) challengesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.challengesHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	challenges, err := s.challenges.List(s.context, user.ID, r.URL.Query().Get("status"), page, size)
+	if s.challengeError(w, op, err) {
+		return
+	}
+	resp := make([]challenge, 0, len(challenges))
+	for _, c := range challenges {
+		resp = append(resp, challenge(c))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s Server) challengeHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.challengeHandler"
+	id, ok := challengeID(w, r)
+	if !ok {
+		return
+	}
+	c, err := s.challenges.Get(s.context, id)
+	if s.challengeError(w, op, err) {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, challenge(c))
+}
+
+func (s Server) acceptChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondChallenge(w, r, s.challenges.Accept)
+}
+
+func (s Server) declineChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondChallenge(w, r, s.challenges.Decline)
+}
+
+func (s Server) cancelChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondChallenge(w, r, s.challenges.Cancel)
+}
+
+// respondChallenge - действие пользователя из токена с вызовом {id}
+func (s Server) respondChallenge(w http.ResponseWriter, r *http.Request, respond func(ctx context.Context, id domain.ChallengeID, userID domain.UserID) (domain.Challenge, error)) {
+	const op = "gates.server.respondChallenge"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	id, ok := challengeID(w, r)
+	if !ok {
+		return
+	}
+	c, err := respond(s.context, id, user.ID)
+	if s.challengeError(w, op, err) {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, challenge(c))
+}
+
+// adminCancelChallengeHandler - отмена любого открытого вызова с возвратом ставок обоим
+func (s Server) adminCancelChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.adminCancelChallengeHandler"
+	id, ok := challengeID(w, r)
+	if !ok {
+		return
+	}
+	c, err := s.challenges.AdminCancel(s.context, id)
+	if s.challengeError(w, op, err) {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, challenge(c))
+}
+
+func challengeID(w http.ResponseWriter, r *http.Request) (domain.ChallengeID, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Challenge ID must consist of numbers only", http.StatusBadRequest)
+		return 0, false
+	}
+	return domain.ChallengeID(id), true
+}
+
+// challengeError отвечает на ошибку сервиса вызовов, false - ошибки нет
+func (s Server) challengeError(w http.ResponseWriter, op string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, domain.ErrInvalidChallenge):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrChallengesDisabled), errors.Is(err, domain.ErrNotChallengeParticipant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrChallengeNotFound), errors.Is(err, domain.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrChallengeClosed), errors.Is(err, domain.ErrInsufficientPoints):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"app/domain"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// expiringPointsHandler - очки пользователя, которые скоро сгорят, опционально within (например 168h)
+func (s Server) expiringPointsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.expiringPointsHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	var within time.Duration
+	if v := r.URL.Query().Get("within"); v != "" {
+		var err error
+		if within, err = time.ParseDuration(v); err != nil {
+			http.Error(w, "Invalid request: within must be a duration, e.g. 168h", http.StatusBadRequest)
+			return
+		}
+	}
+	expiring, err := s.expiration.Expiring(s.context, user.ID, within)
+	if errors.Is(err, domain.ErrInvalidExpiringQuery) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, expiringFromDomain(expiring))
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"app/domain"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// maxRequestBody - сколько тела запроса читается в память ради отпечатка на маршрутах с JSON телом
+const maxRequestBody = 1 << 20
+
+// recorder - копирует ответ обработчика, чтобы сохранить его для повторов
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware - поддержка заголовка Idempotency-Key для изменяющих запросов, ставится после AuthMiddleware.
+// Запросы без заголовка и GET проходят как обычно. maxBody - максимальный размер тела запроса на маршруте
+func (s Server) IdempotencyMiddleware(maxBody int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return s.idempotent(next, maxBody)
+	}
+}
+
+func (s Server) idempotent(next http.Handler, maxBody int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "gates.server.idempotencyMiddleware"
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, ok := userFromContext(r.Context())
+		if !ok {
+			s.log.Error(op + ": user not found in context")
+			http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+			return
+		}
+		//тело читаем целиком: оно входит в отпечаток запроса и потом отдаётся обработчику
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		if err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		saved, err := s.idempotency.Acquire(r.Context(), user.ID, key, fingerprint)
+		switch {
+		case errors.Is(err, domain.ErrInvalidIdempotencyKey):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrIdempotencyInFlight):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, domain.ErrIdempotencyKeyReused):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if saved != nil {
+			s.log.Debug(op+": replaying saved response", "user_id", user.ID, "key", key)
+			if saved.ContentType != "" {
+				w.Header().Set("Content-Type", saved.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(saved.StatusCode)
+			w.Write(saved.Body)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		//если обработчик упал или ответил 5xx - освобождаем ключ, чтобы повтор выполнился заново.
+		//Ответ ниже 500 уже означает выполненный запрос: если его не удалось сохранить, ключ остаётся занятым
+		//до lock_timeout, а не освобождается под повторное выполнение
+		completed := false
+		defer func() {
+			if !completed {
+				if err := s.idempotency.Release(context.Background(), user.ID, key); err != nil {
+					s.log.Error(op, "error", err)
+				}
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= 500 {
+			return
+		}
+		completed = true
+		resp := domain.IdempotentResponse{StatusCode: rec.status, ContentType: w.Header().Get("Content-Type"), Body: rec.body.Bytes()}
+		if err := s.idempotency.Save(context.Background(), user.ID, key, resp); err != nil {
+			s.log.Error(op, "user_id", user.ID, "status", strconv.Itoa(rec.status), "error", err)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package server_test
+
+import (
+	"app/domain"
+	"app/gates/server"
+	"app/iternal/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idempotencyEntry - занятый ключ, resp заполнен после сохранения ответа
+type idempotencyEntry struct {
+	fingerprint string
+	resp        *domain.IdempotentResponse
+}
+
+// memStorage - пользователь для авторизации и ключи идемпотентности в памяти, как их хранит PostgreSQL
+type memStorage struct {
+	server.Storage
+	user domain.User
+	mu   sync.Mutex
+	keys map[string]*idempotencyEntry
+}
+
+func (m *memStorage) GetUser(ctx context.Context, id domain.UserID) (domain.User, error) {
+	if id != m.user.ID {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return m.user, nil
+}
+
+func (m *memStorage) AcquireIdempotencyKey(ctx context.Context, userID domain.UserID, key string, fingerprint string, now time.Time, expiresAt time.Time, lockTimeout time.Duration) (*domain.IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.keys[fmt.Sprintf("%d/%s", userID, key)]
+	switch {
+	case !ok:
+		m.keys[fmt.Sprintf("%d/%s", userID, key)] = &idempotencyEntry{fingerprint: fingerprint}
+		return nil, nil
+	case entry.fingerprint != fingerprint:
+		return nil, domain.ErrIdempotencyKeyReused
+	case entry.resp == nil:
+		return nil, domain.ErrIdempotencyInFlight
+	}
+	return entry.resp, nil
+}
+
+func (m *memStorage) SaveIdempotentResponse(ctx context.Context, userID domain.UserID, key string, resp domain.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[fmt.Sprintf("%d/%s", userID, key)].resp = &resp
+	return nil
+}
+
+func (m *memStorage) ReleaseIdempotencyKey(ctx context.Context, userID domain.UserID, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.keys[fmt.Sprintf("%d/%s", userID, key)]; ok && entry.resp == nil {
+		delete(m.keys, fmt.Sprintf("%d/%s", userID, key))
+	}
+	return nil
+}
+
+// idempotencyRouter - тестовый маршрут POST /orders за AuthMiddleware и IdempotencyMiddleware, handler задаёт тест.
+// Возвращает роутер и токен пользователя
+func idempotencyRouter(t *testing.T, handler http.HandlerFunc) (*chi.Mux, *memStorage, string) {
+	store := &memStorage{user: domain.User{ID: 1, Nickname: "alice", Email: "alice@example.com"}, keys: map[string]*idempotencyEntry{}}
+	cfg := &config.Config{Idempotency: config.Idempotency{TTL: time.Hour, LockTimeout: time.Minute}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r := chi.NewRouter()
+	s := server.NewServer(store, cfg, log, r, nil, nil, nil, nil, nil)
+	r.With(s.AuthMiddleware, s.IdempotencyMiddleware(1<<10)).Post("/orders", handler)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login/1", nil))
+	var token string
+	if err := json.Unmarshal(rec.Body.Bytes(), &token); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+	return r, store, token
+}
+
+func post(r http.Handler, token string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+	r, _, token := idempotencyRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"order":%d}`, calls)
+	})
+	first := post(r, token, "k1", `{"item":1}`)
+	second := post(r, token, "k1", `{"item":1}`)
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want once", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() || second.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("replay = %d %q (%s), want %d %q", second.Code, second.Body, second.Header().Get("Content-Type"), first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("only the replay must be marked with Idempotent-Replayed")
+	}
+	//без ключа запрос выполняется каждый раз
+	post(r, token, "", `{"item":1}`)
+	if calls != 2 {
+		t.Fatalf("request without key called handler %d times in total, want 2", calls)
+	}
+}
+
+func TestIdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	r, _, token := idempotencyRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	post(r, token, "k1", `{"item":1}`)
+	if rec := post(r, token, "k1", `{"item":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("same key with another body = %d, want 422", rec.Code)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	r, _, token := idempotencyRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	})
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- post(r, token, "k1", `{"item":1}`)
+	}()
+	<-started
+	if rec := post(r, token, "k1", `{"item":1}`); rec.Code != http.StatusConflict {
+		t.Fatalf("retry while the first request runs = %d, want 409", rec.Code)
+	}
+	close(finish)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("first request = %d, want 201", rec.Code)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		fail func(w http.ResponseWriter)
+	}{
+		{"5xx", func(w http.ResponseWriter) { http.Error(w, "database is down", http.StatusServiceUnavailable) }},
+		{"panic", func(w http.ResponseWriter) { panic("handler bug") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			r, store, token := idempotencyRouter(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					tt.fail(w)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+			})
+			func() {
+				defer func() {
+					//паника обработчика доходит до сервера, ключ к этому моменту уже освобождён
+					if p := recover(); p != nil && p != "handler bug" {
+						panic(p)
+					}
+				}()
+				post(r, token, "k1", `{"item":1}`)
+			}()
+			if len(store.keys) != 0 {
+				t.Fatalf("key kept after failed request: %+v", store.keys)
+			}
+			if rec := post(r, token, "k1", `{"item":1}`); rec.Code != http.StatusCreated || calls != 2 {
+				t.Fatalf("retry after failure = %d with %d calls, want 201 from a second call", rec.Code, calls)
+			}
+		})
+	}
+}
@@ -3,25 +3,26 @@ package server
 import (
 	"app/domain"
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 func (s Server) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		const op = "gates.server.authMiddleware"
-		s.log.Info(op, ": starting auth")
+		s.log.Info(op + ": starting auth")
 		authHeader := r.Header.Get("Authorization")
-		s.log.Debug("auth header: ", authHeader)
 		if authHeader == "" {
 			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
-			s.log.Debug(op, ": no auth header")
+			s.log.Debug(op + ": no auth header")
 			return
 		}
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			s.log.Debug(op, ": invalid auth header format")
+			s.log.Debug(op + ": invalid auth header format")
 			http.Error(w, "invalid Authorization header format", http.StatusUnauthorized)
 			return
 		}
@@ -33,15 +34,53 @@ func (s Server) AuthMiddleware(next http.Handler) http.Handler {
 			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
 			return
 		}
-		s.log.Debug(op, ": Successfully got token thru auth.Authorize for user: ", user.ID)
+		s.log.Debug(op+": successfully authorized", "user_id", user.ID)
 		// Добавляем пользователя в контекст
 		ctx := context.WithValue(r.Context(), userContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// AdminMiddleware - пропускает только пользователей из admin.user_ids с секретом admin.token в заголовке X-Admin-Token,
+// ставится после AuthMiddleware. Токен пользователя выдаётся по id, поэтому одного списка id для доступа мало
+func (s Server) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "gates.server.adminMiddleware"
+		user, ok := userFromContext(r.Context())
+		if !ok {
+			s.log.Error(op + ": user not found in context")
+			http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+			return
+		}
+		if !slices.Contains(s.cfg.Admin.UserIDs, int64(user.ID)) || !s.validAdminToken(r.Header.Get("X-Admin-Token")) {
+			s.log.Debug(op+": not an admin", "user_id", user.ID)
+			http.Error(w, "You don't have permission", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAdminToken - сравнение с admin.token за постоянное время, пустой admin.token не подходит ни к чему
+func (s Server) validAdminToken(token string) bool {
+	secret := s.cfg.Admin.Token
+	return secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
 // FromContext - извлекает пользователя из контекста
 func userFromContext(ctx context.Context) (domain.User, bool) {
 	user, ok := ctx.Value(userContextKey).(domain.User)
 	return user, ok
 }
+
+// StreamAuthMiddleware - то же что AuthMiddleware, но токен можно передать и в query параметре access_token,
+// потому что браузерный EventSource не умеет выставлять заголовки
+func (s Server) StreamAuthMiddleware(next http.Handler) http.Handler {
+	auth := s.AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		auth.ServeHTTP(w, r)
+	})
+}
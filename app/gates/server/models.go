@@ -2,6 +2,7 @@ package server
 
 import (
 	"app/domain"
+	"encoding/json"
 	"time"
 )
 
@@ -12,6 +13,29 @@ type user struct {
 	Score      domain.UserScore `json:"Score"`
 	Registered time.Time        `json:"register_date"`
 	invitedBy  *domain.UserID   `json:"invited_by,omitempty"` //omitempty потому что поле может быть пустым + ни к чему в leaderboard
+	Rank       int64            `json:"rank,omitempty"`       //место в рейтинге, заполняется только в leaderboard и rank
+	Timezone   *string          `json:"timezone,omitempty"`   //часовой пояс, передаётся при регистрации
+	Level      int              `json:"level,omitempty"`      //уровень, заполняется только в leaderboard и rank
+}
+
+// entryFromDomain - публичное представление записи лидерборды (без email и информации о приглашении)
+func entryFromDomain(entry domain.LeaderboardEntry) user {
+	return user{
+		Id:         entry.ID,
+		Nickname:   entry.Nickname,
+		Score:      entry.Score,
+		Registered: entry.Registered,
+		Rank:       entry.Rank,
+		Level:      entry.Level,
+	}
+}
+
+func entriesFromDomain(entries []domain.LeaderboardEntry) []user {
+	resp := make([]user, 0, len(entries))
+	for _, entry := range entries {
+		resp = append(resp, entryFromDomain(entry))
+	}
+	return resp
 }
 
 func (u *user) toDomain() domain.User {
@@ -22,6 +46,7 @@ func (u *user) toDomain() domain.User {
 		Score:      u.Score,
 		Registered: u.Registered,
 		InvitedBy:  u.invitedBy,
+		Timezone:   u.Timezone,
 	}
 }
 
@@ -40,19 +65,446 @@ type contextKey string
 
 const userContextKey contextKey = "user"
 
-// Структура для чтения JSON с насстройками лидерборды, она необязательна, по умолчанию лидерборда выводится сортируясь по id
-type LeaderboardSettings struct {
-	SortBy string `json:"sort_by"` //score,id,nickname
-	Page   int    `json:"page"`
-	Size   int    `json:"size"`
+// Ответ лидерборды: страница пользователей, курсор на следующую страницу (пустой если страница последняя) и общее кол-во
+type leaderboardResponse struct {
+	Items      []user `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// Ответ на запрос места пользователя
+type rankResponse struct {
+	User       user    `json:"user"`
+	Percentile float64 `json:"percentile"`
+	Total      int     `json:"total"`
+	Above      []user  `json:"above"`
+	Below      []user  `json:"below"`
 }
 
 // структура для чтения JSON в которую пишется выполенный таск
 type TaskRequest struct {
-	Task string `json:"task"`
+	Task    string `json:"task"`
+	Account string `json:"account"` // аккаунт во внешнем сервисе для заданий с проверкой (id в Telegram, имя в X/Twitter)
 }
 
 // структура для чтения JSON referrerHandler, считывает "кто пригласил"
 type RefRequest struct {
 	ID string `json:"referrer"`
 }
+
+// структура ответа на конфликт при регистрации, в field пишется поле которое уже занято
+type conflictResponse struct {
+	Error string `json:"error"`
+	Field string `json:"field"`
+}
+
+type season struct {
+	ID       domain.SeasonID `json:"id"`
+	Name     string          `json:"name"`
+	StartsAt time.Time       `json:"starts_at"`
+	EndsAt   time.Time       `json:"ends_at"`
+	ClosedAt *time.Time      `json:"closed_at,omitempty"`
+}
+
+func seasonFromDomain(dseason domain.Season) season {
+	return season{
+		ID:       dseason.ID,
+		Name:     dseason.Name,
+		StartsAt: dseason.StartsAt,
+		EndsAt:   dseason.EndsAt,
+		ClosedAt: dseason.ClosedAt,
+	}
+}
+
+type seasonStanding struct {
+	UserID   domain.UserID    `json:"user_id"`
+	Nickname domain.Nickname  `json:"nickname"`
+	Rank     int64            `json:"rank"`
+	Score    domain.UserScore `json:"score"`
+	Prize    int              `json:"prize"`
+}
+
+// структура для чтения JSON при создании подписки на вебхуки, events пустой - подписка на все события
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+type webhook struct {
+	ID        domain.WebhookID `json:"id"`
+	URL       string           `json:"url"`
+	Secret    string           `json:"secret,omitempty"` //секрет показывается только при создании
+	Events    []string         `json:"events"`
+	Active    bool             `json:"active"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func webhookFromDomain(sub domain.WebhookSubscription) webhook {
+	return webhook{
+		ID:        sub.ID,
+		URL:       sub.URL,
+		Events:    sub.Events,
+		Active:    sub.Active,
+		CreatedAt: sub.CreatedAt,
+	}
+}
+
+type webhookDelivery struct {
+	ID             domain.DeliveryID `json:"id"`
+	EventID        string            `json:"event_id"`
+	EventType      string            `json:"event_type"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastStatusCode *int              `json:"last_status_code,omitempty"`
+	LastError      *string           `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+}
+
+// структура для чтения JSON заявки со ссылкой на доказательство (файл загружается через multipart/form-data)
+type submissionRequest struct {
+	Task string `json:"task"`
+	URL  string `json:"url"`
+}
+
+type rejectRequest struct {
+	Comment string `json:"comment"`
+}
+
+type submission struct {
+	ID         domain.SubmissionID `json:"id"`
+	UserID     domain.UserID       `json:"user_id"`
+	Task       string              `json:"task"`
+	Points     int                 `json:"points"`
+	Multiplier float64             `json:"multiplier"`
+	ProofURL   *string             `json:"proof_url,omitempty"`
+	HasFile    bool                `json:"has_file"`
+	Status     string              `json:"status"`
+	Comment    *string             `json:"comment,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	ReviewedAt *time.Time          `json:"reviewed_at,omitempty"`
+}
+
+func submissionFromDomain(sub domain.Submission) submission {
+	return submission{
+		ID:         sub.ID,
+		UserID:     sub.UserID,
+		Task:       sub.Task,
+		Points:     sub.Points,
+		Multiplier: sub.Multiplier,
+		ProofURL:   sub.ProofURL,
+		HasFile:    sub.ProofKey != nil,
+		Status:     sub.Status,
+		Comment:    sub.Comment,
+		CreatedAt:  sub.CreatedAt,
+		ReviewedAt: sub.ReviewedAt,
+	}
+}
+
+func submissionsFromDomain(subs []domain.Submission) []submission {
+	resp := make([]submission, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, submissionFromDomain(sub))
+	}
+	return resp
+}
+
+// ответ status: пользователь, его уровень и серии по ежедневным заданиям
+type statusResponse struct {
+	domain.User
+	Level   domain.Level `json:"level"`
+	Streaks []streak     `json:"streaks"`
+}
+
+type streak struct {
+	Task    string  `json:"task"`
+	Current int     `json:"current"`
+	Longest int     `json:"longest"`
+	LastDay *string `json:"last_day,omitempty"` // YYYY-MM-DD в часовом поясе пользователя
+}
+
+func streaksFromDomain(streaks []domain.Streak) []streak {
+	resp := make([]streak, 0, len(streaks))
+	for _, st := range streaks {
+		item := streak{Task: st.Task, Current: st.Current, Longest: st.Longest}
+		if st.LastDay != nil {
+			day := st.LastDay.Format(time.DateOnly)
+			item.LastDay = &day
+		}
+		resp = append(resp, item)
+	}
+	return resp
+}
+
+// квест и прогресс пользователя в текущем периоде
+type quest struct {
+	Key       string   `json:"key"`
+	Title     string   `json:"title"`
+	Steps     []string `json:"steps"`
+	Period    string   `json:"period"`
+	Strict    bool     `json:"strict"`
+	Bonus     int      `json:"bonus"`
+	Step      int      `json:"step"` // кол-во выполненных шагов
+	Done      []string `json:"done"` // выполненные задания, в нестрогих квестах - в любом порядке
+	Next      string   `json:"next,omitempty"`
+	Completed bool     `json:"completed"`
+}
+
+type achievement struct {
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Points      int       `json:"points"`
+	EarnedAt    time.Time `json:"earned_at"`
+}
+
+// структура для чтения JSON товара, active по умолчанию true
+type shopItemRequest struct {
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Cost         int        `json:"cost"`
+	Stock        *int       `json:"stock"`
+	PerUserLimit *int       `json:"per_user_limit"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       *bool      `json:"active"`
+}
+
+func (req shopItemRequest) toDomain() domain.ShopItem {
+	item := domain.ShopItem{
+		Name:         req.Name,
+		Description:  req.Description,
+		Cost:         req.Cost,
+		Stock:        req.Stock,
+		PerUserLimit: req.PerUserLimit,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		Active:       true,
+	}
+	if req.Active != nil {
+		item.Active = *req.Active
+	}
+	return item
+}
+
+type shopItem struct {
+	ID           domain.ShopItemID `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	Cost         int               `json:"cost"`
+	Stock        *int              `json:"stock,omitempty"` // нет поля - без ограничения остатка
+	PerUserLimit *int              `json:"per_user_limit,omitempty"`
+	StartsAt     *time.Time        `json:"starts_at,omitempty"`
+	EndsAt       *time.Time        `json:"ends_at,omitempty"`
+	Active       bool              `json:"active"`
+}
+
+func shopItemFromDomain(item domain.ShopItem) shopItem {
+	return shopItem{
+		ID:           item.ID,
+		Name:         item.Name,
+		Description:  item.Description,
+		Cost:         item.Cost,
+		Stock:        item.Stock,
+		PerUserLimit: item.PerUserLimit,
+		StartsAt:     item.StartsAt,
+		EndsAt:       item.EndsAt,
+		Active:       item.Active,
+	}
+}
+
+func shopItemsFromDomain(items []domain.ShopItem) []shopItem {
+	resp := make([]shopItem, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, shopItemFromDomain(item))
+	}
+	return resp
+}
+
+type orderRequest struct {
+	ItemID   domain.ShopItemID `json:"item_id"`
+	Quantity int               `json:"quantity"` // по умолчанию 1
+}
+
+type order struct {
+	ID        domain.OrderID    `json:"id"`
+	UserID    domain.UserID     `json:"user_id"`
+	ItemID    domain.ShopItemID `json:"item_id"`
+	Quantity  int               `json:"quantity"`
+	Cost      int               `json:"cost"`
+	Status    string            `json:"status"`
+	Comment   *string           `json:"comment,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ClosedAt  *time.Time        `json:"closed_at,omitempty"`
+}
+
+func orderFromDomain(o domain.Order) order {
+	return order{
+		ID:        o.ID,
+		UserID:    o.UserID,
+		ItemID:    o.ItemID,
+		Quantity:  o.Quantity,
+		Cost:      o.Cost,
+		Status:    o.Status,
+		Comment:   o.Comment,
+		CreatedAt: o.CreatedAt,
+		ClosedAt:  o.ClosedAt,
+	}
+}
+
+func ordersFromDomain(orders []domain.Order) []order {
+	resp := make([]order, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, orderFromDomain(o))
+	}
+	return resp
+}
+
+type transferRequest struct {
+	To      domain.UserID `json:"to_user_id"`
+	Amount  int           `json:"amount"`
+	Comment string        `json:"comment"`
+}
+
+type transfer struct {
+	ID         domain.TransferID `json:"id"`
+	FromUserID domain.UserID     `json:"from_user_id"`
+	ToUserID   domain.UserID     `json:"to_user_id"`
+	Amount     int               `json:"amount"`
+	Fee        int               `json:"fee"`
+	Comment    *string           `json:"comment,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// запись истории баланса, reason - задание, достижение или операция (shop_order:1, transfer_in:2 и т.д.)
+type transaction struct {
+	Points     int       `json:"points"`
+	Reason     string    `json:"reason"`
+	Multiplier float64   `json:"multiplier"`
+	At         time.Time `json:"created_at"`
+}
+
+// очки, которые сгорят до before, по партиям от старых к новым
+type expiringPoints struct {
+	Total   int          `json:"total"`
+	Before  time.Time    `json:"before"`
+	Batches []pointBatch `json:"batches"`
+}
+
+type pointBatch struct {
+	Points    int       `json:"points"` // сколько сгорит
+	Earned    int       `json:"earned"` // сколько было начислено
+	EarnedAt  time.Time `json:"earned_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func expiringFromDomain(exp domain.ExpiringPoints) expiringPoints {
+	resp := expiringPoints{Total: exp.Total, Before: exp.Before, Batches: make([]pointBatch, 0, len(exp.Batches))}
+	for _, b := range exp.Batches {
+		resp.Batches = append(resp.Batches, pointBatch{Points: b.Remaining, Earned: b.Points, EarnedAt: b.EarnedAt, ExpiresAt: b.ExpiresAt})
+	}
+	return resp
+}
+
+// задание в каталоге, multiplier и bonus_points учитывают идущие сейчас бонусные события
+type task struct {
+	Task        string                `json:"task"`
+	Points      int                   `json:"points"`
+	Multiplier  float64               `json:"multiplier"`
+	BonusPoints int                   `json:"bonus_points"`
+	Verifier    string                `json:"verifier"`
+	Streak      bool                  `json:"streak"`
+	BonusEvents []domain.BonusEventID `json:"bonus_events,omitempty"`
+}
+
+type bonusEvent struct {
+	ID         domain.BonusEventID `json:"id"`
+	Name       string              `json:"name"`
+	Multiplier float64             `json:"multiplier"`
+	Tasks      []string            `json:"tasks"` // пустой - все задания
+	StartsAt   time.Time           `json:"starts_at"`
+	EndsAt     time.Time           `json:"ends_at"`
+}
+
+func bonusEventFromDomain(e domain.BonusEvent) bonusEvent {
+	tasks := e.Tasks
+	if tasks == nil {
+		tasks = []string{}
+	}
+	return bonusEvent{ID: e.ID, Name: e.Name, Multiplier: e.Multiplier, Tasks: tasks, StartsAt: e.StartsAt, EndsAt: e.EndsAt}
+}
+
+type teamRequest struct {
+	Name    string `json:"name"`
+	MaxSize int    `json:"max_size"` // 0 - teams.max_size из конфига
+}
+
+type inviteRequest struct {
+	UserID domain.UserID `json:"user_id"`
+}
+
+type team struct {
+	ID        domain.TeamID `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   domain.UserID `json:"owner_id"`
+	MaxSize   int           `json:"max_size"`
+	CreatedAt time.Time     `json:"created_at"`
+	Members   []teamMember  `json:"members,omitempty"`
+}
+
+type teamMember struct {
+	UserID       domain.UserID   `json:"user_id"`
+	Nickname     domain.Nickname `json:"nickname"`
+	Contribution int64           `json:"contribution"` // очки, заработанные за время в команде
+	JoinedAt     time.Time       `json:"joined_at"`
+}
+
+type teamEntry struct {
+	ID      domain.TeamID `json:"id"`
+	Name    string        `json:"name"`
+	Members int           `json:"members"`
+	Score   int64         `json:"score"`
+	Rank    int64         `json:"rank"`
+}
+
+type challengeRequest struct {
+	OpponentID domain.UserID `json:"opponent_id"`
+	Task       string        `json:"task"`
+	Stake      int           `json:"stake"`
+	EndsAt     time.Time     `json:"ends_at"`
+}
+
+type challenge struct {
+	ID              domain.ChallengeID `json:"id"`
+	ChallengerID    domain.UserID      `json:"challenger_id"`
+	OpponentID      domain.UserID      `json:"opponent_id"`
+	Task            string             `json:"task"`
+	Stake           int                `json:"stake"`
+	Status          string             `json:"status"`
+	EndsAt          time.Time          `json:"ends_at"`
+	AcceptedAt      *time.Time         `json:"accepted_at,omitempty"`
+	WinnerID        *domain.UserID     `json:"winner_id,omitempty"` // нет у завершённого вызова - ничья
+	ChallengerCount int                `json:"challenger_count"`
+	OpponentCount   int                `json:"opponent_count"`
+	CreatedAt       time.Time          `json:"created_at"`
+	ClosedAt        *time.Time         `json:"closed_at,omitempty"`
+}
+
+// структура для чтения JSON изменений профиля, отсутствующие поля не меняются
+type profileRequest struct {
+	Nickname *domain.Nickname `json:"nickname"`
+	Email    *domain.Email    `json:"email"`
+	Timezone *string          `json:"timezone"` // пустая строка - пояс по умолчанию
+}
+
+type profileResponse struct {
+	domain.User
+	EmailVerificationSent bool `json:"email_verification_sent"` // новый email применится после перехода по ссылке из письма
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// updateProfileHandler - изменение никнейма, email и часового пояса своего аккаунта
+func (s Server) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.updateProfileHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	var req profileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	updated, sent, err := s.profiles.Update(s.context, user.ID, domain.ProfileUpdate(req))
+	switch {
+	case errors.Is(err, domain.ErrInvalidProfile), errors.Is(err, domain.ErrNotEmail), errors.Is(err, domain.ErrInvalidTimezone):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrNicknameTaken):
+		s.writeConflict(w, err, "nickname")
+		return
+	case errors.Is(err, domain.ErrEmailTaken):
+		s.writeConflict(w, err, "email")
+		return
+	case errors.Is(err, domain.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, profileResponse{User: updated, EmailVerificationSent: sent})
+}
+
+// deleteUserHandler - удаление своего аккаунта, персональные данные обезличиваются
+func (s Server) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.deleteUserHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	err := s.profiles.Delete(s.context, user.ID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendVerificationHandler - повторное письмо для подтверждения текущего email
+func (s Server) sendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.sendVerificationHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	err := s.profiles.SendVerification(s.context, user.ID)
+	switch {
+	case errors.Is(err, domain.ErrEmailAlreadyVerified):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, domain.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyEmailHandler - подтверждение email токеном из письма: POST с JSON {"token"} или переход по ссылке с ?token=
+func (s Server) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.verifyEmailHandler"
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var req verifyEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+	user, err := s.profiles.VerifyEmail(s.context, token)
+	switch {
+	case errors.Is(err, domain.ErrInvalidEmailToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrEmailTaken):
+		s.writeConflict(w, err, "email")
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, user)
+}
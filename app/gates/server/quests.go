@@ -0,0 +1,45 @@
+package server
+
+import (
+	"app/domain"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// questsHandler - все квесты с прогрессом пользователя, для ежедневных - за сегодняшний день пользователя
+func (s Server) questsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.questsHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	quests, err := s.srv.Quests(s.context, domain.UserID(id))
+	if errors.Is(err, domain.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]quest, 0, len(quests))
+	for _, q := range quests {
+		resp = append(resp, quest{
+			Key:       q.Key,
+			Title:     q.Quest.Title,
+			Steps:     q.Quest.Steps,
+			Period:    q.Quest.Period,
+			Strict:    q.Quest.Strict,
+			Bonus:     q.Quest.Bonus,
+			Step:      q.Step,
+			Done:      q.Done,
+			Next:      q.Next,
+			Completed: q.Completed,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
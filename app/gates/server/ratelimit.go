@@ -0,0 +1,54 @@
+package server
+
+import (
+	"app/iternal/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitMiddleware - лимит запросов группы эндпоинтов, для лимита по user ставится после AuthMiddleware.
+// Если ограничение выключено или группа не настроена - пропускает всё
+func (s Server) RateLimitMiddleware(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if s.limiter == nil {
+			return next
+		}
+		g, ok := s.limiter.Group(group)
+		if !ok {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const op = "gates.server.rateLimitMiddleware"
+			key := group + ":ip:" + s.limiter.ClientIP(r)
+			if g.Key == ratelimit.KeyUser {
+				if user, ok := userFromContext(r.Context()); ok {
+					key = fmt.Sprintf("%s:user:%d", group, user.ID)
+				}
+			}
+			res, err := s.limiter.Take(r.Context(), key, g.Limit, s.cl.Now())
+			if err != nil {
+				//хранилище лимитов недоступно - не блокируем пользователей
+				s.log.Error(op, "key", key, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				s.log.Debug(op+": rate limit exceeded", "key", key)
+				w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"app/domain"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+func (s Server) seasonsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.seasonsHandler"
+	s.log.Info(op + ": starting seasons")
+	seasons, err := s.seasons.List(s.context)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]season, 0, len(seasons))
+	for _, dseason := range seasons {
+		resp = append(resp, seasonFromDomain(dseason))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// итоговая таблица закрытого сезона, page и size опциональные
+func (s Server) standingsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.standingsHandler"
+	s.log.Info(op + ": starting standings")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Season ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	standings, err := s.seasons.Standings(s.context, domain.SeasonID(id), page, size)
+	switch {
+	case errors.Is(err, domain.ErrInvalidLeaderboardQuery):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrSeasonNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrSeasonNotClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]seasonStanding, 0, len(standings))
+	for _, st := range standings {
+		resp = append(resp, seasonStanding{
+			UserID:   st.UserID,
+			Nickname: st.Nickname,
+			Rank:     st.Rank,
+			Score:    st.Score,
+			Prize:    st.Prize,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
@@ -5,40 +5,163 @@ import (
 	"app/domain"
 	"app/iternal/config"
 	"app/iternal/pkg"
+	"app/iternal/ratelimit"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"log/slog"
 	"net/http"
 	"strconv"
 )
 
+// Storage - все хранилища, которые нужны сервисам сервера
+type Storage interface {
+	domain.UserStore
+	domain.SeasonStore
+	domain.WebhookStore
+	domain.OutboxStore
+	domain.SubmissionStore
+	domain.IdempotencyStore
+	domain.AchievementStore
+	domain.LevelStore
+	domain.ShopStore
+	domain.TransferStore
+	domain.ExpirationStore
+	domain.BonusEventStore
+	domain.TeamStore
+	domain.QuestStore
+	domain.ChallengeStore
+	domain.ProfileStore
+}
+
 type Server struct {
-	db      domain.UserStore
-	context context.Context
-	log     *slog.Logger
-	srv     *domain.UserService
-	auth    *auth.Service
+	db          Storage
+	context     context.Context
+	log         *slog.Logger
+	cfg         *config.Config
+	srv         *domain.UserService
+	seasons     *domain.SeasonService
+	webhooks    *domain.WebhookService
+	submissions *domain.SubmissionService
+	idempotency *domain.IdempotencyService
+	shop        *domain.ShopService
+	transfers   *domain.TransferService
+	expiration  *domain.PointsExpirer
+	bonuses     *domain.BonusEventService
+	teams       *domain.TeamService
+	challenges  *domain.ChallengeService
+	profiles    *domain.ProfileService
+	auth        *auth.Service
+	hub         *domain.ScoreHub
+	limiter     *ratelimit.Limiter // nil если ограничение частоты запросов выключено
+	cl          pkg.Clock
 }
 
-func NewServer(db domain.UserStore, cfg *config.Config, log *slog.Logger, r *chi.Mux) *Server {
+// board - кэш лидерборды, nil если кэш выключен, verifiers - проверяющие заданий по ключу задания,
+// proofs - хранилище файлов доказательств, limiter - лимиты запросов, nil если выключены, mailer - письма пользователям
+func NewServer(db Storage, cfg *config.Config, log *slog.Logger, r *chi.Mux, board *domain.LeaderboardCache, verifiers map[string]domain.TaskVerifier, proofs domain.ProofStore, limiter *ratelimit.Limiter, mailer domain.Mailer) *Server {
+	cl := pkg.NormalClock{}
+	hub := domain.NewScoreHub()
+	//сервер только управляет подписками, события в очередь вебхуков ставит relay outbox (см. main)
+	webhooks := domain.NewWebhookService(db, nil, log, cfg, cl)
+	achievements := domain.NewAchievementService(db, log, cfg, cl)
+	levels := domain.NewLevelService(db, log, cfg, cl)
+	bonuses := domain.NewBonusEventService(db, log, cfg, cl)
+	quests := domain.NewQuestService(db, log, cfg, cl)
+	users := domain.NewUserService(db, log, cfg, cl, board, hub, verifiers, achievements, levels, bonuses, quests)
+	challenges := domain.NewChallengeService(db, users, log, cfg, cl)
 	server := &Server{ //формируем структуру сервера
-		db:      db,
-		context: context.Background(),
-		log:     log,
-		srv:     domain.NewUserService(db, log, cfg),
-		auth:    auth.NewService(db, log, cfg, "secret", pkg.NormalClock{}),
+		db:          db,
+		context:     context.Background(),
+		log:         log,
+		cfg:         cfg,
+		hub:         hub,
+		srv:         users,
+		seasons:     domain.NewSeasonService(db, log, cfg, cl),
+		webhooks:    webhooks,
+		submissions: domain.NewSubmissionService(db, proofs, users, log, cfg, cl),
+		idempotency: domain.NewIdempotencyService(db, log, cfg, cl),
+		shop:        domain.NewShopService(db, users, log, cfg, cl),
+		transfers:   domain.NewTransferService(db, users, log, cfg, cl),
+		expiration:  domain.NewPointsExpirer(db, users, log, cfg, cl),
+		bonuses:     bonuses,
+		teams:       domain.NewTeamService(db, log, cfg, cl),
+		challenges:  challenges,
+		profiles:    domain.NewProfileService(db, mailer, proofs, users, challenges, log, cfg, cl),
+		auth:        auth.NewService(db, log, cfg, "secret", cl),
+		limiter:     limiter,
+		cl:          cl,
 	}
 
 	//роутим эндпоинты авторизации
-	r.Method(http.MethodGet, "/login/{id}", http.HandlerFunc(server.loginHandler))
-	r.Method(http.MethodPost, "/register", http.HandlerFunc(server.registerHandler))
-	//эндпоинты с авторизацией
-	r.With(server.AuthMiddleware).Method(http.MethodGet, "/users/{id}/status", http.HandlerFunc(server.statusHandler))
-	r.With(server.AuthMiddleware).Method(http.MethodGet, "/users/leaderboard", http.HandlerFunc(server.leaderboard))
-	r.With(server.AuthMiddleware).Method(http.MethodPatch, "/users/{id}/task/complete", http.HandlerFunc(server.taskCompleteHandler))
-	r.With(server.AuthMiddleware).Method(http.MethodPatch, "/users/{id}/referrer", http.HandlerFunc(server.referrerHandler))
+	public := r.With(server.RateLimitMiddleware("auth"))
+	public.Method(http.MethodGet, "/login/{id}", http.HandlerFunc(server.loginHandler))
+	public.Method(http.MethodPost, "/register", http.HandlerFunc(server.registerHandler))
+	public.Method(http.MethodPost, "/auth/verify-email", http.HandlerFunc(server.verifyEmailHandler))
+	public.Method(http.MethodGet, "/auth/verify-email", http.HandlerFunc(server.verifyEmailHandler))
+	//эндпоинты с авторизацией, изменяющие поддерживают заголовок Idempotency-Key
+	api := r.With(server.AuthMiddleware, server.RateLimitMiddleware("api"))
+	tasks := r.With(server.AuthMiddleware, server.RateLimitMiddleware("tasks"), server.IdempotencyMiddleware(maxRequestBody))
+	api.Method(http.MethodGet, "/tasks", http.HandlerFunc(server.tasksHandler))
+	api.Method(http.MethodGet, "/users/{id}/status", http.HandlerFunc(server.statusHandler))
+	tasks.Method(http.MethodPatch, "/users/{id}", http.HandlerFunc(server.updateProfileHandler))
+	tasks.Method(http.MethodDelete, "/users/{id}", http.HandlerFunc(server.deleteUserHandler))
+	tasks.Method(http.MethodPost, "/users/{id}/email/verification", http.HandlerFunc(server.sendVerificationHandler))
+	api.Method(http.MethodGet, "/users/leaderboard", http.HandlerFunc(server.leaderboard))
+	r.With(server.StreamAuthMiddleware, server.RateLimitMiddleware("api")).Method(http.MethodGet, "/users/leaderboard/stream", http.HandlerFunc(server.leaderboardStream))
+	api.Method(http.MethodGet, "/users/{id}/rank", http.HandlerFunc(server.rankHandler))
+	api.Method(http.MethodGet, "/users/{id}/achievements", http.HandlerFunc(server.achievementsHandler))
+	api.Method(http.MethodGet, "/users/{id}/quests", http.HandlerFunc(server.questsHandler))
+	tasks.Method(http.MethodPatch, "/users/{id}/task/complete", http.HandlerFunc(server.taskCompleteHandler))
+	tasks.Method(http.MethodPatch, "/users/{id}/referrer", http.HandlerFunc(server.referrerHandler))
+	//файл доказательства целиком входит в отпечаток запроса, поэтому здесь лимит тела больше
+	r.With(server.AuthMiddleware, server.RateLimitMiddleware("tasks"), server.IdempotencyMiddleware(cfg.Submissions.MaxProofSize+multipartMemory)).
+		Method(http.MethodPost, "/users/{id}/submissions", http.HandlerFunc(server.submitProofHandler))
+	api.Method(http.MethodGet, "/users/{id}/submissions", http.HandlerFunc(server.userSubmissionsHandler))
+	tasks.Method(http.MethodPost, "/users/{id}/transfers", http.HandlerFunc(server.transferHandler))
+	api.Method(http.MethodGet, "/users/{id}/transactions", http.HandlerFunc(server.transactionsHandler))
+	api.Method(http.MethodGet, "/users/{id}/points/expiring", http.HandlerFunc(server.expiringPointsHandler))
+	tasks.Method(http.MethodPost, "/teams", http.HandlerFunc(server.createTeamHandler))
+	api.Method(http.MethodGet, "/teams/leaderboard", http.HandlerFunc(server.teamLeaderboardHandler))
+	api.Method(http.MethodGet, "/teams/{id}", http.HandlerFunc(server.teamHandler))
+	tasks.Method(http.MethodPost, "/teams/{id}/invites", http.HandlerFunc(server.inviteToTeamHandler))
+	tasks.Method(http.MethodPost, "/teams/{id}/join", http.HandlerFunc(server.joinTeamHandler))
+	tasks.Method(http.MethodPost, "/teams/{id}/leave", http.HandlerFunc(server.leaveTeamHandler))
+	tasks.Method(http.MethodDelete, "/teams/{id}/members/{userID}", http.HandlerFunc(server.kickTeamMemberHandler))
+	tasks.Method(http.MethodPost, "/challenges", http.HandlerFunc(server.createChallengeHandler))
+	api.Method(http.MethodGet, "/challenges", http.HandlerFunc(server.challengesHandler))
+	api.Method(http.MethodGet, "/challenges/{id}", http.HandlerFunc(server.challengeHandler))
+	tasks.Method(http.MethodPost, "/challenges/{id}/accept", http.HandlerFunc(server.acceptChallengeHandler))
+	tasks.Method(http.MethodPost, "/challenges/{id}/decline", http.HandlerFunc(server.declineChallengeHandler))
+	tasks.Method(http.MethodPost, "/challenges/{id}/cancel", http.HandlerFunc(server.cancelChallengeHandler))
+	api.Method(http.MethodGet, "/shop/items", http.HandlerFunc(server.shopItemsHandler))
+	tasks.Method(http.MethodPost, "/shop/orders", http.HandlerFunc(server.placeOrderHandler))
+	api.Method(http.MethodGet, "/shop/orders", http.HandlerFunc(server.userOrdersHandler))
+	api.Method(http.MethodGet, "/seasons", http.HandlerFunc(server.seasonsHandler))
+	api.Method(http.MethodGet, "/seasons/{id}/standings", http.HandlerFunc(server.standingsHandler))
+	//админские эндпоинты
+	admin := r.With(server.AuthMiddleware, server.RateLimitMiddleware("api"), server.AdminMiddleware, server.IdempotencyMiddleware(maxRequestBody))
+	admin.Method(http.MethodPost, "/admin/webhooks", http.HandlerFunc(server.createWebhookHandler))
+	admin.Method(http.MethodGet, "/admin/webhooks", http.HandlerFunc(server.listWebhooksHandler))
+	admin.Method(http.MethodDelete, "/admin/webhooks/{id}", http.HandlerFunc(server.deleteWebhookHandler))
+	admin.Method(http.MethodGet, "/admin/webhooks/{id}/deliveries", http.HandlerFunc(server.webhookDeliveriesHandler))
+	admin.Method(http.MethodGet, "/admin/submissions", http.HandlerFunc(server.adminSubmissionsHandler))
+	admin.Method(http.MethodGet, "/admin/submissions/{id}/proof", http.HandlerFunc(server.submissionProofHandler))
+	admin.Method(http.MethodPost, "/admin/submissions/{id}/approve", http.HandlerFunc(server.approveSubmissionHandler))
+	admin.Method(http.MethodPost, "/admin/submissions/{id}/reject", http.HandlerFunc(server.rejectSubmissionHandler))
+	admin.Method(http.MethodGet, "/admin/shop/items", http.HandlerFunc(server.adminShopItemsHandler))
+	admin.Method(http.MethodPost, "/admin/shop/items", http.HandlerFunc(server.createShopItemHandler))
+	admin.Method(http.MethodPut, "/admin/shop/items/{id}", http.HandlerFunc(server.updateShopItemHandler))
+	admin.Method(http.MethodGet, "/admin/shop/orders", http.HandlerFunc(server.adminOrdersHandler))
+	admin.Method(http.MethodPost, "/admin/shop/orders/{id}/fulfil", http.HandlerFunc(server.fulfilOrderHandler))
+	admin.Method(http.MethodPost, "/admin/shop/orders/{id}/cancel", http.HandlerFunc(server.cancelOrderHandler))
+	admin.Method(http.MethodPost, "/admin/users/{id}/suspend", http.HandlerFunc(server.suspendUserHandler))
+	admin.Method(http.MethodPost, "/admin/users/{id}/unsuspend", http.HandlerFunc(server.unsuspendUserHandler))
+	admin.Method(http.MethodGet, "/admin/bonus-events", http.HandlerFunc(server.listBonusEventsHandler))
+	admin.Method(http.MethodPost, "/admin/bonus-events", http.HandlerFunc(server.createBonusEventHandler))
+	admin.Method(http.MethodDelete, "/admin/bonus-events/{id}", http.HandlerFunc(server.deleteBonusEventHandler))
+	admin.Method(http.MethodPost, "/admin/challenges/{id}/cancel", http.HandlerFunc(server.adminCancelChallengeHandler))
 	server.log.Info("router configured")
 	return server
 }
@@ -46,34 +169,38 @@ func NewServer(db domain.UserStore, cfg *config.Config, log *slog.Logger, r *chi
 func (s Server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	//логин моковый, он требует только ввести id юзера и отдаёт jwt токен
 	const op = "gates.server.loginHandler"
-	s.log.Info(op, ": starting login")
+	s.log.Info(op + ": starting login")
 
 	idParamStr := chi.URLParam(r, "id")
 	if idParamStr == "" {
-		s.log.Debug(op, ": empty id")
+		s.log.Debug(op + ": empty id")
 		http.Error(w, "Missing user ID", http.StatusBadRequest)
 		return
 	}
 	idParam, err := strconv.Atoi(idParamStr)
 	if err != nil {
-		s.log.Debug(op, ": failed to convert srt to int Atoi")
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 	id := domain.UserID(idParam)
 
-	s.log.Debug("login: ", id)
+	s.log.Debug(op+": login", "user_id", id)
 	token, err := s.auth.Login(s.context, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		s.log.Debug(op, "user_id", id, "msg", "user not found")
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		s.log.Error(op, ": failed to login: "+err.Error())
+		s.log.Error(op+": failed to login", "error", err)
 		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	s.log.Info(op, ": sucesfully logged in")
-	s.log.Debug("token: ", token)
+	s.log.Info(op + ": sucesfully logged in")
 	resp, err := json.Marshal(token)
 	if err != nil {
-		s.log.Error(op, ": failed to encode token: ", err.Error())
+		s.log.Error(op+": failed to encode token", "error", err)
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -85,44 +212,65 @@ func (s Server) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s Server) registerHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "gates.server.registerHandler"
-	s.log.Info(op, ": starting register")
+	s.log.Info(op + ": starting register")
 	var user user
 	//декодировка json, извлечение данных нового пользователя
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		s.log.Error(op, ": failed to decode request body: "+err.Error())
+		s.log.Error(op+": failed to decode request body", "error", err)
 		return
 	}
 	r.Body.Close()
 	//проверка наличия никнейма в json
 	if user.Nickname == "" { //todo вынести в отдельную функцию, validate user
-		s.log.Debug(op, ": No nickname")
+		s.log.Debug(op + ": No nickname")
 		http.Error(w, "Nickname and Email is required", http.StatusBadRequest)
 		return
 	}
 	if user.Email == "" { //todo туда же в отдельную функцию
-		s.log.Debug(op, ": no email")
+		s.log.Debug(op + ": no email")
 		http.Error(w, "Email is required", http.StatusBadRequest)
 		return
 	}
 
 	err := domain.VerifyEmail(user.Email)
+	if err == nil {
+		err = domain.ValidateNickname(user.Nickname)
+	}
 	if err != nil {
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		s.log.Debug(op, ": failed to validate request body: "+err.Error())
+		s.log.Debug(op+": failed to validate request body", "error", err)
 		return
 	}
-	s.log.Debug(op, ": email verified")
+	s.log.Debug(op + ": email verified")
 	//Вызов домейновой функции по добавлению пользователя
-	s.log.Debug(op, ": trying to make duser")
+	s.log.Debug(op + ": trying to make duser")
 	duser := user.toDomain()
-	s.log.Debug(op, ": duser made")
-	err = s.srv.AddUser(s.context, duser)
+	s.log.Debug(op + ": duser made")
+	created, err := s.srv.AddUser(s.context, duser)
+	if errors.Is(err, domain.ErrNicknameTaken) {
+		s.log.Debug(op, "nickname", user.Nickname, "msg", "nickname is taken")
+		s.writeConflict(w, err, "nickname")
+		return
+	}
+	if errors.Is(err, domain.ErrEmailTaken) {
+		s.log.Debug(op, "email", user.Email, "msg", "email is taken")
+		s.writeConflict(w, err, "email")
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidTimezone) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		s.log.Error(op, ": failed to add user: "+err.Error())
+		s.log.Error(op+": failed to add user", "error", err)
 		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	//письмо с подтверждением email, пользователь уже создан, поэтому при ошибке он может запросить письмо повторно
+	if err = s.profiles.SendVerification(s.context, created.ID); err != nil {
+		s.log.Error(op, "user_id", created.ID, "error", err)
+	}
 	s.log.Info(op, "registered user", user.Nickname)
 	w.WriteHeader(http.StatusCreated) //ответ
 	return
@@ -130,17 +278,17 @@ func (s Server) registerHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "gates.server.statusHandler"
-	s.log.Info(op, ": starting status")
+	s.log.Info(op + ": starting status")
 	//извлечение userid из адреса
 	idParamStr := chi.URLParam(r, "id")
 	if idParamStr == "" {
-		s.log.Debug(op, ": empty id")
+		s.log.Debug(op + ": empty id")
 		http.Error(w, "Missing user ID", http.StatusBadRequest)
 		return
 	}
 	idParam, err := strconv.Atoi(idParamStr)
 	if err != nil {
-		s.log.Debug(op, ": failed to convert srt to int Atoi")
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
 		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
 		return
 	}
@@ -148,30 +296,40 @@ func (s Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	var user domain.User
 	user, ok := userFromContext(r.Context())
 	if !ok {
-		s.log.Error(op, ": user not found in context")
+		s.log.Error(op + ": user not found in context")
 		http.Error(w, "user not found in context", http.StatusInternalServerError)
 		return
 	}
 	var resp []byte
-	//если id из запроса совпадает с тем что был в jwt переданный мидлвер авторизации, то формируем ответ из юзера извлечённым из мидлвера (чтоб сократить кол-во обращений в бд)
-	if user.ID == domain.UserID(idParam) {
-		//формирование ответа
-		resp, err = json.Marshal(user)
-		if err != nil {
-			s.log.Error(op, ": failed to encode user: ", err.Error())
-			http.Error(w, "Something went wrong: "+err.Error(), http.StatusBadRequest)
+	//если id из запроса не совпадает с тем что был в jwt переданный мидлвер авторизации, то ходим в бд по нужному id, иначе берём юзера из мидлвера (чтоб сократить кол-во обращений в бд)
+	if user.ID != domain.UserID(idParam) {
+		user, err = s.srv.Status(s.context, domain.UserID(idParam))
+		if errors.Is(err, domain.ErrUserNotFound) {
+			s.log.Debug(op, "user_id", idParam, "msg", "user not found")
+			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
-	} else { //если не совпадает, тогда ходим в бд по нужному id и формируем ответ
-		user, err := s.srv.Status(s.context, domain.UserID(idParam))
-		resp, err = json.Marshal(user)
 		if err != nil {
-			s.log.Error(op, ": failed to encode user: ", err.Error())
-			http.Error(w, "Something went wrong: "+err.Error(), http.StatusBadRequest)
+			s.log.Error(op, "error", err)
+			http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 	}
+	streaks, err := s.srv.Streaks(s.context, user.ID)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	//формирование ответа
+	resp, err = json.Marshal(statusResponse{User: user, Level: s.srv.Level(user), Streaks: streaksFromDomain(streaks)})
+	if err != nil {
+		s.log.Error(op, ": failed to encode user: ", err.Error())
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	s.log.Info(op, ": status sucessfully retrieved")
+	s.log.Info(op + ": status sucessfully retrieved")
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(resp)
 	w.WriteHeader(http.StatusOK)
@@ -180,34 +338,30 @@ func (s Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s Server) leaderboard(w http.ResponseWriter, r *http.Request) {
 	const op = "gates.server.leaderboard"
-	s.log.Info(op, ": starting leaderboard")
-	var set LeaderboardSettings
-	//декодировка json, попытка извлечь параметры сортировки, номер страницы, размер (опционально)
-	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+	s.log.Info(op + ": starting leaderboard")
+	//параметры сортировки, номер страницы, размер и курсор берутся из query string (все опциональные)
+	q, err := parseLeaderboardQuery(r)
+	if err != nil {
+		s.log.Debug(op, "error", err)
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	leaderboard, err := s.srv.Leaderbord(s.context, q)
+	if errors.Is(err, domain.ErrInvalidLeaderboardQuery) {
+		s.log.Debug(op, "error", err)
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		s.log.Error(op, ": failed to decode request body: "+err.Error())
 		return
 	}
-	r.Body.Close()
-	s.log.Debug(op, "leaderboard settings: ", set)
-	leaderboard, err := s.srv.Leaderbord(s.context, set.SortBy, set.Page, set.Size)
 	if err != nil {
-		s.log.Error(op, ": failed to get leaderboard: "+err.Error())
+		s.log.Error(op+": failed to get leaderboard", "error", err)
 		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	var resp []user //собираю ответ без указания email и информации о приглашении
-	for _, duser := range leaderboard {
-		usr := user{
-			Id:         duser.ID,
-			Nickname:   duser.Nickname,
-			Score:      duser.Score,
-			Registered: duser.Registered,
-		}
-		s.log.Debug(op, usr)
-		resp = append(resp, usr)
+	resp := leaderboardResponse{
+		Items:      entriesFromDomain(leaderboard.Users), //собираю ответ без указания email и информации о приглашении
+		NextCursor: leaderboard.NextCursor,
+		Total:      leaderboard.Total,
 	}
-	s.log.Debug(op, "leaderboard:", resp)
 	//формируем ответ
 	responce, err := json.Marshal(resp)
 	if err != nil {
@@ -216,56 +370,169 @@ func (s Server) leaderboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(responce)
 	w.WriteHeader(http.StatusOK)
-	s.log.Info(op, ": leaderboard sucessfully retrieved")
+	w.Write(responce)
+	s.log.Info(op + ": leaderboard sucessfully retrieved")
 	return
 }
 
+func (s Server) rankHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.rankHandler"
+	s.log.Info(op + ": starting rank")
+	idParam, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
+		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	//neighbours - сколько соседей показать сверху и снизу (опционально)
+	var neighbours int
+	if n := r.URL.Query().Get("neighbours"); n != "" {
+		if neighbours, err = strconv.Atoi(n); err != nil {
+			http.Error(w, "Invalid request: neighbours must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	rank, err := s.srv.Rank(s.context, domain.UserID(idParam), neighbours)
+	if errors.Is(err, domain.ErrInvalidLeaderboardQuery) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(rankResponse{
+		User:       entryFromDomain(rank.LeaderboardEntry),
+		Percentile: rank.Percentile,
+		Total:      rank.Total,
+		Above:      entriesFromDomain(rank.Above),
+		Below:      entriesFromDomain(rank.Below),
+	})
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+	s.log.Info(op + ": rank sucessfully retrieved")
+}
+
+// parseLeaderboardQuery - разбор sort_by, order, page, size (или limit), cursor и period (с tz, from, to) из query string
+func parseLeaderboardQuery(r *http.Request) (domain.LeaderboardQuery, error) {
+	values := r.URL.Query()
+	q := domain.LeaderboardQuery{
+		SortBy:   values.Get("sort_by"),
+		Order:    values.Get("order"),
+		Period:   values.Get("period"),
+		Timezone: values.Get("tz"),
+		From:     values.Get("from"),
+		To:       values.Get("to"),
+	}
+	var err error
+	if page := values.Get("page"); page != "" {
+		if q.Page, err = strconv.Atoi(page); err != nil {
+			return q, errors.New("page must be a number")
+		}
+	}
+	size := values.Get("size")
+	if size == "" {
+		size = values.Get("limit")
+	}
+	if size != "" {
+		if q.Size, err = strconv.Atoi(size); err != nil {
+			return q, errors.New("size must be a number")
+		}
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		if q.Cursor, err = domain.DecodeLeaderboardCursor(cursor); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
 func (s Server) taskCompleteHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "gates.server.taskCompleteHandler"
 	//в этом хендлере я подумал что добавлять поинты юзер может только сам себе, так что буду сверять id из authorize мидлвера и id указанный в адрессе, если не сходится то прекращать работу
-	s.log.Info(op, ": starting task complete")
+	s.log.Info(op + ": starting task complete")
 	user, ok := userFromContext(r.Context())
 	if !ok {
-		s.log.Error(op, ": user not found in context")
+		s.log.Error(op + ": user not found in context")
 		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
 		return
 	}
 	//получение id из адреса
 	idParamStr := chi.URLParam(r, "id")
 	if idParamStr == "" {
-		s.log.Debug(op, ": empty id")
+		s.log.Debug(op + ": empty id")
 		http.Error(w, "Missing user ID", http.StatusBadRequest)
 		return
 	}
 	idParam, err := strconv.Atoi(idParamStr)
 	if err != nil {
-		s.log.Debug(op, ": failed to convert srt to int Atoi")
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 	if user.ID != domain.UserID(idParam) {
-		s.log.Debug(op, ": request user doesn't match auth user")
+		s.log.Debug(op + ": request user doesn't match auth user")
 		http.Error(w, "You don't have permission, you may add points only to your account", http.StatusBadRequest)
 		return
 	}
 	var req TaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		s.log.Error(op, ": Failed to decode request body: "+err.Error())
+		s.log.Error(op+": Failed to decode request body", "error", err)
 		return
 	}
 	r.Body.Close()
 	task := req.Task
-	err = s.srv.TaskComplete(s.context, user.ID, task)
+	err = s.srv.TaskComplete(s.context, user.ID, task, req.Account)
 	if err == domain.ErrNotExistingReward {
-		s.log.Debug(op, "User tried to claim not existing reward")
+		s.log.Debug(op + ": user tried to claim not existing reward")
 		http.Error(w, "This task doesn't exist", http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, domain.ErrProofRequired) {
+		http.Error(w, err.Error()+": POST /users/{id}/submissions", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrQuestPrerequisite) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrEmailNotVerified) {
+		http.Error(w, err.Error()+": POST /users/{id}/email/verification", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidTaskClaim) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrTaskNotVerified) {
+		http.Error(w, err.Error(), http.StatusForbidden) //задание не выполнено во внешнем сервисе
+		return
+	}
+	if errors.Is(err, domain.ErrTaskAlreadyCompleted) || errors.Is(err, domain.ErrAccountTaken) || errors.Is(err, domain.ErrAccountMismatch) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrVerificationUnavailable) {
+		//подробности только в лог, клиенту - общий текст
+		s.log.Warn(op+": verification unavailable", "error", err)
+		http.Error(w, domain.ErrVerificationUnavailable.Error()+", try again later", http.StatusBadGateway)
+		return
+	}
 	if err != nil {
-		s.log.Error(op, ": failed to complete task: "+err.Error())
+		s.log.Error(op+": failed to complete task", "error", err)
 		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -277,55 +544,109 @@ func (s Server) taskCompleteHandler(w http.ResponseWriter, r *http.Request) {
 func (s Server) referrerHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "gates.server.reffererHandler"
 	//Аналогично taskComplete, считаю что рефералки может прописывать юзер только сам себе (указывать кто пригласил)
-	s.log.Info(op, ": starting refferer Handler")
+	s.log.Info(op + ": starting refferer Handler")
 	user, ok := userFromContext(r.Context())
 	if !ok {
-		s.log.Error(op, ": user not found in conext")
+		s.log.Error(op + ": user not found in conext")
 		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
 		return
 	}
 	//получение id из адреса
 	idParamStr := chi.URLParam(r, "id")
 	if idParamStr == "" {
-		s.log.Debug(op, ": empty id")
+		s.log.Debug(op + ": empty id")
 		http.Error(w, "Missing user ID", http.StatusBadRequest)
 		return
 	}
 	idParam, err := strconv.Atoi(idParamStr)
 	if err != nil {
-		s.log.Debug(op, ": failed to convert srt to int Atoi")
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
 		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest) //если не сработал atoi, пользователь явно ввёл что-то кроме цифр как idшник
 		return
 	}
 	if user.ID != domain.UserID(idParam) {
-		s.log.Debug(op, ": request user doesn't match auth user")
+		s.log.Debug(op + ": request user doesn't match auth user")
 		http.Error(w, "you don't have permission, you may add points only to your account", http.StatusBadRequest) //Права на вписание "пригласившего" есть только у приглашённого
 		return
 	}
 	var referrer RefRequest
 	if err := json.NewDecoder(r.Body).Decode(&referrer); err != nil {
 		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		s.log.Error(op, ": failed to decode request body: "+err.Error())
+		s.log.Error(op+": failed to decode request body", "error", err)
 		return
 	}
 	ref, err := strconv.Atoi(referrer.ID)
 	if err != nil {
-		s.log.Debug(op, ": failed to convert srt to int Atoi")
+		s.log.Debug(op + ": failed to convert srt to int Atoi")
 		http.Error(w, "Referrer user ID must consist of numbers only", http.StatusBadRequest) //если не сработал atoi, пользователь явно ввёл что-то кроме цифр как idшник
 		return
 	}
 	r.Body.Close()
 	err = s.srv.InvitedBy(s.context, user.ID, domain.UserID(ref))
-	if err == sql.ErrNoRows {
-		s.log.Debug(op, ": referrer not found")
+	if errors.Is(err, domain.ErrUserNotFound) {
+		s.log.Debug(op + ": referrer not found")
 		http.Error(w, "Referrer not found, no such user", http.StatusNotFound) //не нашёлся пригласивший в бд
 		return
 	}
+	if errors.Is(err, domain.ErrUserAlreadyInvited) {
+		s.log.Debug(op + ": user already invited")
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrEmailNotVerified) {
+		http.Error(w, err.Error()+": POST /users/{id}/email/verification", http.StatusForbidden)
+		return
+	}
 	if err != nil {
-		s.log.Error(op, ": failed to invited user: "+err.Error())
+		s.log.Error(op+": failed to invited user", "error", err)
 		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 	s.log.Info(op, ": invited user", user.ID)
 }
+
+// writeConflict - ответ 409 с указанием поля, из-за которого произошёл конфликт
+func (s Server) writeConflict(w http.ResponseWriter, err error, field string) {
+	const op = "gates.server.writeConflict"
+	resp, mErr := json.Marshal(conflictResponse{Error: err.Error(), Field: field})
+	if mErr != nil {
+		s.log.Error(op, "error", mErr)
+		http.Error(w, "Something went wrong: "+mErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	w.Write(resp)
+}
+
+// writeJSON - ответ с JSON телом
+func (s Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	const op = "gates.server.writeJSON"
+	resp, err := json.Marshal(v)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// pageParams - опциональные page и size из query string
+func pageParams(r *http.Request) (int, int, error) {
+	var page, size int
+	var err error
+	if p := r.URL.Query().Get("page"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil {
+			return 0, 0, errors.New("page must be a number")
+		}
+	}
+	if sz := r.URL.Query().Get("size"); sz != "" {
+		if size, err = strconv.Atoi(sz); err != nil {
+			return 0, 0, errors.New("size must be a number")
+		}
+	}
+	return page, size, nil
+}
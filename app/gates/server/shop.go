@@ -0,0 +1,201 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// каталог доступных сейчас товаров
+func (s Server) shopItemsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listShopItems(w, false)
+}
+
+// все товары, включая выключенные и вне окна продаж
+func (s Server) adminShopItemsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listShopItems(w, true)
+}
+
+func (s Server) listShopItems(w http.ResponseWriter, all bool) {
+	const op = "gates.server.listShopItems"
+	items, err := s.shop.Items(s.context, all)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, shopItemsFromDomain(items))
+}
+
+func (s Server) createShopItemHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveShopItem(w, r, false)
+}
+
+// изменение товара, тело целиком заменяет товар
+func (s Server) updateShopItemHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveShopItem(w, r, true)
+}
+
+func (s Server) saveShopItem(w http.ResponseWriter, r *http.Request, update bool) {
+	const op = "gates.server.saveShopItem"
+	var req shopItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	item := req.toDomain()
+	var err error
+	status := http.StatusCreated
+	if update {
+		id, convErr := strconv.Atoi(chi.URLParam(r, "id"))
+		if convErr != nil {
+			http.Error(w, "Item ID must consist of numbers only", http.StatusBadRequest)
+			return
+		}
+		item.ID = domain.ShopItemID(id)
+		item, err = s.shop.UpdateItem(s.context, item)
+		status = http.StatusOK
+	} else {
+		item, err = s.shop.AddItem(s.context, item)
+	}
+	switch {
+	case errors.Is(err, domain.ErrInvalidShopItem):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrShopItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, status, shopItemFromDomain(item))
+}
+
+// placeOrderHandler - покупка товара за очки пользователя из токена
+func (s Server) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.placeOrderHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := s.shop.Order(s.context, user.ID, req.ItemID, req.Quantity)
+	switch {
+	case errors.Is(err, domain.ErrInvalidOrder):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrShopItemNotFound), errors.Is(err, domain.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrShopItemUnavailable), errors.Is(err, domain.ErrOutOfStock),
+		errors.Is(err, domain.ErrPurchaseLimit), errors.Is(err, domain.ErrInsufficientPoints):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, orderFromDomain(created))
+}
+
+// история заказов пользователя из токена, опционально status, page, size
+func (s Server) userOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.userOrdersHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	s.listOrders(w, r, domain.OrderFilter{UserID: &user.ID, Status: r.URL.Query().Get("status")})
+}
+
+// очередь выдачи, опционально status и user_id
+func (s Server) adminOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	filter := domain.OrderFilter{Status: r.URL.Query().Get("status")}
+	if u := r.URL.Query().Get("user_id"); u != "" {
+		id, err := strconv.Atoi(u)
+		if err != nil {
+			http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
+			return
+		}
+		userID := domain.UserID(id)
+		filter.UserID = &userID
+	}
+	s.listOrders(w, r, filter)
+}
+
+func (s Server) listOrders(w http.ResponseWriter, r *http.Request, filter domain.OrderFilter) {
+	const op = "gates.server.listOrders"
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	orders, err := s.shop.Orders(s.context, filter, page, size)
+	if errors.Is(err, domain.ErrInvalidOrder) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, ordersFromDomain(orders))
+}
+
+func (s Server) fulfilOrderHandler(w http.ResponseWriter, r *http.Request) {
+	s.closeOrder(w, r, false)
+}
+
+// отмена заказа с возвратом очков, в теле опционально {"comment": "причина"}
+func (s Server) cancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	s.closeOrder(w, r, true)
+}
+
+func (s Server) closeOrder(w http.ResponseWriter, r *http.Request, cancel bool) {
+	const op = "gates.server.closeOrder"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Order ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	var closed domain.Order
+	if cancel {
+		var req rejectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		closed, err = s.shop.Cancel(s.context, domain.OrderID(id), req.Comment)
+	} else {
+		closed, err = s.shop.Fulfil(s.context, domain.OrderID(id))
+	}
+	switch {
+	case errors.Is(err, domain.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrOrderClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, orderFromDomain(closed))
+}
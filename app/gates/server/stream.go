@@ -0,0 +1,127 @@
+package server
+
+import (
+	"app/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultStreamTop  = 10
+	streamHeartbeat   = 15 * time.Second
+	streamMinInterval = time.Second // не чаще раза в секунду пересчитываем состояние для одного клиента
+)
+
+// streamRank - место подписанного пользователя
+type streamRank struct {
+	Rank       int64            `json:"rank"`
+	Score      domain.UserScore `json:"score"`
+	Percentile float64          `json:"percentile"`
+}
+
+// leaderboardStream - Server-Sent Events с изменениями топа (event: top) и места текущего пользователя (event: rank).
+// При подключении отправляется текущее состояние, дальше только изменения
+func (s Server) leaderboardStream(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.leaderboardStream"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	top := defaultStreamTop
+	if t := r.URL.Query().Get("top"); t != "" {
+		var err error
+		if top, err = strconv.Atoi(t); err != nil || top < 1 || top > domain.MaxLeaderboardSize {
+			http.Error(w, fmt.Sprintf("Invalid request: top must be between 1 and %d", domain.MaxLeaderboardSize), http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	//подписываемся до первого снимка, чтобы не пропустить изменения между снимком и подпиской
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	s.log.Info(op+": client subscribed", "user_id", user.ID)
+
+	ctx := r.Context()
+	var lastTop, lastRank []byte
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		//отправляем только то, что изменилось с прошлой отправки
+		topData, rankData, err := s.streamState(ctx, user.ID, top)
+		if err != nil {
+			s.log.Error(op, "user_id", user.ID, "error", err)
+			return
+		}
+		if !bytes.Equal(topData, lastTop) {
+			fmt.Fprintf(w, "event: top\ndata: %s\n\n", topData)
+			lastTop = topData
+		}
+		if !bytes.Equal(rankData, lastRank) {
+			fmt.Fprintf(w, "event: rank\ndata: %s\n\n", rankData)
+			lastRank = rankData
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			s.log.Info(op+": client disconnected", "user_id", user.ID)
+			return
+		case <-time.After(streamMinInterval):
+		}
+		waiting := true
+		for waiting {
+			select {
+			case <-ctx.Done():
+				s.log.Info(op+": client disconnected", "user_id", user.ID)
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			case <-sub.C():
+				waiting = false
+			}
+		}
+	}
+}
+
+// streamState - текущий топ и место пользователя в виде JSON. Топ общий для всех подписчиков (максимального размера,
+// каждому отдаётся его часть), а место считается для каждого пользователя отдельно
+func (s Server) streamState(ctx context.Context, id domain.UserID, top int) ([]byte, []byte, error) {
+	entries, err := s.hub.Top(ctx, func(ctx context.Context) ([]domain.LeaderboardEntry, error) {
+		page, err := s.srv.Leaderbord(ctx, domain.LeaderboardQuery{SortBy: domain.SortByScore, Size: domain.MaxLeaderboardSize})
+		return page.Users, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	topData, err := json.Marshal(entriesFromDomain(entries[:min(top, len(entries))]))
+	if err != nil {
+		return nil, nil, err
+	}
+	rank, err := s.srv.Rank(ctx, id, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	rankData, err := json.Marshal(streamRank{Rank: rank.Rank, Score: rank.Score, Percentile: rank.Percentile})
+	if err != nil {
+		return nil, nil, err
+	}
+	return topData, rankData, nil
+}
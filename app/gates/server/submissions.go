@@ -0,0 +1,195 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const multipartMemory = 1 << 20 // больше - multipart пишет файл во временный файл на диске
+
+// ownUser - пользователь из токена, если он совпадает с {id} из адреса
+func (s Server) ownUser(w http.ResponseWriter, r *http.Request) (domain.User, bool) {
+	const op = "gates.server.ownUser"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return user, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return user, false
+	}
+	if user.ID != domain.UserID(id) {
+		http.Error(w, "You don't have permission, you may only access your own account", http.StatusForbidden)
+		return user, false
+	}
+	return user, true
+}
+
+// submitProofHandler - заявка о выполнении задания: JSON {"task", "url"} или multipart/form-data с полями task, url и файлом proof
+func (s Server) submitProofHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.submitProofHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	var req submissionRequest
+	var proof *domain.Proof
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.Submissions.MaxProofSize+multipartMemory)
+		if err := r.ParseMultipartForm(multipartMemory); err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		req.Task = r.FormValue("task")
+		req.URL = r.FormValue("url")
+		file, header, err := r.FormFile("proof")
+		if err != nil && !errors.Is(err, http.ErrMissingFile) {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			defer file.Close()
+			proof = &domain.Proof{Body: file, Size: header.Size}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := s.submissions.Submit(s.context, user.ID, req.Task, req.URL, proof)
+	switch {
+	case errors.Is(err, domain.ErrNotExistingReward):
+		http.Error(w, "This task doesn't exist", http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrInvalidSubmission):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrSubmissionPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, domain.ErrEmailNotVerified):
+		http.Error(w, err.Error()+": POST /users/{id}/email/verification", http.StatusForbidden)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, submissionFromDomain(sub))
+}
+
+// userSubmissionsHandler - история заявок пользователя с результатом проверки, опционально status, page, size
+func (s Server) userSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	s.listSubmissions(w, r, domain.SubmissionFilter{UserID: &user.ID, Status: r.URL.Query().Get("status")})
+}
+
+// очередь модерации, по умолчанию все заявки, status=pending - только непроверенные
+func (s Server) adminSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listSubmissions(w, r, domain.SubmissionFilter{Status: r.URL.Query().Get("status")})
+}
+
+func (s Server) listSubmissions(w http.ResponseWriter, r *http.Request, filter domain.SubmissionFilter) {
+	const op = "gates.server.listSubmissions"
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := s.submissions.List(s.context, filter, page, size)
+	if errors.Is(err, domain.ErrInvalidSubmission) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, submissionsFromDomain(subs))
+}
+
+// файл доказательства для модератора
+func (s Server) submissionProofHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.submissionProofHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Submission ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	body, contentType, err := s.submissions.Proof(s.context, domain.SubmissionID(id))
+	if errors.Is(err, domain.ErrSubmissionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, body)
+}
+
+func (s Server) approveSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	s.reviewSubmission(w, r, false)
+}
+
+// отклонение заявки, в теле опционально {"comment": "причина"}
+func (s Server) rejectSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	s.reviewSubmission(w, r, true)
+}
+
+func (s Server) reviewSubmission(w http.ResponseWriter, r *http.Request, reject bool) {
+	const op = "gates.server.reviewSubmission"
+	moderator, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Submission ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	var sub domain.Submission
+	if reject {
+		var req rejectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub, err = s.submissions.Reject(s.context, domain.SubmissionID(id), moderator.ID, req.Comment)
+	} else {
+		sub, err = s.submissions.Approve(s.context, domain.SubmissionID(id), moderator.ID)
+	}
+	switch {
+	case errors.Is(err, domain.ErrSubmissionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrSubmissionReviewed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, submissionFromDomain(sub))
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"app/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// createTeamHandler - создание команды, пользователь из токена становится владельцем
+func (s Server) createTeamHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.createTeamHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	var req teamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := s.teams.Create(s.context, user.ID, req.Name, req.MaxSize)
+	if s.teamError(w, op, err) {
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, team{
+		ID:        created.ID,
+		Name:      created.Name,
+		OwnerID:   created.OwnerID,
+		MaxSize:   created.MaxSize,
+		CreatedAt: created.CreatedAt,
+	})
+}
+
+// teamHandler - страница команды с участниками и их вкладом
+func (s Server) teamHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.teamHandler"
+	id, ok := teamID(w, r)
+	if !ok {
+		return
+	}
+	t, members, err := s.teams.Team(s.context, id)
+	if s.teamError(w, op, err) {
+		return
+	}
+	resp := team{ID: t.ID, Name: t.Name, OwnerID: t.OwnerID, MaxSize: t.MaxSize, CreatedAt: t.CreatedAt, Members: make([]teamMember, 0, len(members))}
+	for _, m := range members {
+		resp.Members = append(resp.Members, teamMember(m))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// teamLeaderboardHandler - команды по очкам, опционально page, size
+func (s Server) teamLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.teamLeaderboardHandler"
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.teams.Leaderboard(s.context, page, size)
+	if errors.Is(err, domain.ErrInvalidLeaderboardQuery) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]teamEntry, 0, len(entries))
+	for _, e := range entries {
+		resp = append(resp, teamEntry(e))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// inviteToTeamHandler - приглашение в команду, только для владельца
+func (s Server) inviteToTeamHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.inviteToTeamHandler"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	id, ok := teamID(w, r)
+	if !ok {
+		return
+	}
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.teamError(w, op, s.teams.Invite(s.context, id, user.ID, req.UserID)) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) joinTeamHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeMembership(w, r, s.teams.Join)
+}
+
+func (s Server) leaveTeamHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeMembership(w, r, s.teams.Leave)
+}
+
+// kickTeamMemberHandler - исключение участника {userID} владельцем
+func (s Server) kickTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeMembership(w, r, func(ctx context.Context, id domain.TeamID, owner domain.UserID) error {
+		member, err := strconv.Atoi(chi.URLParam(r, "userID"))
+		if err != nil {
+			return fmt.Errorf("%w: user ID must consist of numbers only", domain.ErrInvalidTeam)
+		}
+		return s.teams.Kick(ctx, id, owner, domain.UserID(member))
+	})
+}
+
+// changeMembership - вступление, выход или исключение от имени пользователя из токена
+func (s Server) changeMembership(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id domain.TeamID, userID domain.UserID) error) {
+	const op = "gates.server.changeMembership"
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.log.Error(op + ": user not found in context")
+		http.Error(w, "Lost data from auth", http.StatusInternalServerError)
+		return
+	}
+	id, ok := teamID(w, r)
+	if !ok {
+		return
+	}
+	if s.teamError(w, op, change(s.context, id, user.ID)) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func teamID(w http.ResponseWriter, r *http.Request) (domain.TeamID, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Team ID must consist of numbers only", http.StatusBadRequest)
+		return 0, false
+	}
+	return domain.TeamID(id), true
+}
+
+// teamError отвечает на ошибку сервиса команд, false - ошибки нет
+func (s Server) teamError(w http.ResponseWriter, op string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, domain.ErrInvalidTeam):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrNotTeamOwner), errors.Is(err, domain.ErrTeamInviteRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrTeamNotFound), errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrNotTeamMember):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrTeamNameTaken), errors.Is(err, domain.ErrAlreadyInTeam), errors.Is(err, domain.ErrTeamFull):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+// transferHandler - перевод очков от пользователя {id} другому пользователю
+func (s Server) transferHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.transferHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.transfers.Send(s.context, user.ID, req.To, req.Amount, req.Comment)
+	switch {
+	case errors.Is(err, domain.ErrInvalidTransfer):
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, domain.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrTransfersDisabled), errors.Is(err, domain.ErrAccountSuspended),
+		errors.Is(err, domain.ErrAccountTooNew):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, domain.ErrTransferLimit), errors.Is(err, domain.ErrInsufficientPoints):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, transfer{
+		ID:         t.ID,
+		FromUserID: t.FromUserID,
+		ToUserID:   t.ToUserID,
+		Amount:     t.Amount,
+		Fee:        t.Fee,
+		Comment:    t.Comment,
+		CreatedAt:  t.CreatedAt,
+	})
+}
+
+// transactionsHandler - история баланса пользователя, опционально page, size
+func (s Server) transactionsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.transactionsHandler"
+	user, ok := s.ownUser(w, r)
+	if !ok {
+		return
+	}
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.transfers.History(s.context, user.ID, page, size)
+	if errors.Is(err, domain.ErrInvalidTransfer) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]transaction, 0, len(transactions))
+	for _, t := range transactions {
+		resp = append(resp, transaction(t))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s Server) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.suspendUser(w, r, true)
+}
+
+func (s Server) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.suspendUser(w, r, false)
+}
+
+func (s Server) suspendUser(w http.ResponseWriter, r *http.Request, suspend bool) {
+	const op = "gates.server.suspendUser"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "User ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	user, err := s.transfers.Suspend(s.context, domain.UserID(id), suspend)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"app/domain"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+func (s Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.createWebhookHandler"
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body.Close()
+	sub, err := s.webhooks.Subscribe(s.context, req.URL, req.Secret, req.Events)
+	if errors.Is(err, domain.ErrInvalidWebhook) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.log.Info(op+": webhook subscription created", "webhook_id", sub.ID)
+	resp := webhookFromDomain(sub)
+	resp.Secret = sub.Secret
+	s.writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s Server) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.listWebhooksHandler"
+	subs, err := s.webhooks.List(s.context)
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]webhook, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, webhookFromDomain(sub))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.deleteWebhookHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Webhook ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	err = s.webhooks.Unsubscribe(s.context, domain.WebhookID(id))
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// журнал доставок подписки, опционально status=pending/delivered/dead, page, size
+func (s Server) webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "gates.server.webhookDeliveriesHandler"
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Webhook ID must consist of numbers only", http.StatusBadRequest)
+		return
+	}
+	page, size, err := pageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	deliveries, err := s.webhooks.Deliveries(s.context, domain.WebhookID(id), r.URL.Query().Get("status"), page, size)
+	if errors.Is(err, domain.ErrInvalidWebhook) {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.log.Error(op, "error", err)
+		http.Error(w, "Something went wrong: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]webhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, webhookDelivery{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			EventType:      d.EventType,
+			Payload:        d.Payload,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt,
+			DeliveredAt:    d.DeliveredAt,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Журнал начислений очков, по нему считаются лидерборды за период
+CREATE TABLE IF NOT EXISTS points_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    points INT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX points_log_created_at_idx ON points_log (created_at, user_id);
+CREATE INDEX points_log_user_id_idx ON points_log (user_id, created_at);
+
+-- Уже набранные очки переносим одной записью, чтобы журнал сходился с users.score
+INSERT INTO points_log (user_id, points, reason, created_at)
+SELECT id, score, 'initial_balance', registered FROM users WHERE score <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS points_log;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS seasons (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL UNIQUE, -- уникальность не даёт нескольким репликам создать один и тот же сезон
+    ends_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    CHECK (starts_at < ends_at)
+);
+
+-- Итоговая таблица закрытого сезона
+CREATE TABLE IF NOT EXISTS season_standings (
+    season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rank INT NOT NULL,
+    score INT NOT NULL,
+    prize INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (season_id, user_id)
+);
+
+CREATE INDEX season_standings_rank_idx ON season_standings (season_id, rank);
+
+-- Очки текущего сезона, обнуляются при закрытии сезона, score остаётся как очки за всё время
+ALTER TABLE users ADD COLUMN season_score INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS season_score;
+DROP TABLE IF EXISTS season_standings;
+DROP TABLE IF EXISTS seasons;
+-- +goose StatementEnd
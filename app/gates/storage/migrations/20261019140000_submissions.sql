@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Заявки о выполнении заданий с доказательством, очки начисляются только после одобрения модератором
+CREATE TABLE IF NOT EXISTS submissions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task VARCHAR(255) NOT NULL,
+    points INT NOT NULL,
+    proof_url TEXT,
+    proof_key TEXT, -- ключ файла в хранилище доказательств
+    proof_content_type VARCHAR(255),
+    status VARCHAR(32) NOT NULL DEFAULT 'pending', -- pending, approved, rejected
+    comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMPTZ,
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- не больше одной непроверенной заявки пользователя на задание
+CREATE UNIQUE INDEX submissions_pending_user_task_idx ON submissions (user_id, task) WHERE status = 'pending';
+CREATE INDEX submissions_status_idx ON submissions (status, id);
+CREATE INDEX submissions_user_idx ON submissions (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS submissions;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- часовой пояс пользователя для подсчёта дней (NULL - пояс по умолчанию из конфига)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+
+-- серии дней подряд по ежедневным заданиям
+CREATE TABLE IF NOT EXISTS user_streaks (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task VARCHAR(255) NOT NULL,
+    current INT NOT NULL DEFAULT 0,
+    longest INT NOT NULL DEFAULT 0,
+    last_day DATE, -- последний день выполнения в часовом поясе пользователя
+    PRIMARY KEY (user_id, task)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_streaks;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Полученные достижения, каждое выдаётся пользователю один раз
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement VARCHAR(255) NOT NULL,
+    points INT NOT NULL DEFAULT 0, -- начисленный бонус
+    earned_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, achievement)
+);
+
+-- для подсчёта выполнений заданий пользователя
+CREATE INDEX IF NOT EXISTS points_log_user_reason_idx ON points_log (user_id, reason);
+CREATE INDEX IF NOT EXISTS users_invited_by_idx ON users (invited_by);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_invited_by_idx;
+DROP INDEX IF EXISTS points_log_user_reason_idx;
+DROP TABLE IF EXISTS user_achievements;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- опыт - сумма всех заработанных очков, в отличие от score не уменьшается при списаниях
+ALTER TABLE users ADD COLUMN IF NOT EXISTS xp BIGINT NOT NULL DEFAULT 0;
+UPDATE users SET xp = GREATEST(score, 0);
+
+-- достигнутые уровни, бонус за уровень начисляется один раз
+CREATE TABLE IF NOT EXISTS user_levels (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    level INT NOT NULL,
+    bonus INT NOT NULL DEFAULT 0,
+    reached_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, level)
+);
+
+-- пользователи, у которых уже был опыт до появления уровней. Достигнутые уровни им отмечаются без бонуса при старте
+-- сервиса (LevelService.Backfill) по кривой из конфига, иначе первое начисление выдало бы бонусы за все уровни сразу
+CREATE TABLE IF NOT EXISTS level_backfill (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE
+);
+INSERT INTO level_backfill (user_id) SELECT id FROM users WHERE xp > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS level_backfill;
+DROP TABLE IF EXISTS user_levels;
+ALTER TABLE users DROP COLUMN IF EXISTS xp;
+-- +goose StatementEnd
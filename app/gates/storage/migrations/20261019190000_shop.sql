@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Магазин наград за очки: каталог товаров и заказы пользователей
+CREATE TABLE IF NOT EXISTS shop_items (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    cost INT NOT NULL CHECK (cost > 0),
+    stock INT CHECK (stock >= 0), -- NULL - без ограничения остатка
+    per_user_limit INT CHECK (per_user_limit > 0), -- NULL - без ограничения на пользователя
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS shop_orders (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL REFERENCES shop_items(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    cost INT NOT NULL, -- списанные очки за весь заказ
+    status VARCHAR(32) NOT NULL DEFAULT 'pending', -- pending, fulfilled, cancelled
+    comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX shop_orders_user_item_idx ON shop_orders (user_id, item_id) WHERE status <> 'cancelled';
+CREATE INDEX shop_orders_status_idx ON shop_orders (status, id);
+CREATE INDEX shop_orders_user_idx ON shop_orders (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shop_orders;
+DROP TABLE IF EXISTS shop_items;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Переводы очков между пользователями, обе стороны и комиссия пишутся в points_log
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMPTZ; -- NULL - аккаунт не заблокирован
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id BIGSERIAL PRIMARY KEY,
+    from_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INT NOT NULL CHECK (amount > 0), -- сколько получил получатель
+    fee INT NOT NULL DEFAULT 0, -- комиссия, списывается с отправителя сверх amount
+    comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (from_user_id <> to_user_id)
+);
+
+CREATE INDEX transfers_from_user_idx ON transfers (from_user_id, created_at);
+CREATE INDEX transfers_to_user_idx ON transfers (to_user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS transfers;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Партии начисленных очков для сгорания: каждое начисление - партия, списания расходуют партии
+-- от старых к новым (FIFO). Срок жизни партии считается от earned_at по points_expiration.months
+CREATE TABLE IF NOT EXISTS point_batches (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    points INT NOT NULL, -- начислено
+    remaining INT NOT NULL CHECK (remaining >= 0), -- ещё не потрачено и не сгорело
+    earned_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX point_batches_user_idx ON point_batches (user_id, earned_at, id) WHERE remaining > 0;
+CREATE INDEX point_batches_earned_at_idx ON point_batches (earned_at) WHERE remaining > 0;
+
+-- текущий баланс считаем одной партией, заработанной в момент миграции
+INSERT INTO point_batches (user_id, points, remaining, earned_at)
+SELECT id, score, score, NOW() FROM users WHERE score > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS point_batches;
+-- +goose StatementEnd
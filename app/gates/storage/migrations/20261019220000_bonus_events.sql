@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Бонусные события: множитель очков за задания в окне времени
+CREATE TABLE IF NOT EXISTS bonus_events (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    multiplier NUMERIC(6, 2) NOT NULL CHECK (multiplier > 0),
+    tasks TEXT[] NOT NULL DEFAULT '{}', -- пустой - все задания
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX bonus_events_window_idx ON bonus_events (ends_at, starts_at);
+
+-- множитель, с которым посчитано начисление
+ALTER TABLE points_log ADD COLUMN IF NOT EXISTS multiplier NUMERIC(6, 2) NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE points_log DROP COLUMN IF EXISTS multiplier;
+DROP TABLE IF EXISTS bonus_events;
+-- +goose StatementEnd
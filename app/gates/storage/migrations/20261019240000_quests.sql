@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Прогресс квестов: step - кол-во выполненных подряд шагов за период (день или 1970-01-01 для одноразовых)
+CREATE TABLE IF NOT EXISTS user_quests (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    quest VARCHAR(64) NOT NULL,
+    period DATE NOT NULL,
+    step INT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, quest, period)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_quests;
+-- +goose StatementEnd
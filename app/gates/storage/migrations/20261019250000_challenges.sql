@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Вызовы между пользователями: ставки списываются при создании (вызывающий) и принятии (соперник)
+-- и возвращаются или достаются победителю при закрытии
+CREATE TABLE IF NOT EXISTS challenges (
+    id BIGSERIAL PRIMARY KEY,
+    challenger_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    opponent_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task VARCHAR(64) NOT NULL,
+    stake INT NOT NULL CHECK (stake > 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    ends_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    winner_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    challenger_count INT NOT NULL DEFAULT 0,
+    opponent_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ,
+    CHECK (challenger_id <> opponent_id)
+);
+
+CREATE INDEX IF NOT EXISTS challenges_challenger_idx ON challenges (challenger_id, id);
+CREATE INDEX IF NOT EXISTS challenges_opponent_idx ON challenges (opponent_id, id);
+-- открытые вызовы по сроку для планировщика
+CREATE INDEX IF NOT EXISTS challenges_due_idx ON challenges (ends_at) WHERE status IN ('pending', 'active');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS challenges;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Удалённые аккаунты обезличиваются, а не удаляются: журнал начислений и ссылки invited_by остаются целыми
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Время подтверждения текущего email пользователя, NULL - не подтверждён
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- пользователи, зарегистрированные до подтверждения email, считаются подтверждёнными,
+-- иначе включение email.require_verified лишит их начислений
+UPDATE users SET email_verified_at = COALESCE(registered, NOW()) WHERE deleted_at IS NULL;
+
+-- Одноразовые токены подтверждения email, хранится только sha256 токена
+CREATE TABLE IF NOT EXISTS email_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS email_tokens_user_id_idx ON email_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS email_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
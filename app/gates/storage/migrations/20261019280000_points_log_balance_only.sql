@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Движения только баланса (магазин, переводы, сгорание, ставки вызовов) не считаются заработанными очками
+-- и не попадают в лидерборды за период и итоги сезона
+ALTER TABLE points_log ADD COLUMN IF NOT EXISTS balance_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE points_log SET balance_only = TRUE
+WHERE reason LIKE 'shop\_%' OR reason LIKE 'transfer\_%' OR reason LIKE 'challenge\_%' OR reason = 'points_expired';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE points_log DROP COLUMN IF EXISTS balance_only;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Повторы публикации с задержкой: relay арендует событие до next_attempt_at, после max_attempts оно помечается failed_at
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_unpublished_idx;
+CREATE INDEX outbox_due_idx ON outbox (next_attempt_at, id) WHERE published_at IS NULL AND failed_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_due_idx;
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+ALTER TABLE outbox DROP COLUMN IF EXISTS failed_at;
+ALTER TABLE outbox DROP COLUMN IF EXISTS next_attempt_at;
+-- +goose StatementEnd
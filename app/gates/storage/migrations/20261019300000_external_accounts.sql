@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- аккаунты во внешних сервисах, которыми пользователи подтверждали задания: аккаунт принадлежит одному пользователю,
+-- у пользователя один аккаунт в каждом сервисе
+CREATE TABLE IF NOT EXISTS external_accounts (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    service VARCHAR(32) NOT NULL, -- telegram, twitter
+    account VARCHAR(255) NOT NULL, -- id в Telegram, имя в X/Twitter в нижнем регистре
+    bound_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (service, account),
+    CONSTRAINT external_accounts_user_service_key UNIQUE (user_id, service)
+);
+
+-- задания с внешней проверкой засчитываются пользователю один раз
+CREATE TABLE IF NOT EXISTS verified_tasks (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task VARCHAR(255) NOT NULL,
+    account VARCHAR(255) NOT NULL,
+    completed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, task)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS verified_tasks;
+DROP TABLE IF EXISTS external_accounts;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- множитель бонусных событий на момент подачи заявки, очки заявки уже посчитаны с ним и начисляются при одобрении
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS multiplier NUMERIC(6, 2) NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE submissions DROP COLUMN IF EXISTS multiplier;
+-- +goose StatementEnd
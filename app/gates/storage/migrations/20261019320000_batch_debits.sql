@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Из каких партий списаны очки: возврат списания (отмена заказа, возврат ставки) и перевод восстанавливают
+-- даты сгорания списанных партий. Строка удаляется, когда списание возвращено
+CREATE TABLE IF NOT EXISTS batch_debits (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL, -- reason списания в points_log
+    batch_id BIGINT NOT NULL,
+    points INT NOT NULL,
+    earned_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX batch_debits_user_reason_idx ON batch_debits (user_id, reason);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS batch_debits;
+-- +goose StatementEnd
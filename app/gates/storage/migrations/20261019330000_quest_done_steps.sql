@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- выполненные шаги квеста битами (бит i - шаг i): в нестрогих квестах шаги выполняются в любом порядке.
+-- До этого шаги засчитывались только по порядку, поэтому выполнены первые step шагов
+ALTER TABLE user_quests ADD COLUMN IF NOT EXISTS done BIGINT NOT NULL DEFAULT 0;
+UPDATE user_quests SET done = (1::BIGINT << step) - 1 WHERE step > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_quests DROP COLUMN IF EXISTS done;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CompleteAccountTask - привязка аккаунта, отметка задания и начисление в одной транзакции. Параллельные заявки
+// одного аккаунта от разных пользователей или одного задания от пользователя упираются в первичные ключи
+func (p *Store) CompleteAccountTask(ctx context.Context, link domain.ExternalAccount, task string, award domain.Award, events ...domain.Event) error {
+	const op = "storage.PostgreSQL.CompleteAccountTask"
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		//при конфликте строка не меняется, но возвращается владелец уже привязанного аккаунта
+		var owner domain.UserID
+		err := tx.GetContext(ctx, &owner, `INSERT INTO external_accounts (user_id, service, account) VALUES ($1, $2, $3)
+ON CONFLICT (service, account) DO UPDATE SET service = EXCLUDED.service
+RETURNING user_id`, link.UserID, link.Service, link.Account)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation && pqErr.Constraint == "external_accounts_user_service_key" {
+			return domain.ErrAccountMismatch
+		}
+		if err != nil {
+			return err
+		}
+		if owner != link.UserID {
+			return domain.ErrAccountTaken
+		}
+		res, err := tx.ExecContext(ctx, `INSERT INTO verified_tasks (user_id, task, account, completed_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, task) DO NOTHING`, link.UserID, task, link.Account, award.At)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return domain.ErrTaskAlreadyCompleted
+		}
+		if err := p.addPoints(ctx, tx, award); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil && !errors.Is(err, domain.ErrAccountTaken) && !errors.Is(err, domain.ErrAccountMismatch) && !errors.Is(err, domain.ErrTaskAlreadyCompleted) {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+func (p *Store) AchievementStats(ctx context.Context, id domain.UserID) (domain.AchievementStats, error) {
+	const op = "storage.PostgreSQL.AchievementStats"
+	stats := domain.AchievementStats{
+		Completions: make(map[string]int),
+		Streaks:     make(map[string]int),
+		Earned:      make(map[string]bool),
+	}
+	err := p.db.GetContext(ctx, &stats.Score, "SELECT score FROM users WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, domain.ErrUserNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return stats, err
+	}
+	var counts []struct {
+		Key   string `db:"key"`
+		Count int    `db:"count"`
+	}
+	if err = p.db.SelectContext(ctx, &counts, `SELECT reason AS key, COUNT(*) AS count FROM points_log
+WHERE user_id = $1 GROUP BY reason`, id); err != nil {
+		p.log.Error(op, "error", err)
+		return stats, err
+	}
+	for _, c := range counts {
+		stats.Completions[c.Key] = c.Count
+	}
+	counts = counts[:0]
+	if err = p.db.SelectContext(ctx, &counts, "SELECT task AS key, longest AS count FROM user_streaks WHERE user_id = $1", id); err != nil {
+		p.log.Error(op, "error", err)
+		return stats, err
+	}
+	for _, c := range counts {
+		stats.Streaks[c.Key] = c.Count
+	}
+	if err = p.db.GetContext(ctx, &stats.Referrals, "SELECT COUNT(*) FROM users WHERE invited_by = $1", id); err != nil {
+		p.log.Error(op, "error", err)
+		return stats, err
+	}
+	var earned []string
+	if err = p.db.SelectContext(ctx, &earned, "SELECT achievement FROM user_achievements WHERE user_id = $1", id); err != nil {
+		p.log.Error(op, "error", err)
+		return stats, err
+	}
+	for _, key := range earned {
+		stats.Earned[key] = true
+	}
+	return stats, nil
+}
+
+func (p *Store) PeriodRank(ctx context.Context, id domain.UserID, window *domain.TimeWindow, ranking string) (int64, domain.UserScore, error) {
+	const op = "storage.PostgreSQL.PeriodRank"
+	qry, args, err := p.sq.Select("rank", "score").
+		FromSelect(p.sq.Select("id", "score", rankExpr(ranking)+" AS rank").FromSelect(p.scores(window), "s"), "r").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, 0, err
+	}
+	var row struct {
+		Rank  int64            `db:"rank"`
+		Score domain.UserScore `db:"score"`
+	}
+	err = p.db.GetContext(ctx, &row, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, domain.ErrUserNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, 0, err
+	}
+	return row.Rank, row.Score, nil
+}
+
+func (p *Store) GrantAchievement(ctx context.Context, id domain.UserID, achievement domain.Achievement, award *domain.Award, events ...domain.Event) (bool, error) {
+	const op = "storage.PostgreSQL.GrantAchievement"
+	granted := false
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `INSERT INTO user_achievements (user_id, achievement, points, earned_at)
+VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, achievement) DO NOTHING`, id, achievement.Key, achievement.Points, achievement.EarnedAt)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		//достижение уже выдано (параллельной проверкой) - бонус второй раз не начисляем
+		if rows == 0 {
+			return nil
+		}
+		granted = true
+		if award != nil {
+			if err := p.addPoints(ctx, tx, *award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return false, err
+	}
+	return granted, nil
+}
+
+func (p *Store) GetAchievements(ctx context.Context, id domain.UserID) ([]domain.Achievement, error) {
+	const op = "storage.PostgreSQL.GetAchievements"
+	achievements := []domain.Achievement{}
+	err := p.db.SelectContext(ctx, &achievements, `SELECT achievement, points, earned_at FROM user_achievements
+WHERE user_id = $1 ORDER BY earned_at, achievement`, id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return achievements, nil
+}
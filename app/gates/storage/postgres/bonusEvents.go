@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var bonusEventColumns = []string{"id", "name", "multiplier", "tasks", "starts_at", "ends_at", "created_at"}
+
+// bonusEvent - строка bonus_events, tasks хранится массивом postgres
+type bonusEvent struct {
+	ID         domain.BonusEventID `db:"id"`
+	Name       string              `db:"name"`
+	Multiplier float64             `db:"multiplier"`
+	Tasks      pq.StringArray      `db:"tasks"`
+	StartsAt   time.Time           `db:"starts_at"`
+	EndsAt     time.Time           `db:"ends_at"`
+	CreatedAt  time.Time           `db:"created_at"`
+}
+
+func (e bonusEvent) toDomain() domain.BonusEvent {
+	return domain.BonusEvent{
+		ID:         e.ID,
+		Name:       e.Name,
+		Multiplier: e.Multiplier,
+		Tasks:      []string(e.Tasks),
+		StartsAt:   e.StartsAt,
+		EndsAt:     e.EndsAt,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func (p *Store) AddBonusEvent(ctx context.Context, event domain.BonusEvent) (domain.BonusEvent, error) {
+	const op = "storage.PostgreSQL.AddBonusEvent"
+	tasks := pq.StringArray(event.Tasks)
+	if tasks == nil {
+		tasks = pq.StringArray{}
+	}
+	qry, args, err := p.sq.Insert("bonus_events").
+		Columns("name", "multiplier", "tasks", "starts_at", "ends_at", "created_at").
+		Values(event.Name, event.Multiplier, tasks, event.StartsAt, event.EndsAt, event.CreatedAt).
+		Suffix("RETURNING " + strings.Join(bonusEventColumns, ", ")).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return domain.BonusEvent{}, err
+	}
+	var created bonusEvent
+	if err = p.db.GetContext(ctx, &created, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return domain.BonusEvent{}, err
+	}
+	return created.toDomain(), nil
+}
+
+func (p *Store) DeleteBonusEvent(ctx context.Context, id domain.BonusEventID) error {
+	const op = "storage.PostgreSQL.DeleteBonusEvent"
+	res, err := p.db.ExecContext(ctx, "DELETE FROM bonus_events WHERE id = $1", id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrBonusEventNotFound
+	}
+	return nil
+}
+
+func (p *Store) ListBonusEvents(ctx context.Context, at *time.Time) ([]domain.BonusEvent, error) {
+	const op = "storage.PostgreSQL.ListBonusEvents"
+	query := p.sq.Select(bonusEventColumns...).From("bonus_events")
+	if at != nil {
+		query = query.Where(sq.And{sq.LtOrEq{"starts_at": *at}, sq.Gt{"ends_at": *at}})
+	}
+	qry, args, err := query.OrderBy("starts_at", "id").ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	var rows []bonusEvent
+	if err = p.db.SelectContext(ctx, &rows, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	events := make([]domain.BonusEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toDomain())
+	}
+	return events, nil
+}
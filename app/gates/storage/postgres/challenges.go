@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var challengeColumns = []string{"id", "challenger_id", "opponent_id", "task", "stake", "status", "ends_at", "accepted_at",
+	"winner_id", "challenger_count", "opponent_count", "created_at", "closed_at"}
+
+func (p *Store) CreateChallenge(ctx context.Context, c domain.Challenge, create func(c domain.Challenge) (domain.Award, []domain.Event)) (domain.Challenge, error) {
+	const op = "storage.PostgreSQL.CreateChallenge"
+	var created domain.Challenge
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		qry, args, err := p.sq.Insert("challenges").
+			Columns("challenger_id", "opponent_id", "task", "stake", "status", "ends_at", "created_at").
+			Values(c.ChallengerID, c.OpponentID, c.Task, c.Stake, c.Status, c.EndsAt, c.CreatedAt).
+			Suffix("RETURNING " + strings.Join(challengeColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &created, qry, args...)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return domain.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		award, events := create(created)
+		if err := p.addPoints(ctx, tx, award); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) && !errors.Is(err, domain.ErrInsufficientPoints) {
+		p.log.Error(op, "error", err)
+	}
+	return created, err
+}
+
+func (p *Store) GetChallenge(ctx context.Context, id domain.ChallengeID) (domain.Challenge, error) {
+	const op = "storage.PostgreSQL.GetChallenge"
+	var c domain.Challenge
+	err := p.db.GetContext(ctx, &c, "SELECT "+strings.Join(challengeColumns, ", ")+" FROM challenges WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, domain.ErrChallengeNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return c, err
+	}
+	return c, nil
+}
+
+func (p *Store) ListChallenges(ctx context.Context, id domain.UserID, status string, page int, size int) ([]domain.Challenge, error) {
+	const op = "storage.PostgreSQL.ListChallenges"
+	query := p.sq.Select(challengeColumns...).From("challenges").
+		Where(sq.Or{sq.Eq{"challenger_id": id}, sq.Eq{"opponent_id": id}})
+	if status != "" {
+		query = query.Where(sq.Eq{"status": status})
+	}
+	qry, args, err := query.OrderBy("id DESC").
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	challenges := []domain.Challenge{}
+	if err = p.db.SelectContext(ctx, &challenges, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return challenges, nil
+}
+
+func (p *Store) DueChallenges(ctx context.Context, at time.Time, limit int) ([]domain.Challenge, error) {
+	const op = "storage.PostgreSQL.DueChallenges"
+	qry, args, err := p.sq.Select(challengeColumns...).From("challenges").
+		Where(sq.Eq{"status": []string{domain.ChallengePending, domain.ChallengeActive}}).
+		Where(sq.LtOrEq{"ends_at": at}).
+		OrderBy("ends_at", "id").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	challenges := []domain.Challenge{}
+	if err = p.db.SelectContext(ctx, &challenges, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return challenges, nil
+}
+
+func (p *Store) CountCompletions(ctx context.Context, task string, users []domain.UserID, from time.Time, to time.Time) (map[domain.UserID]int, error) {
+	const op = "storage.PostgreSQL.CountCompletions"
+	qry, args, err := p.sq.Select("user_id", "COUNT(*) AS completions").From("points_log").
+		Where(sq.Eq{"user_id": users, "reason": task}).
+		Where(sq.GtOrEq{"created_at": from}).
+		Where(sq.Lt{"created_at": to}).
+		GroupBy("user_id").
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	var rows []struct {
+		UserID      domain.UserID `db:"user_id"`
+		Completions int           `db:"completions"`
+	}
+	if err = p.db.SelectContext(ctx, &rows, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	counts := make(map[domain.UserID]int, len(users))
+	for _, row := range rows {
+		counts[row.UserID] = row.Completions
+	}
+	return counts, nil
+}
+
+// UpdateChallenge - строка вызова блокируется FOR UPDATE, поэтому принятие, отмена и подведение итога
+// одного вызова идут по очереди и ставки не вернутся дважды
+func (p *Store) UpdateChallenge(ctx context.Context, id domain.ChallengeID, update func(c domain.Challenge) (domain.Challenge, []domain.Award, []domain.Event, error)) (domain.Challenge, error) {
+	const op = "storage.PostgreSQL.UpdateChallenge"
+	var updated domain.Challenge
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var c domain.Challenge
+		err := tx.GetContext(ctx, &c, "SELECT "+strings.Join(challengeColumns, ", ")+" FROM challenges WHERE id = $1 FOR UPDATE", id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrChallengeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		c, awards, events, err := update(c)
+		if err != nil {
+			return err
+		}
+		qry, args, err := p.sq.Update("challenges").
+			Set("status", c.Status).
+			Set("accepted_at", c.AcceptedAt).
+			Set("winner_id", c.WinnerID).
+			Set("challenger_count", c.ChallengerCount).
+			Set("opponent_count", c.OpponentCount).
+			Set("closed_at", c.ClosedAt).
+			Where(sq.Eq{"id": id}).
+			Suffix("RETURNING " + strings.Join(challengeColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if err := tx.GetContext(ctx, &updated, qry, args...); err != nil {
+			return err
+		}
+		for _, award := range awards {
+			if err := p.addPoints(ctx, tx, award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	switch {
+	case errors.Is(err, domain.ErrChallengeNotFound), errors.Is(err, domain.ErrChallengeClosed),
+		errors.Is(err, domain.ErrNotChallengeParticipant), errors.Is(err, domain.ErrInsufficientPoints):
+		return domain.Challenge{}, err
+	case err != nil:
+		p.log.Error(op, "error", err)
+		return domain.Challenge{}, err
+	}
+	return updated, nil
+}
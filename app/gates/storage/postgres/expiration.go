@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (p *Store) ExpiringBatches(ctx context.Context, id domain.UserID, earnedBefore time.Time) ([]domain.PointBatch, error) {
+	const op = "storage.PostgreSQL.ExpiringBatches"
+	batches := []domain.PointBatch{}
+	err := p.db.SelectContext(ctx, &batches, `SELECT points, remaining, earned_at FROM point_batches
+WHERE user_id = $1 AND remaining > 0 AND earned_at < $2 ORDER BY earned_at, id`, id, earnedBefore)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return batches, nil
+}
+
+// ExpirePoints - списание идёт через addPoints, поэтому FIFO расходует как раз просроченные (самые старые) партии
+func (p *Store) ExpirePoints(ctx context.Context, earnedBefore time.Time, limit int, expire func(id domain.UserID, points int) (domain.Award, []domain.Event)) ([]domain.Award, error) {
+	const op = "storage.PostgreSQL.ExpirePoints"
+	var ids []domain.UserID
+	err := p.db.SelectContext(ctx, &ids, `SELECT DISTINCT user_id FROM point_batches
+WHERE remaining > 0 AND earned_at < $1 LIMIT $2`, earnedBefore, limit)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	var awards []domain.Award
+	for _, id := range ids {
+		var award domain.Award
+		err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+			//блокируем пользователя, чтобы параллельные начисления и списания не меняли партии
+			var score int
+			err := tx.GetContext(ctx, &score, "SELECT score FROM users WHERE id = $1 FOR UPDATE", id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			var expired int
+			err = tx.GetContext(ctx, &expired, `SELECT COALESCE(SUM(remaining), 0) FROM point_batches
+WHERE user_id = $1 AND remaining > 0 AND earned_at < $2`, id, earnedBefore)
+			if err != nil {
+				return err
+			}
+			//баланс мог разойтись с партиями (например, после ручной правки), в минус не уводим
+			if expired = min(expired, score); expired > 0 {
+				var events []domain.Event
+				award, events = expire(id, expired)
+				if err := p.addPoints(ctx, tx, award); err != nil {
+					return err
+				}
+				if err := p.insertOutbox(ctx, tx, events); err != nil {
+					return err
+				}
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE point_batches SET remaining = 0 WHERE user_id = $1 AND remaining > 0 AND earned_at < $2",
+				id, earnedBefore)
+			return err
+		})
+		if err != nil {
+			p.log.Error(op, "user_id", id, "error", err)
+			return awards, err
+		}
+		if award.Points != 0 {
+			awards = append(awards, award)
+		}
+	}
+	return awards, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type idempotencyRow struct {
+	Fingerprint string         `db:"fingerprint"`
+	StatusCode  sql.NullInt64  `db:"status_code"`
+	ContentType sql.NullString `db:"content_type"`
+	Body        []byte         `db:"response_body"`
+}
+
+// AcquireIdempotencyKey - ключ занимается вставкой строки, конфликт по (user_id, key) перезаписывает строку
+// только если она истекла или зависла (запрос упал, не сохранив ответ), иначе читаем существующую
+func (p *Store) AcquireIdempotencyKey(ctx context.Context, userID domain.UserID, key string, fingerprint string, now time.Time, expiresAt time.Time, lockTimeout time.Duration) (*domain.IdempotentResponse, error) {
+	const op = "storage.PostgreSQL.AcquireIdempotencyKey"
+	var acquired bool
+	err := p.db.GetContext(ctx, &acquired, `INSERT INTO idempotency_keys (user_id, key, fingerprint, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at,
+	status_code = NULL, content_type = NULL, response_body = NULL
+WHERE idempotency_keys.expires_at <= $4
+	OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= $6)
+RETURNING true`, userID, key, fingerprint, now, expiresAt, now.Add(-lockTimeout))
+	if err == nil && acquired {
+		return nil, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	var row idempotencyRow
+	err = p.db.GetContext(ctx, &row, `SELECT fingerprint, status_code, content_type, response_body
+FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		//ключ только что освободили (запрос завершился ошибкой), клиенту стоит повторить
+		return nil, domain.ErrIdempotencyInFlight
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	if row.Fingerprint != fingerprint {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+	if !row.StatusCode.Valid {
+		return nil, domain.ErrIdempotencyInFlight
+	}
+	return &domain.IdempotentResponse{
+		StatusCode:  int(row.StatusCode.Int64),
+		ContentType: row.ContentType.String,
+		Body:        row.Body,
+	}, nil
+}
+
+func (p *Store) SaveIdempotentResponse(ctx context.Context, userID domain.UserID, key string, resp domain.IdempotentResponse) error {
+	const op = "storage.PostgreSQL.SaveIdempotentResponse"
+	_, err := p.db.ExecContext(ctx, `UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5
+WHERE user_id = $1 AND key = $2`, userID, key, resp.StatusCode, resp.ContentType, resp.Body)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+// ReleaseIdempotencyKey удаляет только незавершённый ключ, сохранённый ответ остаётся
+func (p *Store) ReleaseIdempotencyKey(ctx context.Context, userID domain.UserID, key string) error {
+	const op = "storage.PostgreSQL.ReleaseIdempotencyKey"
+	_, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status_code IS NULL`, userID, key)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.PostgreSQL.PurgeIdempotencyKeys"
+	res, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+func (p *Store) LastLevel(ctx context.Context, id domain.UserID) (int, error) {
+	const op = "storage.PostgreSQL.LastLevel"
+	var level int
+	err := p.db.GetContext(ctx, &level, "SELECT COALESCE(MAX(level), 1) FROM user_levels WHERE user_id = $1", id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, err
+	}
+	return level, nil
+}
+
+// BackfillLevels - очередь разбирается с SKIP LOCKED, поэтому несколько реплик при старте не мешают друг другу
+func (p *Store) BackfillLevels(ctx context.Context, limit int, level func(xp int64) int) (int, error) {
+	const op = "storage.PostgreSQL.BackfillLevels"
+	var users []struct {
+		ID domain.UserID `db:"id"`
+		XP int64         `db:"xp"`
+	}
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.SelectContext(ctx, &users, `SELECT users.id, users.xp FROM level_backfill
+JOIN users ON users.id = level_backfill.user_id
+ORDER BY level_backfill.user_id LIMIT $1 FOR UPDATE OF level_backfill SKIP LOCKED`, limit)
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, int64(u.ID))
+			if reached := level(u.XP); reached >= 2 {
+				_, err = tx.ExecContext(ctx, `INSERT INTO user_levels (user_id, level, bonus)
+SELECT $1, generate_series(2, $2), 0 ON CONFLICT (user_id, level) DO NOTHING`, u.ID, reached)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM level_backfill WHERE user_id = ANY($1)", pq.Array(ids))
+		return err
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, err
+	}
+	return len(users), nil
+}
+
+func (p *Store) GrantLevel(ctx context.Context, id domain.UserID, level int, at time.Time, award *domain.Award, events ...domain.Event) (bool, error) {
+	const op = "storage.PostgreSQL.GrantLevel"
+	bonus := 0
+	if award != nil {
+		bonus = award.Points
+	}
+	granted := false
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `INSERT INTO user_levels (user_id, level, bonus, reached_at)
+VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, level) DO NOTHING`, id, level, bonus, at)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		//уровень уже отмечен параллельной проверкой - бонус второй раз не начисляем
+		if rows == 0 {
+			return nil
+		}
+		granted = true
+		if award != nil {
+			if err := p.addPoints(ctx, tx, *award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return false, err
+	}
+	return granted, nil
+}
@@ -6,6 +6,7 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/bool64/sqluct"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"log/slog"
 	"time"
 )
@@ -24,15 +25,41 @@ type user struct {
 	score      domain.UserScore `db:"score"`
 	registered time.Time        `db:"registered"`
 	invitedBy  *domain.UserID   `db:"invited_by"`
+	timezone   *string          `db:"timezone"`
 }
 
-var ErrUserAlreadyInvited = errors.New("User already invited")
-var errNoRowsAffected = errors.New("No rows affected")
+// колонки users, которые отдаются в domain.User
+var userColumns = []string{"id", "nickname", "email", "score", "season_score", "xp", "registered", "invited_by", "timezone", "suspended_at",
+	"email_verified_at"}
+
+// коды ошибок postgres и имена ограничений из миграции users
+const (
+	pqUniqueViolation       pq.ErrorCode = "23505"
+	constraintNickname                   = "users_nickname_key"
+	constraintEmail                      = "users_email_key"
+	constraintNicknameEmail              = "unique_nickname_email"
+)
+
+// mapUniqueViolation переводит ошибку уникальности postgres в доменную ошибку, остальные ошибки возвращает как есть
+func mapUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pqUniqueViolation {
+		return err
+	}
+	switch pqErr.Constraint {
+	case constraintNickname, constraintNicknameEmail:
+		return domain.ErrNicknameTaken
+	case constraintEmail:
+		return domain.ErrEmailTaken
+	}
+	return err
+}
 
 func fromDomain(duser domain.User) user {
 	return user{
 		nickname: duser.Nickname,
 		email:    duser.Email,
+		timezone: duser.Timezone,
 	}
 }
 
@@ -44,5 +71,6 @@ func toDomain(usr user) domain.User {
 		Score:      usr.score,
 		Registered: usr.registered,
 		InvitedBy:  usr.invitedBy,
+		Timezone:   usr.timezone,
 	}
 }
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"app/domain"
+	"cmp"
+	"context"
+	"encoding/json"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"time"
+)
+
+// insertOutbox пишет события в outbox, вызывается внутри транзакции вместе с изменениями, которые эти события описывают
+func (p *Store) insertOutbox(ctx context.Context, ex sqlx.ExtContext, events []domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	query := p.sq.Insert("outbox").Columns("event_id", "event_type", "payload", "created_at")
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		query = query.Values(event.ID, event.Type, payload, event.OccurredAt)
+	}
+	qry, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = ex.ExecContext(ctx, qry, args...)
+	return err
+}
+
+// ClaimOutbox - строки блокируются только на время аренды, параллельные relay (на других репликах)
+// пропускают их благодаря SKIP LOCKED, а после коммита - по next_attempt_at
+func (p *Store) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error) {
+	const op = "storage.PostgreSQL.ClaimOutbox"
+	events := []domain.OutboxEvent{}
+	err := p.db.SelectContext(ctx, &events, `WITH due AS (
+	SELECT id FROM outbox
+	WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= $1
+	ORDER BY id
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE outbox o SET next_attempt_at = $2
+FROM due
+WHERE o.id = due.id
+RETURNING o.id, o.payload, o.attempts`, now, now.Add(lease), limit)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	//RETURNING не сохраняет порядок, публикуем в порядке записи
+	slices.SortFunc(events, func(a, b domain.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return events, nil
+}
+
+func (p *Store) MarkOutboxPublished(ctx context.Context, id int64, at time.Time) error {
+	const op = "storage.PostgreSQL.MarkOutboxPublished"
+	_, err := p.db.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, published_at = $2, last_error = NULL WHERE id = $1`, id, at)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) RetryOutbox(ctx context.Context, id int64, lastErr string, next time.Time) error {
+	const op = "storage.PostgreSQL.RetryOutbox"
+	_, err := p.db.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`, id, lastErr, next)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) MarkOutboxFailed(ctx context.Context, id int64, lastErr string, at time.Time) error {
+	const op = "storage.PostgreSQL.MarkOutboxFailed"
+	_, err := p.db.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2, failed_at = $3 WHERE id = $1`, id, lastErr, at)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
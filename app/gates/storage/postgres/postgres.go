@@ -2,13 +2,17 @@ package storage
 
 import (
 	"app/domain"
+	"cmp"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/bool64/sqluct"
 	"github.com/jmoiron/sqlx"
 	"log/slog"
+	"slices"
+	"strings"
 )
 
 func NewDB(db *sqlx.DB, log *slog.Logger) *Store {
@@ -20,33 +24,52 @@ func NewDB(db *sqlx.DB, log *slog.Logger) *Store {
 	}
 }
 
+// inTx выполняет fn в транзакции, при ошибке транзакция откатывается
+func (p *Store) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			p.log.Error("storage.Postgres.inTx: failed to rollback", "error", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
 // добавление нового пользователя
-func (p *Store) AddUser(ctx context.Context, duser domain.User) error {
+func (p *Store) AddUser(ctx context.Context, duser domain.User) (domain.User, error) {
 	const op = "storage.Postgres.AddUser"
 	user := fromDomain(duser)
 	p.log.Debug(op, user)
 	p.log.Debug(op, "trying to add user")
 	query := p.sq.Insert("users").
-		Columns("nickname", "email").
-		Values(user.nickname, user.email).
-		Suffix("ON CONFLICT (nickname, email) DO NOTHING")
+		Columns("nickname", "email", "timezone").
+		Values(user.nickname, user.email, user.timezone).
+		Suffix("RETURNING " + strings.Join(userColumns, ", "))
 	qry, args, err := query.ToSql()
 	p.log.Debug(op, "qry: ", qry, "args: ", args)
+	var created domain.User
 	if err != nil {
 		p.log.Error(op, err)
-		return err
+		return created, err
 	}
-	rows, err := p.db.ExecContext(ctx, qry, args...)
+	err = p.inTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &created, qry, args...); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, []domain.Event{domain.UserRegisteredEvent(created)})
+	})
 	if err != nil {
-		p.log.Error(op, err)
-		return err
-	}
-	if rows, _ := rows.RowsAffected(); rows == 0 {
-		p.log.Error(op, "no rows affected")
-		return errNoRowsAffected
+		//занятый никнейм или email отдаём наверх доменной ошибкой, чтобы хендлер мог ответить 409
+		err = mapUniqueViolation(err)
+		p.log.Error(op, "error", err)
+		return created, err
 	}
 	p.log.Debug(fmt.Sprintf("%v: sucessfully added new user", op))
-	return nil
+	return created, nil
 }
 
 // Получение информации по пользователю
@@ -55,26 +78,26 @@ func (p *Store) GetUser(ctx context.Context, id domain.UserID) (domain.User, err
 	p.log.Debug(fmt.Sprintf("%v: trying to get info for user %v", op, id))
 
 	// Явно указываем поля, которые нам нужны из таблицы
-	query := p.sq.Select("id", "nickname", "email", "score", "registered", "invited_by").
+	query := p.sq.Select(userColumns...).
 		From("users").
-		Where(sq.Eq{"id": id})
+		Where(sq.Eq{"id": id, "deleted_at": nil})
 
 	qry, args, err := query.ToSql()
 	p.log.Debug(op, "qry: ", qry, "args: ", args)
 
 	var user domain.User
 
-	// Пытаемся получить данные из базы
-	if err == sql.ErrNoRows {
-		p.log.Debug("user not found")
-		return user, sql.ErrNoRows
-	}
 	if err != nil {
 		p.log.Error(op, err)
 		return user, err
 	}
+	// Пытаемся получить данные из базы
 	p.log.Debug(op, "trying to use GetContext")
 	err = p.db.GetContext(ctx, &user, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		p.log.Debug(op, "user_id", id, "msg", "user not found")
+		return user, domain.ErrUserNotFound
+	}
 	if err != nil {
 		p.log.Error(op, err)
 		return user, err
@@ -86,107 +109,357 @@ func (p *Store) GetUser(ctx context.Context, id domain.UserID) (domain.User, err
 }
 
 // Получение пользователей
-func (p *Store) GetUsers(ctx context.Context, filter string, page int, limit int) ([]domain.User, error) {
+func (p *Store) GetUsers(ctx context.Context, q domain.LeaderboardQuery) ([]domain.LeaderboardEntry, error) {
 	const op = "storage.PostgreSQL.GetUsers"
-	var users []domain.User
+	var users []domain.LeaderboardEntry
 	p.log.Debug(fmt.Sprintf("%v: trying to get all users", op))
-	query := p.sq.Select("id", "nickname", "email", "score", "registered", "invited_by").From("users")
-
-	//фильтрация 0-Рейтинг, 1-алфавит(никнейм), 2-id/дате регистрации
-	switch filter {
-	case "score":
-		query = query.OrderBy("score DESC")
-	case "nickname":
-		query = query.OrderBy("nickname ASC")
-	case "id":
-		query = query.OrderBy("id ASC")
+	//место считается оконной функцией по всей таблице, поэтому фильтры и пагинация применяются уже к подзапросу
+	ranked := p.sq.Select("id", "nickname", "email", "score", "xp", "registered", "invited_by", rankExpr(q.Ranking)+" AS rank").
+		FromSelect(p.scores(q.Window), "scores")
+	query := p.sq.Select("id", "nickname", "email", "score", "xp", "registered", "invited_by", "rank").
+		FromSelect(ranked, "ranked")
+
+	//сортировка по рейтингу, никнейму или id, при равном рейтинге порядок определяет id
+	dir := "ASC"
+	if q.Order == domain.OrderDesc {
+		dir = "DESC"
+	}
+	switch q.SortBy {
+	case domain.SortByScore:
+		query = query.OrderBy("score "+dir, "id ASC")
+	case domain.SortByNickname:
+		query = query.OrderBy("nickname " + dir)
 	default:
-		query = query.OrderBy("id ASC")
+		query = query.OrderBy("id " + dir)
 	}
 
-	//Опциональная пагинация
-	if limit != 0 {
-		offset := (page - 1) * limit
-		query = query.Offset(uint64(offset)).Limit(uint64(limit))
+	//keyset пагинация по курсору, без курсора обычная постраничная
+	if q.Cursor != nil {
+		query = query.Where(keysetCondition(*q.Cursor))
+	} else {
+		offset := (q.Page - 1) * q.Size
+		query = query.Offset(uint64(offset))
 	}
+	query = query.Limit(uint64(q.Size))
 
 	qry, args, err := query.ToSql()
-	p.log.Debug(op, "qry: ", qry, "args: ", args)
+	p.log.Debug(op, "qry", qry, "args", args)
 	if err != nil {
-		p.log.Error(op, err)
+		p.log.Error(op, "error", err)
 		return nil, err
 	}
 	err = p.db.SelectContext(ctx, &users, qry, args...)
 	if err != nil {
-		p.log.Error(op, err)
+		p.log.Error(op, "error", err)
 		return nil, err
 	}
 	p.log.Debug(fmt.Sprintf("%v: success, all users retrieved", op))
 	return users, nil
 }
 
-// добавление score для user по id
-func (p *Store) AddPoints(ctx context.Context, id domain.UserID, points int) error {
+// keysetCondition - условие "строго после курсора" с учётом поля и направления сортировки
+func keysetCondition(c domain.LeaderboardCursor) sq.Sqlizer {
+	cmp := ">"
+	if c.Order == domain.OrderDesc {
+		cmp = "<"
+	}
+	switch c.SortBy {
+	case domain.SortByScore:
+		//id всегда по возрастанию, поэтому сравнение кортежем (score, id) тут не подходит
+		return sq.Or{
+			sq.Expr("score "+cmp+" ?", c.Score),
+			sq.And{sq.Eq{"score": c.Score}, sq.Gt{"id": c.ID}},
+		}
+	case domain.SortByNickname:
+		return sq.Expr("nickname "+cmp+" ?", c.Nickname)
+	default:
+		return sq.Expr("id "+cmp+" ?", c.ID)
+	}
+}
+
+// scores - пользователи (кроме удалённых) с очками за всё время или, если задан window, с суммой очков заработанных за период.
+// Движения только баланса (покупки, переводы, сгорание, ставки) в сумму за период не входят
+func (p *Store) scores(window *domain.TimeWindow) sq.SelectBuilder {
+	if window == nil {
+		return p.sq.Select("id", "nickname", "email", "score", "xp", "registered", "invited_by").From("users").
+			Where(sq.Eq{"deleted_at": nil})
+	}
+	return p.sq.Select("u.id AS id", "u.nickname AS nickname", "u.email AS email",
+		"COALESCE(SUM(pl.points), 0) AS score", "u.xp AS xp", "u.registered AS registered", "u.invited_by AS invited_by").
+		From("users u").
+		LeftJoin("points_log pl ON pl.user_id = u.id AND NOT pl.balance_only AND pl.created_at >= ? AND pl.created_at < ?", window.From, window.To).
+		Where(sq.Eq{"u.deleted_at": nil}).
+		GroupBy("u.id")
+}
+
+// rankExpr - оконная функция нумерации мест по очкам
+func rankExpr(ranking string) string {
+	return rankExprBy(ranking, "score")
+}
+
+func rankExprBy(ranking string, column string) string {
+	if ranking == domain.RankingDense {
+		return "DENSE_RANK() OVER (ORDER BY " + column + " DESC)"
+	}
+	return "RANK() OVER (ORDER BY " + column + " DESC)"
+}
+
+// Место пользователя в рейтинге, процентиль и соседи (в порядке лидерборды по очкам)
+func (p *Store) GetUserRank(ctx context.Context, id domain.UserID, ranking string, neighbours int) (domain.UserRank, error) {
+	const op = "storage.PostgreSQL.GetUserRank"
+	var rank domain.UserRank
+	p.log.Debug(op, "user_id", id, "neighbours", neighbours)
+	//pos - позиция в лидерборде с разрешением ничьих по id, по ней выбираются соседи
+	qry := `WITH ranked AS (
+	SELECT id, nickname, email, score, xp, registered, invited_by,
+		` + rankExpr(ranking) + ` AS rank,
+		ROW_NUMBER() OVER (ORDER BY score DESC, id ASC) AS pos,
+		PERCENT_RANK() OVER (ORDER BY score ASC) * 100 AS percentile,
+		COUNT(*) OVER () AS total
+	FROM users
+	WHERE deleted_at IS NULL
+), me AS (
+	SELECT pos FROM ranked WHERE id = $1
+)
+SELECT ranked.* FROM ranked, me
+WHERE ranked.pos BETWEEN me.pos - $2 AND me.pos + $2
+ORDER BY ranked.pos`
+	var rows []struct {
+		domain.LeaderboardEntry
+		Pos        int64   `db:"pos"`
+		Percentile float64 `db:"percentile"`
+		Total      int     `db:"total"`
+	}
+	err := p.db.SelectContext(ctx, &rows, qry, id, neighbours)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return rank, err
+	}
+	found := false
+	for _, row := range rows {
+		switch {
+		case row.ID == id:
+			rank.LeaderboardEntry = row.LeaderboardEntry
+			rank.Percentile = row.Percentile
+			rank.Total = row.Total
+			found = true
+		case !found:
+			rank.Above = append(rank.Above, row.LeaderboardEntry)
+		default:
+			rank.Below = append(rank.Below, row.LeaderboardEntry)
+		}
+	}
+	if !found {
+		p.log.Debug(op, "user_id", id, "msg", "user not found")
+		return rank, domain.ErrUserNotFound
+	}
+	return rank, nil
+}
+
+// Общее кол-во пользователей для лидерборды
+func (p *Store) CountUsers(ctx context.Context) (int, error) {
+	const op = "storage.PostgreSQL.CountUsers"
+	qry, args, err := p.sq.Select("COUNT(*)").From("users").Where(sq.Eq{"deleted_at": nil}).ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, err
+	}
+	var total int
+	err = p.db.GetContext(ctx, &total, qry, args...)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return 0, err
+	}
+	return total, nil
+}
+
+// добавление score для user, начисление пишется в журнал points_log, а события в outbox в той же транзакции
+func (p *Store) AddPoints(ctx context.Context, award domain.Award, events ...domain.Event) error {
 	const op = "storage.PostgreSQL.AddScore"
-	p.log.Debug(fmt.Sprintf("%v: trying to add points (%v) to user (%v) score", op, points, id))
+	p.log.Debug(fmt.Sprintf("%v: trying to add points (%v) to user (%v) score", op, award.Points, award.UserID))
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		if err := p.addPoints(ctx, tx, award); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	p.log.Debug(fmt.Sprintf("%v: successfully added points (%v) to user (%v)", op, award.Points, award.UserID))
+	return nil
+}
+
+// addPoints - изменение users.score и запись в points_log, вызывается внутри транзакции.
+// Списание (отрицательные очки) не может увести баланс ниже нуля - тогда ErrInsufficientPoints
+func (p *Store) addPoints(ctx context.Context, ex sqlx.ExtContext, award domain.Award) error {
 	query := p.sq.Update("users").
-		Set("score", sq.Expr("score + ?", points)).
-		Where(sq.Eq{"id": id})
-	qry, args, err := query.ToSql()
+		Set("score", sq.Expr("score + ?", award.Points))
+	if !award.BalanceOnly {
+		query = query.
+			Set("season_score", sq.Expr("season_score + ?", award.Points)).
+			Set("xp", sq.Expr("xp + GREATEST(?::int, 0)", award.Points)) //опыт только растёт, списания его не уменьшают
+	}
+	where := sq.And{sq.Eq{"id": award.UserID}}
+	if award.Points < 0 {
+		where = append(where, sq.Expr("score + ? >= 0", award.Points))
+	}
+	//удалённому аккаунту очки не начисляются, списания (например, сгорание) проходят
+	if award.Points > 0 {
+		where = append(where, sq.Expr("deleted_at IS NULL"))
+	}
+	qry, args, err := query.Where(where).ToSql()
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
-	res, err := p.db.ExecContext(ctx, qry, args...)
+	res, err := ex.ExecContext(ctx, qry, args...)
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
+	if rowsAffected == 0 && award.Points < 0 {
+		var exists bool
+		if err := sqlx.GetContext(ctx, ex, &exists, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", award.UserID); err != nil {
+			return err
+		}
+		if exists {
+			return domain.ErrInsufficientPoints
+		}
+	}
 	if rowsAffected == 0 {
-		p.log.Error(op, "no rows affected")
-		return errNoRowsAffected
+		return domain.ErrUserNotFound
 	}
-	p.log.Debug(fmt.Sprintf("%v: successfully added points (%v) to user (%v)", op, points, id))
-	return nil
-}
-
-func (p *Store) SetInvitedBy(ctx context.Context, userID, invitedByID domain.UserID) error {
-	const op = "storage.PostgreSQL.SetInvitedBy"
-	p.log.Debug(fmt.Sprintf("%v: trying to set invited_by for user %v to %v", op, userID, invitedByID))
-	_, err := p.GetUser(ctx, invitedByID) //todo: костыль для проверки существования пользователя, вообще эту функцию нужно пересобрать в транзакцию
+	multiplier := award.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	qry, args, err = p.sq.Insert("points_log").
+		Columns("user_id", "points", "reason", "multiplier", "balance_only", "created_at").
+		Values(award.UserID, award.Points, award.Reason, multiplier, award.BalanceOnly, award.At).
+		ToSql()
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
-	query := p.sq.Update("users").
-		Set("invited_by", invitedByID).
-		Where(sq.And{
-			sq.Eq{"id": userID},
-			sq.Expr("invited_by IS NULL"), // Условие установки: если строка приглашения пустая, тогда можно писать
-		})
-	qry, args, err := query.ToSql()
-	if err != nil {
-		p.log.Error(op, err)
+	if _, err = ex.ExecContext(ctx, qry, args...); err != nil {
 		return err
 	}
-	res, err := p.db.ExecContext(ctx, qry, args...)
+	//заработанные очки идут во вклад участника команды
+	if award.Points > 0 && !award.BalanceOnly {
+		_, err = ex.ExecContext(ctx, "UPDATE team_members SET contribution = contribution + $2 WHERE user_id = $1", award.UserID, award.Points)
+		if err != nil {
+			return err
+		}
+	}
+	//начисление - новая партия для сгорания (возврат - партии с датами списанных), списание расходует партии от старых к новым
+	switch {
+	case award.Points > 0 && award.Restores != nil:
+		err = p.restoreBatches(ctx, ex, award)
+	case award.Points > 0:
+		_, err = ex.ExecContext(ctx, "INSERT INTO point_batches (user_id, points, remaining, earned_at) VALUES ($1, $2, $2, $3)",
+			award.UserID, award.Points, award.At)
+	case award.Points < 0:
+		err = p.consumeBatches(ctx, ex, award)
+	}
+	return err
+}
+
+// consumeBatches уменьшает остаток партий пользователя от старых к новым на списание award и запоминает, из каких
+// партий оно списано. Отдельная блокировка партий не нужна: строка пользователя уже заблокирована обновлением users
+// в той же транзакции
+func (p *Store) consumeBatches(ctx context.Context, ex sqlx.ExtContext, award domain.Award) error {
+	var batches []domain.PointBatch
+	err := sqlx.SelectContext(ctx, ex, &batches, `SELECT id, points, remaining, earned_at FROM point_batches
+WHERE user_id = $1 AND remaining > 0 ORDER BY earned_at, id`, award.UserID)
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
-	// Проверка на то что строка была обновлена:
-	rowsAffected, err := res.RowsAffected()
+	for _, debit := range domain.ConsumeBatches(batches, -award.Points) {
+		_, err = ex.ExecContext(ctx, "UPDATE point_batches SET remaining = remaining - $2 WHERE id = $1", debit.BatchID, debit.Points)
+		if err != nil {
+			return err
+		}
+		_, err = ex.ExecContext(ctx, `INSERT INTO batch_debits (user_id, reason, batch_id, points, earned_at)
+VALUES ($1, $2, $3, $4, $5)`, award.UserID, award.Reason, debit.BatchID, debit.Points, debit.EarnedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreBatches - начисление, возвращающее списание award.Restores, получает даты его партий. Запись о списании
+// удаляется, чтобы те же даты не вернулись второй раз
+func (p *Store) restoreBatches(ctx context.Context, ex sqlx.ExtContext, award domain.Award) error {
+	var debits []domain.BatchDebit
+	err := sqlx.SelectContext(ctx, ex, &debits, `DELETE FROM batch_debits WHERE user_id = $1 AND reason = $2
+RETURNING batch_id, points, earned_at`, award.Restores.UserID, award.Restores.Reason)
 	if err != nil {
-		p.log.Error(op, err)
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrUserAlreadyInvited //Если cтрока не была изменена, значит поле invited_by уже было заполнено
+	//RETURNING не гарантирует порядок, списание шло от старых партий к новым
+	slices.SortFunc(debits, func(a, b domain.BatchDebit) int {
+		return cmp.Or(a.EarnedAt.Compare(b.EarnedAt), cmp.Compare(a.BatchID, b.BatchID))
+	})
+	for _, b := range domain.RestoreBatches(debits, award.Points, award.At) {
+		_, err = ex.ExecContext(ctx, "INSERT INTO point_batches (user_id, points, remaining, earned_at) VALUES ($1, $2, $2, $3)",
+			award.UserID, b.Points, b.EarnedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// запись пригласившего и начисление наград за реферала одной транзакцией
+func (p *Store) ApplyReferral(ctx context.Context, userID, invitedByID domain.UserID, awards []domain.Award, events ...domain.Event) error {
+	const op = "storage.PostgreSQL.ApplyReferral"
+	p.log.Debug(fmt.Sprintf("%v: trying to set invited_by for user %v to %v", op, userID, invitedByID))
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		//проверка существования пригласившего (удалённый не считается), FOR SHARE чтобы его не удалили до конца транзакции
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL FOR SHARE)`, invitedByID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return domain.ErrUserNotFound
+		}
+		qry, args, err := p.sq.Update("users").
+			Set("invited_by", invitedByID).
+			Where(sq.And{
+				sq.Eq{"id": userID},
+				sq.Expr("invited_by IS NULL"), // Условие установки: если строка приглашения пустая, тогда можно писать
+			}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, qry, args...)
+		if err != nil {
+			return err
+		}
+		// Проверка на то что строка была обновлена:
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return domain.ErrUserAlreadyInvited //Если cтрока не была изменена, значит поле invited_by уже было заполнено
+		}
+		for _, award := range awards {
+			if err := p.addPoints(ctx, tx, award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
 	}
 	p.log.Debug(fmt.Sprintf("%v: successfully set invited_by for user %v to %v", op, userID, invitedByID))
 	return nil
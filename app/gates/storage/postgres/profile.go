@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+func (p *Store) UpdateProfile(ctx context.Context, user domain.User, events ...domain.Event) (domain.User, error) {
+	const op = "storage.PostgreSQL.UpdateProfile"
+	var updated domain.User
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		qry, args, err := p.sq.Update("users").
+			Set("nickname", user.Nickname).
+			Set("timezone", user.Timezone).
+			Where(sq.Eq{"id": user.ID, "deleted_at": nil}).
+			Suffix("RETURNING " + strings.Join(userColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &updated, qry, args...)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		err = mapUniqueViolation(err)
+		if !errors.Is(err, domain.ErrUserNotFound) && !errors.Is(err, domain.ErrNicknameTaken) {
+			p.log.Error(op, "error", err)
+		}
+		return domain.User{}, err
+	}
+	return updated, nil
+}
+
+func (p *Store) EmailTaken(ctx context.Context, email domain.Email, except domain.UserID) (bool, error) {
+	const op = "storage.PostgreSQL.EmailTaken"
+	var taken bool
+	err := p.db.GetContext(ctx, &taken, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)", email, except)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return false, err
+	}
+	return taken, nil
+}
+
+func (p *Store) AddEmailToken(ctx context.Context, token domain.EmailToken) error {
+	const op = "storage.PostgreSQL.AddEmailToken"
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM email_tokens WHERE user_id = $1 AND used_at IS NULL", token.UserID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO email_tokens (token_hash, user_id, email, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5)`, token.Hash, token.UserID, token.Email, token.ExpiresAt, token.CreatedAt)
+		return err
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	return nil
+}
+
+// UseEmailToken - токен блокируется FOR UPDATE, поэтому повторный переход по ссылке его уже не найдёт.
+// Email из токена ставится пользователю и сразу считается подтверждённым
+func (p *Store) UseEmailToken(ctx context.Context, hash string, at time.Time, use func(user domain.User) []domain.Event) (domain.User, error) {
+	const op = "storage.PostgreSQL.UseEmailToken"
+	var user domain.User
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var token domain.EmailToken
+		err := tx.GetContext(ctx, &token, `SELECT token_hash, user_id, email, expires_at, used_at, created_at FROM email_tokens
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 FOR UPDATE`, hash, at)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrInvalidEmailToken
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE email_tokens SET used_at = $2 WHERE token_hash = $1", hash, at); err != nil {
+			return err
+		}
+		qry, args, err := p.sq.Update("users").
+			Set("email", token.Email).
+			Set("email_verified_at", at).
+			Where(sq.Eq{"id": token.UserID, "deleted_at": nil}).
+			Suffix("RETURNING " + strings.Join(userColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &user, qry, args...)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrInvalidEmailToken
+		}
+		if err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, use(user))
+	})
+	if err != nil {
+		err = mapUniqueViolation(err)
+		if !errors.Is(err, domain.ErrInvalidEmailToken) && !errors.Is(err, domain.ErrEmailTaken) {
+			p.log.Error(op, "error", err)
+		}
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+// DeleteUser - строка пользователя остаётся (на неё ссылаются журнал начислений, переводы и invited_by),
+// а никнейм и email заменяются на служебные, чтобы освободить их и не хранить персональные данные
+func (p *Store) DeleteUser(ctx context.Context, id domain.UserID, at time.Time, events ...domain.Event) ([]string, error) {
+	const op = "storage.PostgreSQL.DeleteUser"
+	var keys []string
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE users SET nickname = $2 || id, email = $2 || id || '@deleted.invalid',
+	timezone = NULL, email_verified_at = NULL, deleted_at = $3
+WHERE id = $1 AND deleted_at IS NULL`, id, domain.DeletedNicknamePrefix, at)
+		if err != nil {
+			return err
+		}
+		if deleted, err := res.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 {
+			return domain.ErrUserNotFound
+		}
+		var team domain.TeamID
+		err = tx.GetContext(ctx, &team, "SELECT team_id FROM team_members WHERE user_id = $1", id)
+		if err == nil {
+			err = leaveTeam(ctx, tx, team, id)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM team_invites WHERE user_id = $1", id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM email_tokens WHERE user_id = $1", id); err != nil {
+			return err
+		}
+		//непроверенные заявки уже не одобрить: начислять некому
+		_, err = tx.ExecContext(ctx, `UPDATE submissions SET status = $2, comment = $3, reviewed_at = $4
+WHERE user_id = $1 AND status = $5`, id, domain.SubmissionRejected, "account deleted", at, domain.SubmissionPending)
+		if err != nil {
+			return err
+		}
+		err = tx.SelectContext(ctx, &keys, "SELECT proof_key FROM submissions WHERE user_id = $1 AND proof_key IS NOT NULL", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE submissions SET proof_url = NULL, proof_key = NULL, proof_content_type = NULL
+WHERE user_id = $1 AND (proof_url IS NOT NULL OR proof_key IS NOT NULL)`, id)
+		if err != nil {
+			return err
+		}
+		//аккаунты во внешних сервисах освобождаются для других пользователей
+		if _, err := tx.ExecContext(ctx, "DELETE FROM verified_tasks WHERE user_id = $1", id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM external_accounts WHERE user_id = $1", id); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		p.log.Error(op, "error", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (p *Store) GetQuestProgress(ctx context.Context, id domain.UserID, periods map[string]time.Time) (map[string]domain.QuestProgress, error) {
+	const op = "storage.PostgreSQL.GetQuestProgress"
+	progress := make(map[string]domain.QuestProgress, len(periods))
+	if len(periods) == 0 {
+		return progress, nil
+	}
+	qry := p.sq.Select("quest", "period", "step", "done", "completed_at").From("user_quests").Where(sq.Eq{"user_id": id})
+	or := sq.Or{}
+	for quest, period := range periods {
+		or = append(or, sq.Eq{"quest": quest, "period": period})
+	}
+	sqlStr, args, err := qry.Where(or).ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	rows := []domain.QuestProgress{}
+	if err := p.db.SelectContext(ctx, &rows, sqlStr, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	for _, row := range rows {
+		progress[row.Quest] = row
+	}
+	return progress, nil
+}
+
+// AdvanceQuest - шаг засчитывается условным upsert-ом по биту шага, поэтому повторный или параллельный вызов
+// не засчитает его дважды. Строка квеста блокируется upsert-ом, так что на последний шаг выходит ровно один вызов
+func (p *Store) AdvanceQuest(ctx context.Context, id domain.UserID, quest string, period time.Time, step int, total int, at time.Time, complete func() (*domain.Award, []domain.Event)) (bool, error) {
+	const op = "storage.PostgreSQL.AdvanceQuest"
+	advanced := false
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var done int
+		err := tx.GetContext(ctx, &done, `INSERT INTO user_quests (user_id, quest, period, step, done)
+VALUES ($1, $2, $3, 1, $4) ON CONFLICT (user_id, quest, period) DO UPDATE
+SET step = user_quests.step + 1, done = user_quests.done | EXCLUDED.done
+WHERE user_quests.done & EXCLUDED.done = 0
+RETURNING step`, id, quest, period, int64(1)<<step)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		advanced = true
+		if done < total {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE user_quests SET completed_at = $4 WHERE user_id = $1 AND quest = $2 AND period = $3",
+			id, quest, period, at)
+		if err != nil {
+			return err
+		}
+		award, events := complete()
+		if award != nil {
+			if err := p.addPoints(ctx, tx, *award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return false, err
+	}
+	return advanced, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+var seasonColumns = []string{"id", "name", "starts_at", "ends_at", "closed_at"}
+
+// создание сезона, если сезон с таким началом уже создан (другой репликой) - ничего не делаем
+func (p *Store) CreateSeason(ctx context.Context, season domain.Season) error {
+	const op = "storage.PostgreSQL.CreateSeason"
+	qry, args, err := p.sq.Insert("seasons").
+		Columns("name", "starts_at", "ends_at").
+		Values(season.Name, season.StartsAt, season.EndsAt).
+		Suffix("ON CONFLICT (starts_at) DO NOTHING").
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (p *Store) GetSeason(ctx context.Context, id domain.SeasonID) (domain.Season, error) {
+	const op = "storage.PostgreSQL.GetSeason"
+	var season domain.Season
+	qry, args, err := p.sq.Select(seasonColumns...).From("seasons").Where(sq.Eq{"id": id}).ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return season, err
+	}
+	err = p.db.GetContext(ctx, &season, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return season, domain.ErrSeasonNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return season, err
+	}
+	return season, nil
+}
+
+func (p *Store) ListSeasons(ctx context.Context) ([]domain.Season, error) {
+	const op = "storage.PostgreSQL.ListSeasons"
+	var seasons []domain.Season
+	qry, args, err := p.sq.Select(seasonColumns...).From("seasons").OrderBy("starts_at DESC").ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	err = p.db.SelectContext(ctx, &seasons, qry, args...)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (p *Store) DueSeasons(ctx context.Context, now time.Time) ([]domain.Season, error) {
+	const op = "storage.PostgreSQL.DueSeasons"
+	var seasons []domain.Season
+	qry, args, err := p.sq.Select(seasonColumns...).From("seasons").
+		Where(sq.And{sq.LtOrEq{"ends_at": now}, sq.Eq{"closed_at": nil}}).
+		OrderBy("ends_at ASC").
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	err = p.db.SelectContext(ctx, &seasons, qry, args...)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return seasons, nil
+}
+
+// закрытие сезона: строка сезона блокируется FOR UPDATE SKIP LOCKED, поэтому если её уже закрывает
+// другая реплика, то мы её просто пропускаем, а после коммита closed_at уже заполнен.
+// Итоги считаются по журналу начислений за [starts_at, ends_at), поэтому очки, заработанные после ends_at
+// до проверки планировщика, и одновременно закрываемые сезоны не смешиваются
+func (p *Store) CloseSeason(ctx context.Context, id domain.SeasonID, closedAt time.Time, ranking string, prizes []int) (bool, error) {
+	const op = "storage.PostgreSQL.CloseSeason"
+	closed := false
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var season domain.Season
+		err := tx.GetContext(ctx, &season,
+			`SELECT id, name, starts_at, ends_at, closed_at FROM seasons WHERE id = $1 AND closed_at IS NULL FOR UPDATE SKIP LOCKED`, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		//замораживаем итоговую таблицу, приз за место берётся из массива призов (за пределами массива - 0)
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO season_standings (season_id, user_id, rank, score, prize)
+SELECT $1, user_id, rank, score, COALESCE(($2::int[])[rank], 0)
+FROM (
+	SELECT user_id, score, %s AS rank
+	FROM (
+		SELECT pl.user_id, SUM(pl.points) AS score
+		FROM points_log pl JOIN users u ON u.id = pl.user_id AND u.deleted_at IS NULL
+		WHERE NOT pl.balance_only AND pl.created_at >= $3 AND pl.created_at < $4
+		GROUP BY pl.user_id
+	) earned
+	WHERE score <> 0
+) ranked`, rankExprBy(ranking, "score")),
+			id, pq.Array(prizes), season.StartsAt, season.EndsAt)
+		if err != nil {
+			return err
+		}
+		//в очках текущего сезона остаётся только заработанное после конца закрытого сезона
+		_, err = tx.ExecContext(ctx, `UPDATE users u SET season_score = COALESCE(after.points, 0)
+FROM users cur LEFT JOIN (
+	SELECT user_id, SUM(points) AS points FROM points_log
+	WHERE NOT balance_only AND created_at >= $1
+	GROUP BY user_id
+) after ON after.user_id = cur.id
+WHERE u.id = cur.id AND u.season_score <> COALESCE(after.points, 0)`, season.EndsAt)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE seasons SET closed_at = $2 WHERE id = $1`, id, closedAt); err != nil {
+			return err
+		}
+		closed = true
+		return nil
+	})
+	if err != nil {
+		p.log.Error(op, "season_id", id, "error", err)
+		return false, err
+	}
+	return closed, nil
+}
+
+func (p *Store) GetStandings(ctx context.Context, id domain.SeasonID, page int, size int) ([]domain.SeasonStanding, error) {
+	const op = "storage.PostgreSQL.GetStandings"
+	var standings []domain.SeasonStanding
+	qry, args, err := p.sq.Select("ss.season_id", "ss.user_id", "u.nickname", "ss.rank", "ss.score", "ss.prize").
+		From("season_standings ss").
+		Join("users u ON u.id = ss.user_id").
+		Where(sq.Eq{"ss.season_id": id}).
+		OrderBy("ss.rank ASC", "ss.user_id ASC").
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	err = p.db.SelectContext(ctx, &standings, qry, args...)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return standings, nil
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+var shopItemColumns = []string{"id", "name", "description", "cost", "stock", "per_user_limit", "starts_at", "ends_at",
+	"active", "created_at"}
+var orderColumns = []string{"id", "user_id", "item_id", "quantity", "cost", "status", "comment", "created_at", "closed_at"}
+
+func (p *Store) AddShopItem(ctx context.Context, item domain.ShopItem) (domain.ShopItem, error) {
+	const op = "storage.PostgreSQL.AddShopItem"
+	var created domain.ShopItem
+	qry, args, err := p.sq.Insert("shop_items").
+		Columns("name", "description", "cost", "stock", "per_user_limit", "starts_at", "ends_at", "active", "created_at").
+		Values(item.Name, item.Description, item.Cost, item.Stock, item.PerUserLimit, item.StartsAt, item.EndsAt, item.Active, item.CreatedAt).
+		Suffix("RETURNING " + strings.Join(shopItemColumns, ", ")).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return created, err
+	}
+	if err = p.db.GetContext(ctx, &created, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return created, err
+	}
+	return created, nil
+}
+
+func (p *Store) UpdateShopItem(ctx context.Context, item domain.ShopItem) (domain.ShopItem, error) {
+	const op = "storage.PostgreSQL.UpdateShopItem"
+	var updated domain.ShopItem
+	qry, args, err := p.sq.Update("shop_items").
+		Set("name", item.Name).
+		Set("description", item.Description).
+		Set("cost", item.Cost).
+		Set("stock", item.Stock).
+		Set("per_user_limit", item.PerUserLimit).
+		Set("starts_at", item.StartsAt).
+		Set("ends_at", item.EndsAt).
+		Set("active", item.Active).
+		Where(sq.Eq{"id": item.ID}).
+		Suffix("RETURNING " + strings.Join(shopItemColumns, ", ")).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return updated, err
+	}
+	err = p.db.GetContext(ctx, &updated, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, domain.ErrShopItemNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return updated, err
+	}
+	return updated, nil
+}
+
+func (p *Store) ListShopItems(ctx context.Context, available bool, at time.Time) ([]domain.ShopItem, error) {
+	const op = "storage.PostgreSQL.ListShopItems"
+	query := p.sq.Select(shopItemColumns...).From("shop_items")
+	if available {
+		query = query.Where(sq.And{
+			sq.Eq{"active": true},
+			sq.Or{sq.Eq{"starts_at": nil}, sq.LtOrEq{"starts_at": at}},
+			sq.Or{sq.Eq{"ends_at": nil}, sq.Gt{"ends_at": at}},
+		})
+	}
+	qry, args, err := query.OrderBy("id").ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	items := []domain.ShopItem{}
+	if err = p.db.SelectContext(ctx, &items, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// PlaceOrder - строка товара блокируется FOR UPDATE, поэтому параллельные заказы одного товара
+// проверяют остаток и лимит на пользователя по очереди, а условное списание не даёт уйти в минус
+func (p *Store) PlaceOrder(ctx context.Context, order domain.Order, place func(item domain.ShopItem, bought int, order domain.Order) (domain.Award, []domain.Event, error)) (domain.Order, error) {
+	const op = "storage.PostgreSQL.PlaceOrder"
+	var created domain.Order
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var item domain.ShopItem
+		err := tx.GetContext(ctx, &item, "SELECT "+strings.Join(shopItemColumns, ", ")+" FROM shop_items WHERE id = $1 FOR UPDATE", order.ItemID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrShopItemNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var bought int
+		err = tx.GetContext(ctx, &bought, `SELECT COALESCE(SUM(quantity), 0) FROM shop_orders
+WHERE user_id = $1 AND item_id = $2 AND status <> $3`, order.UserID, order.ItemID, domain.OrderCancelled)
+		if err != nil {
+			return err
+		}
+		qry, args, err := p.sq.Insert("shop_orders").
+			Columns("user_id", "item_id", "quantity", "cost", "status", "created_at").
+			Values(order.UserID, order.ItemID, order.Quantity, item.Cost*order.Quantity, order.Status, order.CreatedAt).
+			Suffix("RETURNING " + strings.Join(orderColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if err := tx.GetContext(ctx, &created, qry, args...); err != nil {
+			return err
+		}
+		award, events, err := place(item, bought, created)
+		if err != nil {
+			return err
+		}
+		if item.Stock != nil {
+			if *item.Stock < order.Quantity {
+				return domain.ErrOutOfStock
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE shop_items SET stock = stock - $2 WHERE id = $1", item.ID, order.Quantity); err != nil {
+				return err
+			}
+		}
+		if err := p.addPoints(ctx, tx, award); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	switch {
+	case errors.Is(err, domain.ErrShopItemNotFound), errors.Is(err, domain.ErrShopItemUnavailable),
+		errors.Is(err, domain.ErrPurchaseLimit), errors.Is(err, domain.ErrOutOfStock),
+		errors.Is(err, domain.ErrInsufficientPoints), errors.Is(err, domain.ErrUserNotFound):
+		return domain.Order{}, err
+	case err != nil:
+		p.log.Error(op, "error", err)
+		return domain.Order{}, err
+	}
+	return created, nil
+}
+
+func (p *Store) ListOrders(ctx context.Context, filter domain.OrderFilter, page int, size int) ([]domain.Order, error) {
+	const op = "storage.PostgreSQL.ListOrders"
+	where := sq.And{}
+	if filter.UserID != nil {
+		where = append(where, sq.Eq{"user_id": *filter.UserID})
+	}
+	if filter.Status != "" {
+		where = append(where, sq.Eq{"status": filter.Status})
+	}
+	query := p.sq.Select(orderColumns...).From("shop_orders")
+	if len(where) > 0 {
+		query = query.Where(where)
+	}
+	//невыданные заказы показываем администраторам от старых к новым (очередь), остальные - от новых к старым
+	order := "id DESC"
+	if filter.Status == domain.OrderPending {
+		order = "id"
+	}
+	qry, args, err := query.OrderBy(order).
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	orders := []domain.Order{}
+	if err = p.db.SelectContext(ctx, &orders, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return orders, nil
+}
+
+// CloseOrder - смена статуса, возврат остатка, очков и события одной транзакцией, повторное закрытие ничего не вернёт
+func (p *Store) CloseOrder(ctx context.Context, id domain.OrderID, status string, comment *string, at time.Time, close func(order domain.Order) (*domain.Award, []domain.Event)) (domain.Order, error) {
+	const op = "storage.PostgreSQL.CloseOrder"
+	var order domain.Order
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		qry, args, err := p.sq.Update("shop_orders").
+			Set("status", status).
+			Set("comment", comment).
+			Set("closed_at", at).
+			Where(sq.Eq{"id": id, "status": domain.OrderPending}).
+			Suffix("RETURNING " + strings.Join(orderColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &order, qry, args...)
+		if errors.Is(err, sql.ErrNoRows) {
+			//заказа нет или он уже закрыт
+			var exists bool
+			if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM shop_orders WHERE id = $1)", id); err != nil {
+				return err
+			}
+			if !exists {
+				return domain.ErrOrderNotFound
+			}
+			return domain.ErrOrderClosed
+		}
+		if err != nil {
+			return err
+		}
+		if status == domain.OrderCancelled {
+			_, err := tx.ExecContext(ctx, "UPDATE shop_items SET stock = stock + $2 WHERE id = $1 AND stock IS NOT NULL", order.ItemID, order.Quantity)
+			if err != nil {
+				return err
+			}
+		}
+		award, events := close(order)
+		if award != nil {
+			if err := p.addPoints(ctx, tx, *award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil && !errors.Is(err, domain.ErrOrderNotFound) && !errors.Is(err, domain.ErrOrderClosed) {
+		p.log.Error(op, "error", err)
+	}
+	return order, err
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (p *Store) GetStreaks(ctx context.Context, id domain.UserID) ([]domain.Streak, error) {
+	const op = "storage.PostgreSQL.GetStreaks"
+	streaks := []domain.Streak{}
+	err := p.db.SelectContext(ctx, &streaks, `SELECT task, current, longest, last_day FROM user_streaks
+WHERE user_id = $1 ORDER BY task`, id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return streaks, nil
+}
+
+// CompleteStreakTask - строка серии блокируется FOR UPDATE, поэтому параллельные выполнения одного задания
+// считаются по очереди и бонус за новый день начислится один раз
+func (p *Store) CompleteStreakTask(ctx context.Context, id domain.UserID, task string, day time.Time, complete func(streak domain.Streak, newDay bool) (domain.Award, []domain.Event)) (domain.Streak, domain.Award, error) {
+	const op = "storage.PostgreSQL.CompleteStreakTask"
+	var streak domain.Streak
+	var award domain.Award
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		//строка создаётся заранее, чтобы первую серию тоже можно было заблокировать
+		_, err := tx.ExecContext(ctx, `INSERT INTO user_streaks (user_id, task) VALUES ($1, $2)
+ON CONFLICT (user_id, task) DO NOTHING`, id, task)
+		if err != nil {
+			return err
+		}
+		var current domain.Streak
+		err = tx.GetContext(ctx, &current, `SELECT task, current, longest, last_day FROM user_streaks
+WHERE user_id = $1 AND task = $2 FOR UPDATE`, id, task)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var newDay bool
+		streak, newDay = current.Advance(day)
+		if newDay {
+			_, err = tx.ExecContext(ctx, `UPDATE user_streaks SET current = $3, longest = $4, last_day = $5
+WHERE user_id = $1 AND task = $2`, id, task, streak.Current, streak.Longest, day)
+			if err != nil {
+				return err
+			}
+		}
+		var events []domain.Event
+		award, events = complete(streak, newDay)
+		if err := p.addPoints(ctx, tx, award); err != nil {
+			return err
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return streak, award, err
+	}
+	return streak, award, nil
+}
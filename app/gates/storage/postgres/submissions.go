@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var submissionColumns = []string{"id", "user_id", "task", "points", "multiplier", "proof_url", "proof_key", "proof_content_type",
+	"status", "comment", "created_at", "reviewed_at", "reviewed_by"}
+
+func (p *Store) AddSubmission(ctx context.Context, sub domain.Submission) (domain.Submission, error) {
+	const op = "storage.PostgreSQL.AddSubmission"
+	var created domain.Submission
+	qry, args, err := p.sq.Insert("submissions").
+		Columns("user_id", "task", "points", "multiplier", "proof_url", "proof_key", "proof_content_type", "status", "created_at").
+		Values(sub.UserID, sub.Task, sub.Points, sub.Multiplier, sub.ProofURL, sub.ProofKey, sub.ProofContentType, sub.Status, sub.CreatedAt).
+		Suffix("RETURNING " + strings.Join(submissionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return created, err
+	}
+	err = p.db.GetContext(ctx, &created, qry, args...)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "submissions_pending_user_task_idx" {
+		return created, domain.ErrSubmissionPending
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return created, err
+	}
+	return created, nil
+}
+
+func (p *Store) GetSubmission(ctx context.Context, id domain.SubmissionID) (domain.Submission, error) {
+	const op = "storage.PostgreSQL.GetSubmission"
+	var sub domain.Submission
+	qry, args, err := p.sq.Select(submissionColumns...).From("submissions").Where(sq.Eq{"id": id}).ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return sub, err
+	}
+	err = p.db.GetContext(ctx, &sub, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, domain.ErrSubmissionNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return sub, err
+	}
+	return sub, nil
+}
+
+func (p *Store) ListSubmissions(ctx context.Context, filter domain.SubmissionFilter, page int, size int) ([]domain.Submission, error) {
+	const op = "storage.PostgreSQL.ListSubmissions"
+	where := sq.And{}
+	if filter.UserID != nil {
+		where = append(where, sq.Eq{"user_id": *filter.UserID})
+	}
+	if filter.Status != "" {
+		where = append(where, sq.Eq{"status": filter.Status})
+	}
+	if filter.Task != "" {
+		where = append(where, sq.Eq{"task": filter.Task})
+	}
+	query := p.sq.Select(submissionColumns...).From("submissions")
+	if len(where) > 0 {
+		query = query.Where(where)
+	}
+	//непроверенные показываем модераторам от старых к новым (очередь), остальные - от новых к старым
+	order := "id DESC"
+	if filter.Status == domain.SubmissionPending {
+		order = "id"
+	}
+	qry, args, err := query.OrderBy(order).
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	subs := []domain.Submission{}
+	if err = p.db.SelectContext(ctx, &subs, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return subs, nil
+}
+
+// ReviewSubmission - смена статуса, начисление очков и события одной транзакцией, повторная проверка ничего не начислит
+func (p *Store) ReviewSubmission(ctx context.Context, id domain.SubmissionID, status string, reviewer domain.UserID, comment *string, at time.Time, award *domain.Award, events ...domain.Event) (domain.Submission, error) {
+	const op = "storage.PostgreSQL.ReviewSubmission"
+	var sub domain.Submission
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		qry, args, err := p.sq.Update("submissions").
+			Set("status", status).
+			Set("comment", comment).
+			Set("reviewed_at", at).
+			Set("reviewed_by", reviewer).
+			Where(sq.Eq{"id": id, "status": domain.SubmissionPending}).
+			Suffix("RETURNING " + strings.Join(submissionColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &sub, qry, args...)
+		if errors.Is(err, sql.ErrNoRows) {
+			//заявки нет или она уже проверена
+			var exists bool
+			if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM submissions WHERE id = $1)", id); err != nil {
+				return err
+			}
+			if !exists {
+				return domain.ErrSubmissionNotFound
+			}
+			return domain.ErrSubmissionReviewed
+		}
+		if err != nil {
+			return err
+		}
+		if award != nil {
+			if err := p.addPoints(ctx, tx, *award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	if err != nil && !errors.Is(err, domain.ErrSubmissionNotFound) && !errors.Is(err, domain.ErrSubmissionReviewed) {
+		p.log.Error(op, "error", err)
+	}
+	return sub, err
+}
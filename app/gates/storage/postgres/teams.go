@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	constraintTeamName   = "teams_name_key"
+	constraintTeamMember = "team_members_user_id_key"
+)
+
+func (p *Store) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
+	const op = "storage.PostgreSQL.CreateTeam"
+	var created domain.Team
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &created, `INSERT INTO teams (name, owner_id, max_size, created_at) VALUES ($1, $2, $3, $4)
+RETURNING id, name, owner_id, max_size, created_at`, team.Name, team.OwnerID, team.MaxSize, team.CreatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO team_members (team_id, user_id, joined_at) VALUES ($1, $2, $3)",
+			created.ID, team.OwnerID, team.CreatedAt)
+		return err
+	})
+	if err != nil {
+		err = mapTeamViolation(err)
+		if !errors.Is(err, domain.ErrTeamNameTaken) && !errors.Is(err, domain.ErrAlreadyInTeam) {
+			p.log.Error(op, "error", err)
+		}
+		return domain.Team{}, err
+	}
+	return created, nil
+}
+
+// mapTeamViolation - занятое имя команды и вторая команда пользователя из ошибок уникальности
+func mapTeamViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pqUniqueViolation {
+		return err
+	}
+	switch pqErr.Constraint {
+	case constraintTeamName:
+		return domain.ErrTeamNameTaken
+	case constraintTeamMember:
+		return domain.ErrAlreadyInTeam
+	}
+	return err
+}
+
+func (p *Store) GetTeam(ctx context.Context, id domain.TeamID) (domain.Team, error) {
+	const op = "storage.PostgreSQL.GetTeam"
+	var team domain.Team
+	err := p.db.GetContext(ctx, &team, "SELECT id, name, owner_id, max_size, created_at FROM teams WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return team, domain.ErrTeamNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return team, err
+	}
+	return team, nil
+}
+
+func (p *Store) GetTeamMembers(ctx context.Context, id domain.TeamID) ([]domain.TeamMember, error) {
+	const op = "storage.PostgreSQL.GetTeamMembers"
+	members := []domain.TeamMember{}
+	err := p.db.SelectContext(ctx, &members, `SELECT tm.user_id, u.nickname, tm.contribution, tm.joined_at
+FROM team_members tm JOIN users u ON u.id = tm.user_id
+WHERE tm.team_id = $1 ORDER BY tm.contribution DESC, tm.joined_at`, id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+func (p *Store) InviteToTeam(ctx context.Context, id domain.TeamID, userID domain.UserID, invitedBy domain.UserID, at time.Time) error {
+	const op = "storage.PostgreSQL.InviteToTeam"
+	_, err := p.db.ExecContext(ctx, `INSERT INTO team_invites (team_id, user_id, invited_by, created_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (team_id, user_id) DO NOTHING`, id, userID, invitedBy, at)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return domain.ErrUserNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	return nil
+}
+
+// JoinTeam - строка команды блокируется FOR UPDATE, поэтому параллельные вступления не превысят max_size
+func (p *Store) JoinTeam(ctx context.Context, id domain.TeamID, userID domain.UserID, at time.Time) error {
+	const op = "storage.PostgreSQL.JoinTeam"
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var maxSize int
+		err := tx.GetContext(ctx, &maxSize, "SELECT max_size FROM teams WHERE id = $1 FOR UPDATE", id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrTeamNotFound
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "DELETE FROM team_invites WHERE team_id = $1 AND user_id = $2", id, userID)
+		if err != nil {
+			return err
+		}
+		if invited, err := res.RowsAffected(); err != nil {
+			return err
+		} else if invited == 0 {
+			return domain.ErrTeamInviteRequired
+		}
+		var members int
+		if err := tx.GetContext(ctx, &members, "SELECT COUNT(*) FROM team_members WHERE team_id = $1", id); err != nil {
+			return err
+		}
+		if members >= maxSize {
+			return domain.ErrTeamFull
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO team_members (team_id, user_id, joined_at) VALUES ($1, $2, $3)", id, userID, at)
+		return err
+	})
+	if err != nil {
+		err = mapTeamViolation(err)
+		switch {
+		case errors.Is(err, domain.ErrTeamNotFound), errors.Is(err, domain.ErrTeamInviteRequired),
+			errors.Is(err, domain.ErrTeamFull), errors.Is(err, domain.ErrAlreadyInTeam):
+		default:
+			p.log.Error(op, "error", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *Store) LeaveTeam(ctx context.Context, id domain.TeamID, userID domain.UserID) error {
+	const op = "storage.PostgreSQL.LeaveTeam"
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		return leaveTeam(ctx, tx, id, userID)
+	})
+	if err != nil && !errors.Is(err, domain.ErrTeamNotFound) && !errors.Is(err, domain.ErrNotTeamMember) {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+// leaveTeam убирает участника внутри транзакции, если ушёл владелец - передаёт команду или удаляет её
+func leaveTeam(ctx context.Context, tx *sqlx.Tx, id domain.TeamID, userID domain.UserID) error {
+	var owner domain.UserID
+	err := tx.GetContext(ctx, &owner, "SELECT owner_id FROM teams WHERE id = $1 FOR UPDATE", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrTeamNotFound
+	}
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM team_members WHERE team_id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if left, err := res.RowsAffected(); err != nil {
+		return err
+	} else if left == 0 {
+		return domain.ErrNotTeamMember
+	}
+	if owner != userID {
+		return nil
+	}
+	//владельцем становится самый давний участник, без участников команда удаляется
+	var next domain.UserID
+	err = tx.GetContext(ctx, &next, "SELECT user_id FROM team_members WHERE team_id = $1 ORDER BY joined_at, user_id LIMIT 1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", id)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE teams SET owner_id = $2 WHERE id = $1", id, next)
+	return err
+}
+
+func (p *Store) TeamLeaderboard(ctx context.Context, aggregate string, ranking string, page int, size int) ([]domain.TeamEntry, error) {
+	const op = "storage.PostgreSQL.TeamLeaderboard"
+	score := "COALESCE(SUM(tm.contribution), 0)"
+	if aggregate == domain.TeamScoreAverage {
+		score = "COALESCE(ROUND(AVG(tm.contribution)), 0)"
+	}
+	qry := `SELECT id, name, members, score, ` + rankExprBy(ranking, "score") + ` AS rank FROM (
+	SELECT t.id, t.name, COUNT(tm.user_id) AS members, ` + score + `::bigint AS score
+	FROM teams t LEFT JOIN team_members tm ON tm.team_id = t.id
+	GROUP BY t.id
+) teams
+ORDER BY score DESC, id
+OFFSET $1 LIMIT $2`
+	entries := []domain.TeamEntry{}
+	if err := p.db.SelectContext(ctx, &entries, qry, (page-1)*size, size); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"database/sql"
+	"errors"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+var transferColumns = []string{"id", "from_user_id", "to_user_id", "amount", "fee", "comment", "created_at"}
+
+// Transfer - строки обоих пользователей блокируются FOR UPDATE в порядке id, поэтому встречные переводы
+// ждут друг друга, а не взаимно блокируются, и параллельные переводы одного отправителя считают лимит по очереди
+func (p *Store) Transfer(ctx context.Context, t domain.Transfer, since time.Time, transfer func(from domain.User, to domain.User, sent int, t domain.Transfer) ([]domain.Award, []domain.Event, error)) (domain.Transfer, error) {
+	const op = "storage.PostgreSQL.Transfer"
+	var created domain.Transfer
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		qry, args, err := p.sq.Select(userColumns...).
+			From("users").
+			Where(sq.Eq{"id": []domain.UserID{t.FromUserID, t.ToUserID}, "deleted_at": nil}).
+			OrderBy("id").
+			Suffix("FOR UPDATE").
+			ToSql()
+		if err != nil {
+			return err
+		}
+		var users []domain.User
+		if err := tx.SelectContext(ctx, &users, qry, args...); err != nil {
+			return err
+		}
+		if len(users) != 2 {
+			return domain.ErrUserNotFound
+		}
+		from, to := users[0], users[1]
+		if from.ID != t.FromUserID {
+			from, to = to, from
+		}
+		var sent int
+		err = tx.GetContext(ctx, &sent, "SELECT COALESCE(SUM(amount), 0) FROM transfers WHERE from_user_id = $1 AND created_at >= $2",
+			t.FromUserID, since)
+		if err != nil {
+			return err
+		}
+		qry, args, err = p.sq.Insert("transfers").
+			Columns("from_user_id", "to_user_id", "amount", "fee", "comment", "created_at").
+			Values(t.FromUserID, t.ToUserID, t.Amount, t.Fee, t.Comment, t.CreatedAt).
+			Suffix("RETURNING " + strings.Join(transferColumns, ", ")).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if err := tx.GetContext(ctx, &created, qry, args...); err != nil {
+			return err
+		}
+		awards, events, err := transfer(from, to, sent, created)
+		if err != nil {
+			return err
+		}
+		for _, award := range awards {
+			if err := p.addPoints(ctx, tx, award); err != nil {
+				return err
+			}
+		}
+		return p.insertOutbox(ctx, tx, events)
+	})
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrAccountSuspended),
+		errors.Is(err, domain.ErrAccountTooNew), errors.Is(err, domain.ErrTransferLimit),
+		errors.Is(err, domain.ErrInsufficientPoints):
+		return domain.Transfer{}, err
+	case err != nil:
+		p.log.Error(op, "error", err)
+		return domain.Transfer{}, err
+	}
+	return created, nil
+}
+
+func (p *Store) GetTransactions(ctx context.Context, id domain.UserID, page int, size int) ([]domain.Transaction, error) {
+	const op = "storage.PostgreSQL.GetTransactions"
+	qry, args, err := p.sq.Select("points", "reason", "multiplier", "created_at").
+		From("points_log").
+		Where(sq.Eq{"user_id": id}).
+		OrderBy("created_at DESC", "id DESC").
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	transactions := []domain.Transaction{}
+	if err = p.db.SelectContext(ctx, &transactions, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// SuspendUser - повторная блокировка сохраняет время первой
+func (p *Store) SuspendUser(ctx context.Context, id domain.UserID, at *time.Time) (domain.User, error) {
+	const op = "storage.PostgreSQL.SuspendUser"
+	var user domain.User
+	suspendedAt := sq.Expr("COALESCE(suspended_at, ?)", at)
+	if at == nil {
+		suspendedAt = sq.Expr("NULL")
+	}
+	qry, args, err := p.sq.Update("users").
+		Set("suspended_at", suspendedAt).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING " + strings.Join(userColumns, ", ")).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return user, err
+	}
+	err = p.db.GetContext(ctx, &user, qry, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, domain.ErrUserNotFound
+	}
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return user, err
+	}
+	return user, nil
+}
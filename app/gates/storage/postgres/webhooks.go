@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"app/domain"
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// webhook - строка webhook_subscriptions, массив событий сканируется через pq.StringArray
+type webhook struct {
+	ID        domain.WebhookID `db:"id"`
+	URL       string           `db:"url"`
+	Secret    string           `db:"secret"`
+	Events    pq.StringArray   `db:"events"`
+	Active    bool             `db:"active"`
+	CreatedAt time.Time        `db:"created_at"`
+}
+
+func (w webhook) toDomain() domain.WebhookSubscription {
+	return domain.WebhookSubscription{
+		ID:        w.ID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    w.Events,
+		Active:    w.Active,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+var webhookColumns = "id, url, secret, events, active, created_at"
+
+var deliveryColumns = []string{"id", "subscription_id", "event_id", "event_type", "payload", "status", "attempts",
+	"next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at"}
+
+func (p *Store) AddWebhook(ctx context.Context, sub domain.WebhookSubscription) (domain.WebhookSubscription, error) {
+	const op = "storage.PostgreSQL.AddWebhook"
+	qry, args, err := p.sq.Insert("webhook_subscriptions").
+		Columns("url", "secret", "events", "active").
+		Values(sub.URL, sub.Secret, pq.Array(sub.Events), sub.Active).
+		Suffix("RETURNING " + webhookColumns).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return sub, err
+	}
+	var created webhook
+	if err = p.db.GetContext(ctx, &created, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return sub, err
+	}
+	return created.toDomain(), nil
+}
+
+func (p *Store) ListWebhooks(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	const op = "storage.PostgreSQL.ListWebhooks"
+	var rows []webhook
+	if err := p.db.SelectContext(ctx, &rows, "SELECT "+webhookColumns+" FROM webhook_subscriptions ORDER BY id"); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	subs := make([]domain.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, row.toDomain())
+	}
+	return subs, nil
+}
+
+func (p *Store) DeleteWebhook(ctx context.Context, id domain.WebhookID) error {
+	const op = "storage.PostgreSQL.DeleteWebhook"
+	res, err := p.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (p *Store) EnqueueDeliveries(ctx context.Context, event domain.Event, payload []byte) error {
+	const op = "storage.PostgreSQL.EnqueueDeliveries"
+	_, err := p.db.ExecContext(ctx, `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at)
+SELECT id, $1, $2, $3, $4 FROM webhook_subscriptions
+WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))
+ON CONFLICT (subscription_id, event_id) DO NOTHING`, event.ID, event.Type, payload, event.OccurredAt)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return err
+	}
+	return nil
+}
+
+// захват доставок: строки блокируются FOR UPDATE SKIP LOCKED и сразу откладываются на lease,
+// так что параллельный воркер их не увидит, а если воркер упадёт - доставка вернётся в очередь после lease
+func (p *Store) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	const op = "storage.PostgreSQL.ClaimDeliveries"
+	var deliveries []domain.WebhookDelivery
+	qry := fmt.Sprintf(`WITH due AS (
+	SELECT id FROM webhook_deliveries
+	WHERE status = 'pending' AND next_attempt_at <= $1
+	ORDER BY next_attempt_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries d SET next_attempt_at = $2
+FROM due, webhook_subscriptions s
+WHERE d.id = due.id AND s.id = d.subscription_id
+RETURNING d.%s, s.url, s.secret`, strings.Join(deliveryColumns, ", d."))
+	err := p.db.SelectContext(ctx, &deliveries, qry, now, now.Add(lease), limit)
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (p *Store) MarkDelivered(ctx context.Context, id domain.DeliveryID, statusCode int, at time.Time) error {
+	const op = "storage.PostgreSQL.MarkDelivered"
+	_, err := p.db.ExecContext(ctx, `UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = $3
+WHERE id = $1`, id, statusCode, at)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) ScheduleRetry(ctx context.Context, id domain.DeliveryID, statusCode int, lastErr string, next time.Time) error {
+	const op = "storage.PostgreSQL.ScheduleRetry"
+	_, err := p.db.ExecContext(ctx, `UPDATE webhook_deliveries
+SET attempts = attempts + 1, last_status_code = NULLIF($2, 0), last_error = $3, next_attempt_at = $4
+WHERE id = $1`, id, statusCode, lastErr, next)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) MarkDead(ctx context.Context, id domain.DeliveryID, statusCode int, lastErr string, at time.Time) error {
+	const op = "storage.PostgreSQL.MarkDead"
+	_, err := p.db.ExecContext(ctx, `WITH dead AS (
+	UPDATE webhook_deliveries
+	SET status = 'dead', attempts = attempts + 1, last_status_code = NULLIF($2, 0), last_error = $3
+	WHERE id = $1
+	RETURNING id, subscription_id, event_type, payload
+)
+INSERT INTO webhook_dead_letters (delivery_id, subscription_id, event_type, payload, last_error, failed_at)
+SELECT id, subscription_id, event_type, payload, $3, $4 FROM dead
+ON CONFLICT (delivery_id) DO NOTHING`, id, statusCode, lastErr, at)
+	if err != nil {
+		p.log.Error(op, "error", err)
+	}
+	return err
+}
+
+func (p *Store) GetDeliveries(ctx context.Context, id domain.WebhookID, status string, page int, size int) ([]domain.WebhookDelivery, error) {
+	const op = "storage.PostgreSQL.GetDeliveries"
+	var deliveries []domain.WebhookDelivery
+	where := sq.And{sq.Eq{"subscription_id": id}}
+	if status != "" {
+		where = append(where, sq.Eq{"status": status})
+	}
+	qry, args, err := p.sq.Select(deliveryColumns...).From("webhook_deliveries").
+		Where(where).
+		OrderBy("id DESC").
+		Offset(uint64((page - 1) * size)).
+		Limit(uint64(size)).
+		ToSql()
+	if err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	if err = p.db.SelectContext(ctx, &deliveries, qry, args...); err != nil {
+		p.log.Error(op, "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
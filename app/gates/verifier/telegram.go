@@ -0,0 +1,79 @@
+package verifier
+
+import (
+	"app/domain"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Telegram - проверка подписки на канал через Bot API getChatMember, бот должен быть админом канала.
+// Account - числовой id пользователя в Telegram
+type Telegram struct {
+	client pkg.HTTPDoer
+	apiURL string
+	token  string
+	chat   string // @username или id канала
+}
+
+func NewTelegram(client pkg.HTTPDoer, apiURL string, token string, chat string) *Telegram {
+	return &Telegram{
+		client: client,
+		apiURL: apiURL,
+		token:  token,
+		chat:   chat,
+	}
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      struct {
+		Status   string `json:"status"`
+		IsMember bool   `json:"is_member"` // только для status = restricted
+	} `json:"result"`
+}
+
+func (t *Telegram) Service() string {
+	return domain.VerifierTelegram
+}
+
+// NormalizeAccount - id пользователя в Telegram без пробелов и ведущих нулей
+func (t *Telegram) NormalizeAccount(account string) (string, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(account), 10, 64)
+	if err != nil || id <= 0 {
+		return "", fmt.Errorf("%w: account must be a numeric telegram user id", domain.ErrInvalidTaskClaim)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (t *Telegram) Verify(ctx context.Context, claim domain.TaskClaim) error {
+	account, err := t.NormalizeAccount(claim.Account)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("chat_id", t.chat)
+	q.Set("user_id", account)
+	var resp telegramResponse
+	_, err = getJSON(ctx, t.client, t.apiURL+"/bot"+t.token+"/getChatMember?"+q.Encode(), nil, &resp)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		//Bot API отвечает ok=false, если пользователь никогда не заходил в чат
+		return fmt.Errorf("%w: %s", domain.ErrTaskNotVerified, resp.Description)
+	}
+	switch resp.Result.Status {
+	case "creator", "administrator", "member":
+		return nil
+	case "restricted":
+		if resp.Result.IsMember {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: user is not subscribed to %s", domain.ErrTaskNotVerified, t.chat)
+}
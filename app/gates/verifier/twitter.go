@@ -0,0 +1,76 @@
+package verifier
+
+import (
+	"app/domain"
+	"app/iternal/pkg"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Twitter - проверка подписки на аккаунт в X/Twitter через friendships/show.
+// Account - имя пользователя (@ в начале можно не указывать)
+type Twitter struct {
+	client pkg.HTTPDoer
+	apiURL string
+	token  string
+	target string // имя аккаунта, на который нужно подписаться
+}
+
+func NewTwitter(client pkg.HTTPDoer, apiURL string, token string, target string) *Twitter {
+	return &Twitter{
+		client: client,
+		apiURL: apiURL,
+		token:  token,
+		target: strings.TrimPrefix(target, "@"),
+	}
+}
+
+type twitterResponse struct {
+	Relationship struct {
+		Source struct {
+			Following bool `json:"following"`
+		} `json:"source"`
+	} `json:"relationship"`
+}
+
+func (t *Twitter) Service() string {
+	return domain.VerifierTwitter
+}
+
+// NormalizeAccount - имя пользователя без @ в нижнем регистре, имена в X/Twitter не различают регистр
+func (t *Twitter) NormalizeAccount(account string) (string, error) {
+	account = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(account), "@"))
+	if account == "" {
+		return "", fmt.Errorf("%w: account must be a twitter username", domain.ErrInvalidTaskClaim)
+	}
+	return account, nil
+}
+
+func (t *Twitter) Verify(ctx context.Context, claim domain.TaskClaim) error {
+	account, err := t.NormalizeAccount(claim.Account)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("source_screen_name", account)
+	q.Set("target_screen_name", t.target)
+	var resp twitterResponse
+	header := http.Header{"Authorization": []string{"Bearer " + t.token}}
+	code, err := getJSON(ctx, t.client, t.apiURL+"/1.1/friendships/show.json?"+q.Encode(), header, &resp)
+	if err != nil {
+		return err
+	}
+	if code == http.StatusNotFound {
+		return fmt.Errorf("%w: twitter user %s not found", domain.ErrTaskNotVerified, account)
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("%w: status code %d", domain.ErrVerificationUnavailable, code)
+	}
+	if !resp.Relationship.Source.Following {
+		return fmt.Errorf("%w: %s doesn't follow %s", domain.ErrTaskNotVerified, account, t.target)
+	}
+	return nil
+}
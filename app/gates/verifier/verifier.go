@@ -0,0 +1,70 @@
+package verifier
+
+import (
+	"app/domain"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+/*Проверка заданий через внешние API. Для каждого задания из verification.tasks создаётся
+проверяющий нужного типа, остальные задания засчитываются без проверки
+*/
+
+// FromConfig собирает проверяющих по ключу задания
+func FromConfig(cfg *config.Config, client pkg.HTTPDoer) (map[string]domain.TaskVerifier, error) {
+	verifiers := make(map[string]domain.TaskVerifier, len(cfg.Verification.Tasks))
+	for task, tc := range cfg.Verification.Tasks {
+		if _, ok := cfg.Rewards[task]; !ok {
+			return nil, fmt.Errorf("verification configured for unknown task %q", task)
+		}
+		switch tc.Verifier {
+		case domain.VerifierSelfReported:
+			verifiers[task] = domain.SelfReported{}
+		case domain.VerifierProof:
+			verifiers[task] = domain.ProofRequired{}
+		case domain.VerifierTelegram:
+			verifiers[task] = NewTelegram(client, cfg.Verification.Telegram.APIURL, cfg.Verification.Telegram.BotToken, tc.Target)
+		case domain.VerifierTwitter:
+			verifiers[task] = NewTwitter(client, cfg.Verification.Twitter.APIURL, cfg.Verification.Twitter.BearerToken, tc.Target)
+		default:
+			return nil, fmt.Errorf("unknown verifier %q for task %q", tc.Verifier, task)
+		}
+	}
+	return verifiers, nil
+}
+
+// getJSON - GET запрос с разбором JSON ответа, сетевые ошибки и 5xx - ErrVerificationUnavailable
+func getJSON(ctx context.Context, client pkg.HTTPDoer, rawURL string, header http.Header, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		//в url запроса может быть токен (Telegram Bot API), поэтому в ошибку попадает только причина без url
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("%w: %v", domain.ErrVerificationUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, fmt.Errorf("%w: status code %d", domain.ErrVerificationUnavailable, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("%w: %v", domain.ErrVerificationUnavailable, err)
+	}
+	return resp.StatusCode, nil
+}
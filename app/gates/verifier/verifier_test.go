@@ -0,0 +1,101 @@
+package verifier_test
+
+import (
+	"app/domain"
+	"app/gates/verifier"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const botToken = "123456:secret-bot-token"
+
+// fakeTelegram - Bot API getChatMember, статусы участников по user_id
+func fakeTelegram(t *testing.T, statuses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot"+botToken+"/getChatMember" || r.URL.Query().Get("chat_id") != "@channel" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		status, ok := statuses[r.URL.Query().Get("user_id")]
+		if !ok {
+			w.Write([]byte(`{"ok":false,"description":"Bad Request: user not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"status":"` + status + `"}}`))
+	}))
+}
+
+func TestTelegramVerify(t *testing.T) {
+	api := fakeTelegram(t, map[string]string{"42": "member", "43": "left"})
+	defer api.Close()
+	tg := verifier.NewTelegram(api.Client(), api.URL, botToken, "@channel")
+	ctx := context.Background()
+
+	if err := tg.Verify(ctx, domain.TaskClaim{UserID: 1, Account: " 042"}); err != nil {
+		t.Fatalf("subscribed user is not verified: %v", err)
+	}
+	for _, account := range []string{"43", "44"} {
+		if err := tg.Verify(ctx, domain.TaskClaim{UserID: 1, Account: account}); !errors.Is(err, domain.ErrTaskNotVerified) {
+			t.Fatalf("Verify(%s) = %v, want ErrTaskNotVerified", account, err)
+		}
+	}
+	if err := tg.Verify(ctx, domain.TaskClaim{UserID: 1, Account: "@durov"}); !errors.Is(err, domain.ErrInvalidTaskClaim) {
+		t.Fatalf("Verify(@durov) = %v, want ErrInvalidTaskClaim", err)
+	}
+}
+
+func TestTelegramUnavailableDoesNotLeakToken(t *testing.T) {
+	api := fakeTelegram(t, nil)
+	client := api.Client()
+	api.Close() //соединения к закрытому серверу падают с *url.Error, в котором есть url запроса
+	tg := verifier.NewTelegram(client, api.URL, botToken, "@channel")
+
+	err := tg.Verify(context.Background(), domain.TaskClaim{UserID: 1, Account: "42"})
+	if !errors.Is(err, domain.ErrVerificationUnavailable) {
+		t.Fatalf("Verify() = %v, want ErrVerificationUnavailable", err)
+	}
+	if strings.Contains(err.Error(), botToken) {
+		t.Fatalf("error leaks bot token: %v", err)
+	}
+}
+
+func TestTwitterVerify(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("target_screen_name") != "denet" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		switch r.URL.Query().Get("source_screen_name") {
+		case "follower":
+			w.Write([]byte(`{"relationship":{"source":{"following":true}}}`))
+		case "stranger":
+			w.Write([]byte(`{"relationship":{"source":{"following":false}}}`))
+		case "busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer api.Close()
+	tw := verifier.NewTwitter(api.Client(), api.URL, "token", "@denet")
+	ctx := context.Background()
+
+	tests := []struct {
+		account string
+		want    error
+	}{
+		{"@Follower", nil},
+		{"stranger", domain.ErrTaskNotVerified},
+		{"nobody", domain.ErrTaskNotVerified},
+		{"busy", domain.ErrVerificationUnavailable},
+		{" @ ", domain.ErrInvalidTaskClaim},
+	}
+	for _, tt := range tests {
+		if err := tw.Verify(ctx, domain.TaskClaim{UserID: 1, Account: tt.account}); !errors.Is(err, tt.want) {
+			t.Errorf("Verify(%q) = %v, want %v", tt.account, err, tt.want)
+		}
+	}
+}
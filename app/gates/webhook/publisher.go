@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"app/domain"
+	"app/iternal/pkg"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// HTTPPublisher - публикация доменных событий из outbox на один внутренний адрес (шина событий, другой сервис).
+// Idempotency-Key равен ID события, по нему получатель отбрасывает повторы at-least-once доставки
+type HTTPPublisher struct {
+	client pkg.HTTPDoer
+	url    string
+	secret string
+	cl     pkg.Clock
+}
+
+func NewHTTPPublisher(client pkg.HTTPDoer, url string, secret string, cl pkg.Clock) *HTTPPublisher {
+	return &HTTPPublisher{
+		client: client,
+		url:    url,
+		secret: secret,
+		cl:     cl,
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event domain.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+	if p.secret != "" {
+		ts := strconv.FormatInt(p.cl.Now().Unix(), 10)
+		req.Header.Set("X-Webhook-Signature", "t="+ts+",v1="+Sign(p.secret, ts, body))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
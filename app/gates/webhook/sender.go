@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"app/domain"
+	"app/iternal/pkg"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+/*Отправка вебхуков партнёрам. Тело запроса - JSON события, подпись в заголовке
+X-Webhook-Signature: t=<unix время>,v1=<hex(HMAC-SHA256(secret, "<t>.<тело>"))>
+время входит в подпись, чтобы получатель мог отбрасывать старые (переигранные) запросы
+*/
+
+type Sender struct {
+	client pkg.HTTPDoer
+	cl     pkg.Clock
+}
+
+func NewSender(client pkg.HTTPDoer, cl pkg.Clock) *Sender {
+	return &Sender{
+		client: client,
+		cl:     cl,
+	}
+}
+
+func (s *Sender) Send(ctx context.Context, d domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(s.cl.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Event-Id", d.EventID)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(int64(d.ID), 10))
+	req.Header.Set("X-Webhook-Signature", "t="+ts+",v1="+Sign(d.Secret, ts, d.Payload))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) //дочитываем тело, чтобы соединение переиспользовалось
+	return resp.StatusCode, nil
+}
+
+// Sign - подпись тела запроса, получатель должен посчитать её так же и сравнить
+func Sign(secret string, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
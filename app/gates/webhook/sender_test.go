@@ -0,0 +1,217 @@
+package webhook_test
+
+import (
+	"app/domain"
+	"app/gates/webhook"
+	"app/iternal/config"
+	"app/iternal/pkg"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memDeliveries - очередь доставок в памяти, остальные методы WebhookStore в тестах не вызываются
+type memDeliveries struct {
+	domain.WebhookStore
+	mu         sync.Mutex
+	deliveries map[domain.DeliveryID]*domain.WebhookDelivery
+	dead       []domain.DeliveryID
+}
+
+func newMemDeliveries(deliveries ...domain.WebhookDelivery) *memDeliveries {
+	m := &memDeliveries{deliveries: make(map[domain.DeliveryID]*domain.WebhookDelivery)}
+	for i := range deliveries {
+		m.deliveries[deliveries[i].ID] = &deliveries[i]
+	}
+	return m
+}
+
+func (m *memDeliveries) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []domain.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == domain.DeliveryPending && !d.NextAttemptAt.After(now) && len(claimed) < limit {
+			d.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, *d)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *memDeliveries) MarkDelivered(ctx context.Context, id domain.DeliveryID, statusCode int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.deliveries[id]
+	d.Status, d.Attempts, d.LastStatusCode, d.DeliveredAt = domain.DeliveryDelivered, d.Attempts+1, &statusCode, &at
+	return nil
+}
+
+func (m *memDeliveries) ScheduleRetry(ctx context.Context, id domain.DeliveryID, statusCode int, lastErr string, next time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.deliveries[id]
+	d.Attempts, d.LastStatusCode, d.LastError, d.NextAttemptAt = d.Attempts+1, &statusCode, &lastErr, next
+	return nil
+}
+
+func (m *memDeliveries) MarkDead(ctx context.Context, id domain.DeliveryID, statusCode int, lastErr string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.deliveries[id]
+	d.Status, d.Attempts, d.LastStatusCode, d.LastError = domain.DeliveryDead, d.Attempts+1, &statusCode, &lastErr
+	m.dead = append(m.dead, id)
+	return nil
+}
+
+func (m *memDeliveries) get(id domain.DeliveryID) domain.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.deliveries[id]
+}
+
+func testConfig() *config.Config {
+	return &config.Config{Webhooks: config.Webhooks{
+		Timeout:     time.Second,
+		MaxAttempts: 4,
+		BackoffBase: 5 * time.Second,
+		BackoffMax:  12 * time.Second,
+	}}
+}
+
+func discardLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestSenderSignsPayload(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"type":"task.completed"}`)
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := webhook.NewSender(receiver.Client(), pkg.StubClock{Time: now})
+	code, err := sender.Send(context.Background(), domain.WebhookDelivery{
+		ID: 7, EventID: "evt-1", EventType: "task.completed", Payload: payload, URL: receiver.URL, Secret: "s3cret",
+	})
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v, want 204", code, err)
+	}
+	if string(body) != string(payload) {
+		t.Fatalf("receiver got body %s, want %s", body, payload)
+	}
+	if got.Header.Get("X-Webhook-Event") != "task.completed" || got.Header.Get("X-Webhook-Event-Id") != "evt-1" || got.Header.Get("X-Webhook-Delivery") != "7" {
+		t.Fatalf("unexpected webhook headers: %v", got.Header)
+	}
+	//получатель проверяет подпись по своей копии секрета
+	ts, sig, ok := strings.Cut(got.Header.Get("X-Webhook-Signature"), ",v1=")
+	unix := strconv.FormatInt(now.Unix(), 10)
+	if !ok || ts != "t="+unix {
+		t.Fatalf("malformed signature header %q", got.Header.Get("X-Webhook-Signature"))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(unix + "." + string(body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Fatalf("signature %s, want %s", sig, want)
+	}
+}
+
+func TestWebhookRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cl := &pkg.StubClock{Time: start}
+	store := newMemDeliveries(domain.WebhookDelivery{ID: 1, Status: domain.DeliveryPending, NextAttemptAt: start, URL: receiver.URL, Secret: "s"})
+	service := domain.NewWebhookService(store, webhook.NewSender(receiver.Client(), cl), discardLog(), testConfig(), cl)
+
+	//задержки перед 2, 3 и 4 попыткой: base, 2*base и упор в backoff_max
+	for i, delay := range []time.Duration{5 * time.Second, 10 * time.Second, 12 * time.Second} {
+		if err := service.DeliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		d := store.get(1)
+		if d.Status != domain.DeliveryPending || d.Attempts != i+1 {
+			t.Fatalf("after attempt %d delivery is %s with %d attempts", i+1, d.Status, d.Attempts)
+		}
+		if want := cl.Now().Add(delay); !d.NextAttemptAt.Equal(want) {
+			t.Fatalf("after attempt %d next attempt at %v, want %v", i+1, d.NextAttemptAt, want)
+		}
+		//до срока повтора доставка не отправляется
+		if err := service.DeliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := requests.Load(); got != int32(i+1) {
+			t.Fatalf("receiver got %d requests before retry was due, want %d", got, i+1)
+		}
+		cl.Time = d.NextAttemptAt
+	}
+	if err := service.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d := store.get(1)
+	if d.Status != domain.DeliveryDead || d.Attempts != 4 || len(store.dead) != 1 {
+		t.Fatalf("after max attempts delivery is %s with %d attempts, dead letters %v", d.Status, d.Attempts, store.dead)
+	}
+	if d.LastStatusCode == nil || *d.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("last status code %v, want 500", d.LastStatusCode)
+	}
+	cl.Time = cl.Time.Add(time.Hour)
+	if err := service.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Fatalf("receiver got %d requests, dead delivery must not be retried", got)
+	}
+}
+
+func TestWebhookDeliveredAfterFailedAttempt(t *testing.T) {
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cl := &pkg.StubClock{Time: start}
+	store := newMemDeliveries(domain.WebhookDelivery{ID: 1, Status: domain.DeliveryPending, NextAttemptAt: start, URL: receiver.URL, Secret: "s"})
+	service := domain.NewWebhookService(store, webhook.NewSender(receiver.Client(), cl), discardLog(), testConfig(), cl)
+
+	if err := service.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cl.Time = store.get(1).NextAttemptAt
+	if err := service.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d := store.get(1)
+	if d.Status != domain.DeliveryDelivered || d.Attempts != 2 || d.DeliveredAt == nil || !d.DeliveredAt.Equal(cl.Time) {
+		t.Fatalf("delivery is %s with %d attempts, delivered at %v", d.Status, d.Attempts, d.DeliveredAt)
+	}
+}
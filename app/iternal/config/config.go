@@ -4,6 +4,7 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"log"
 	"os"
+	"time"
 )
 
 type DB struct {
@@ -23,12 +24,247 @@ type Log struct {
 	FilePath string `yaml:"logger_file_path"`
 }
 
+type LeaderboardCache struct {
+	Enabled        bool          `yaml:"enabled"`
+	ResyncInterval time.Duration `yaml:"resync_interval" env-default:"5m"` // как часто сверять кэш с бд
+}
+
+type Leaderboard struct {
+	Ranking  string           `yaml:"ranking" env-default:"competition"` // competition (1,2,2,4) или dense (1,2,2,3)
+	Timezone string           `yaml:"timezone" env-default:"UTC"`        // часовой пояс для границ дня/недели/месяца
+	Cache    LeaderboardCache `yaml:"cache"`
+}
+
+type Seasons struct {
+	Enabled       bool          `yaml:"enabled"`
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"1m"` // как часто проверять не закончился ли сезон
+	Prizes        []int         `yaml:"prizes"`                          // призовые очки по местам: первый элемент - за 1 место и т.д.
+}
+
+type Webhooks struct {
+	Enabled      bool          `yaml:"enabled"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"2s"` // как часто воркер ищет доставки для отправки
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`      // таймаут одного запроса к получателю
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`   // после стольких неудачных попыток доставка уходит в dead letters
+	BackoffBase  time.Duration `yaml:"backoff_base" env-default:"5s"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env-default:"1h"`
+}
+
+// Outbox - relay доменных событий из таблицы outbox получателям
+type Outbox struct {
+	Enabled      bool          `yaml:"enabled"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Publishers   []string      `yaml:"publishers"`  // log, webhooks, http
+	HTTPURL      string        `yaml:"http_url"`    // адрес для publisher http
+	HTTPSecret   string        `yaml:"http_secret"` // если задан, запросы подписываются как вебхуки
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"10"` // после стольких неудачных публикаций событие помечается failed
+	BackoffBase  time.Duration `yaml:"backoff_base" env-default:"1s"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env-default:"1h"`
+}
+
+// Verification - проверка выполнения заданий через внешние сервисы
+type Verification struct {
+	Timeout  time.Duration `yaml:"timeout" env-default:"10s"`
+	Telegram struct {
+		APIURL   string `yaml:"api_url" env-default:"https://api.telegram.org"`
+		BotToken string `yaml:"bot_token" env:"TELEGRAM_BOT_TOKEN"`
+	} `yaml:"telegram"`
+	Twitter struct {
+		APIURL      string `yaml:"api_url" env-default:"https://api.twitter.com"`
+		BearerToken string `yaml:"bearer_token" env:"TWITTER_BEARER_TOKEN"`
+	} `yaml:"twitter"`
+	Tasks map[string]TaskVerification `yaml:"tasks"` // ключ - задание из rewards, задания без записи засчитываются без проверки
+}
+
+type TaskVerification struct {
+	Verifier string `yaml:"verifier"` // self_reported, telegram, twitter
+	Target   string `yaml:"target"`   // канал в Telegram или аккаунт в X/Twitter
+}
+
+// Submissions - заявки с доказательством выполнения задания (задания с verifier: proof)
+type Submissions struct {
+	MaxProofSize int64  `yaml:"max_proof_size" env-default:"10485760"` // максимальный размер файла в байтах
+	Storage      string `yaml:"storage" env-default:"local"`           // local или s3
+	Dir          string `yaml:"dir" env-default:"../proofs"`           // каталог для storage: local
+	S3           struct {
+		Endpoint  string        `yaml:"endpoint"` // например https://s3.amazonaws.com или адрес minio
+		Region    string        `yaml:"region" env-default:"us-east-1"`
+		Bucket    string        `yaml:"bucket"`
+		AccessKey string        `yaml:"access_key" env:"S3_ACCESS_KEY"`
+		SecretKey string        `yaml:"secret_key" env:"S3_SECRET_KEY"`
+		Timeout   time.Duration `yaml:"timeout" env-default:"30s"` // на запрос к хранилищу вместе с передачей файла
+	} `yaml:"s3"`
+}
+
+// Idempotency - хранение ответов на запросы с заголовком Idempotency-Key
+type Idempotency struct {
+	TTL             time.Duration `yaml:"ttl" env-default:"24h"`             // сколько хранится ответ
+	LockTimeout     time.Duration `yaml:"lock_timeout" env-default:"1m"`     // через сколько незавершённый запрос считается упавшим
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"` // как часто удалять истёкшие ключи
+}
+
+// RateLimit - ограничение частоты запросов по группам эндпоинтов
+type RateLimit struct {
+	Enabled        bool                      `yaml:"enabled"`
+	TrustedProxies []string                  `yaml:"trusted_proxies"` // ip или подсети прокси, которым доверяем X-Forwarded-For
+	Groups         map[string]RateLimitGroup `yaml:"groups"`          // auth, tasks, api; группа без записи не ограничивается
+}
+
+type RateLimitGroup struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"` // сколько запросов можно сделать подряд, по умолчанию requests
+	Key      string        `yaml:"key"`   // ip или user
+}
+
+// Streaks - серии дней подряд для ежедневных заданий, отслеживаются только задания из Tasks
+type Streaks struct {
+	Tasks map[string]StreakTask `yaml:"tasks"`
+}
+
+type StreakTask struct {
+	Bonuses []StreakBonus `yaml:"bonuses"`
+}
+
+// StreakBonus - множитель очков за задание, начиная с серии Days дней
+type StreakBonus struct {
+	Days       int     `yaml:"days"`
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+// Achievement - достижение: правило, по которому оно выдаётся, и бонусные очки
+type Achievement struct {
+	Title       string          `yaml:"title"`
+	Description string          `yaml:"description"`
+	Rule        AchievementRule `yaml:"rule"`
+	Points      int             `yaml:"points"`
+}
+
+type AchievementRule struct {
+	Type   string `yaml:"type"`   // completions, score, streak, referrals, rank
+	Task   string `yaml:"task"`   // для completions и streak
+	Min    int    `yaml:"min"`    // порог для completions, score, streak, referrals
+	Period string `yaml:"period"` // для rank: all, day, week, month
+	Top    int    `yaml:"top"`    // для rank
+}
+
+// Levels - кривая прогрессии уровней по опыту (сумма всех заработанных очков)
+type Levels struct {
+	Curve    string      `yaml:"curve" env-default:"formula"` // table или formula
+	Table    []int64     `yaml:"table"`                       // table: опыт для уровней 2, 3, ...
+	Base     int64       `yaml:"base" env-default:"100"`      // formula: опыт для уровня 2
+	Growth   float64     `yaml:"growth" env-default:"1.5"`    // formula: во сколько раз каждый уровень дороже предыдущего
+	MaxLevel int         `yaml:"max_level" env-default:"50"`  // formula: максимальный уровень
+	Bonus    int         `yaml:"bonus"`                       // очки за каждый новый уровень
+	Bonuses  map[int]int `yaml:"bonuses"`                     // очки за отдельные уровни, вместо bonus
+}
+
+// Shop - магазин наград за очки, товары заводят администраторы через /admin/shop/items
+type Shop struct {
+	MaxQuantity int `yaml:"max_quantity" env-default:"10"` // максимум единиц товара в одном заказе
+}
+
+// Transfers - переводы очков между пользователями
+type Transfers struct {
+	Enabled       bool          `yaml:"enabled"`
+	MinAmount     int           `yaml:"min_amount" env-default:"1"`
+	DailyLimit    int           `yaml:"daily_limit"`     // сколько очков можно отправить за день (в часовом поясе отправителя), 0 - без ограничения
+	MinAccountAge time.Duration `yaml:"min_account_age"` // сколько должно пройти с регистрации отправителя
+	FeePercent    float64       `yaml:"fee_percent"`     // комиссия в процентах от суммы, округляется вверх и списывается сверх суммы
+}
+
+// PointsExpiration - сгорание очков через months месяцев после начисления
+type PointsExpiration struct {
+	Enabled       bool          `yaml:"enabled"`
+	Months        int           `yaml:"months" env-default:"12"`
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"1h"`
+	BatchSize     int           `yaml:"batch_size" env-default:"100"`     // сколько пользователей обрабатывать за проход
+	NotifyWithin  time.Duration `yaml:"notify_within" env-default:"720h"` // окно "скоро сгорят" по умолчанию
+}
+
+// BonusEvents - акции с множителем очков за задания, сами события заводят администраторы через /admin/bonus-events
+type BonusEvents struct {
+	Resolution string `yaml:"resolution" env-default:"max"` // пересекающиеся события: max, multiply или sum
+}
+
+// Teams - команды пользователей
+type Teams struct {
+	MaxSize int    `yaml:"max_size" env-default:"10"` // максимальный размер команды (владелец может задать меньше)
+	Score   string `yaml:"score" env-default:"sum"`   // очки команды: sum или average вкладов участников
+}
+
+// Quest - цепочка заданий, которые нужно выполнить (в течение одного дня для period: day), по порядку если strict
+type Quest struct {
+	Title  string   `yaml:"title"`
+	Steps  []string `yaml:"steps"`  // задания из rewards, для strict - в порядке выполнения
+	Period string   `yaml:"period"` // once - один раз, day - заново каждый день (в часовом поясе пользователя)
+	Strict bool     `yaml:"strict"` // шаг нельзя выполнить раньше предыдущих
+	Bonus  int      `yaml:"bonus"`  // очки за прохождение всей цепочки
+}
+
+// Challenges - вызовы между пользователями со ставкой очками
+type Challenges struct {
+	Enabled       bool          `yaml:"enabled"`
+	MinStake      int           `yaml:"min_stake" env-default:"1"`
+	MaxStake      int           `yaml:"max_stake"` // 0 - без ограничения
+	MaxDuration   time.Duration `yaml:"max_duration" env-default:"720h"`
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"1m"` // как часто подводить итоги вызовов с наступившим сроком
+	BatchSize     int           `yaml:"batch_size" env-default:"100"`    // сколько вызовов обрабатывать за проход
+}
+
+// Email - письма пользователям и подтверждение email
+type Email struct {
+	Mailer          string        `yaml:"mailer" env-default:"log"`    // log - в лог, file - файлами в dir, smtp - через smtp сервер
+	From            string        `yaml:"from"`                        // адрес отправителя
+	Dir             string        `yaml:"dir" env-default:"./mail"`    // куда складывать письма для mailer: file
+	SMTP            SMTP          `yaml:"smtp"`                        // для mailer: smtp
+	TokenTTL        time.Duration `yaml:"token_ttl" env-default:"24h"` // сколько действует ссылка подтверждения
+	VerifyURL       string        `yaml:"verify_url"`                  // страница подтверждения, токен добавляется параметром token
+	RequireVerified bool          `yaml:"require_verified"`            // очки за задания и приглашения начисляются только с подтверждённым email
+}
+
+type SMTP struct {
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port" env-default:"587"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password" env:"SMTP_PASSWORD"`
+	Timeout  time.Duration `yaml:"timeout" env-default:"10s"` // на соединение и весь диалог с сервером
+}
+
+type Admin struct {
+	UserIDs []int64 `yaml:"user_ids"` // id пользователей, которым доступны /admin эндпоинты
+	//общий секрет администраторов в заголовке X-Admin-Token, без него /admin эндпоинты закрыты для всех
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+}
+
 type Config struct {
-	Env     string         `yaml:"env"`
-	DB      DB             `yaml:"postgres_db"`
-	Rest    Rest           `yaml:"RestServer"`
-	Log     Log            `yaml:"logger"`
-	Rewards map[string]int `yaml:"rewards"` // Ключ — название награды, значение — очки
+	Env              string                 `yaml:"env"`
+	DB               DB                     `yaml:"postgres_db"`
+	Rest             Rest                   `yaml:"RestServer"`
+	Log              Log                    `yaml:"logger"`
+	Leaderboard      Leaderboard            `yaml:"leaderboard"`
+	Seasons          Seasons                `yaml:"seasons"`
+	Webhooks         Webhooks               `yaml:"webhooks"`
+	Outbox           Outbox                 `yaml:"outbox"`
+	Verification     Verification           `yaml:"verification"`
+	Submissions      Submissions            `yaml:"submissions"`
+	Idempotency      Idempotency            `yaml:"idempotency"`
+	RateLimit        RateLimit              `yaml:"rate_limit"`
+	Streaks          Streaks                `yaml:"streaks"`
+	Achievements     map[string]Achievement `yaml:"achievements"` // ключ - код достижения
+	Levels           Levels                 `yaml:"levels"`
+	Shop             Shop                   `yaml:"shop"`
+	Transfers        Transfers              `yaml:"transfers"`
+	PointsExpiration PointsExpiration       `yaml:"points_expiration"`
+	BonusEvents      BonusEvents            `yaml:"bonus_events"`
+	Teams            Teams                  `yaml:"teams"`
+	Quests           map[string]Quest       `yaml:"quests"` // ключ - код квеста
+	Challenges       Challenges             `yaml:"challenges"`
+	Email            Email                  `yaml:"email"`
+	Admin            Admin                  `yaml:"admin"`
+	Rewards          map[string]int         `yaml:"rewards"` // Ключ — название награды, значение — очки
 }
 
 func MustLoad() *Config {
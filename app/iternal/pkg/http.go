@@ -0,0 +1,8 @@
+package pkg
+
+import "net/http"
+
+// HTTPDoer - исходящий http клиент (*http.Client с таймаутом), общий для клиентов внешних сервисов
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"app/iternal/config"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	KeyIP   = "ip"
+	KeyUser = "user"
+)
+
+// Group - лимит группы эндпоинтов и чем различаются клиенты: ip или user (для анонимных запросов - ip)
+type Group struct {
+	Limit Limit
+	Key   string
+}
+
+// Limiter - лимиты групп эндпоинтов поверх Store и определение ip клиента
+type Limiter struct {
+	store   Store
+	groups  map[string]Group
+	trusted []netip.Prefix
+}
+
+// New - лимиты из конфига, trusted_proxies - ip или подсети прокси, которым доверяем X-Forwarded-For
+func New(store Store, cfg config.RateLimit) (*Limiter, error) {
+	l := &Limiter{store: store, groups: make(map[string]Group, len(cfg.Groups))}
+	for name, g := range cfg.Groups {
+		if g.Requests <= 0 || g.Per <= 0 {
+			return nil, fmt.Errorf("rate limit group %q: requests and per must be positive", name)
+		}
+		if g.Key != KeyIP && g.Key != KeyUser {
+			return nil, fmt.Errorf("rate limit group %q: key must be ip or user", name)
+		}
+		burst := g.Burst
+		if burst <= 0 {
+			burst = g.Requests
+		}
+		l.groups[name] = Group{Limit: Limit{Requests: g.Requests, Per: g.Per, Burst: burst}, Key: g.Key}
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		l.trusted = append(l.trusted, prefix.Masked())
+	}
+	return l, nil
+}
+
+// Group - лимит группы, false если для группы лимит не задан
+func (l *Limiter) Group(name string) (Group, bool) {
+	g, ok := l.groups[name]
+	return g, ok
+}
+
+func (l *Limiter) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	return l.store.Take(ctx, key, limit, now)
+}
+
+// ClientIP - адрес клиента. Если запрос пришёл от доверенного прокси, идём по X-Forwarded-For справа налево
+// до первого недоверенного или неразборчивого адреса: левые значения клиент может подставить сам.
+// Адреса IPv4 в виде IPv6 (::ffff:1.2.3.4) приводятся к IPv4, чтобы у клиента был один ключ
+func (l *Limiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if !l.isTrusted(addr) || len(forwarded) == 0 {
+		return addr.String()
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		raw := strings.TrimSpace(hops[i])
+		hop, err := netip.ParseAddr(raw)
+		if err != nil {
+			//мусор в заголовке - отдельный ключ, а не общая корзина с прокси
+			return raw
+		}
+		addr = hop.Unmap()
+		if !l.isTrusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func (l *Limiter) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
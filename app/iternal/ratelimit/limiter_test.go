@@ -0,0 +1,46 @@
+package ratelimit_test
+
+import (
+	"app/iternal/config"
+	"app/iternal/ratelimit"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.NewMemoryStore(), config.RateLimit{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted client can't spoof the header", "203.0.113.7:5000", []string{"1.1.1.1"}, "203.0.113.7"},
+		{"ipv4-mapped direct client", "[::ffff:203.0.113.7]:5000", nil, "203.0.113.7"},
+		{"trusted proxy without header", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"client behind proxy", "10.0.0.2:5000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"ipv4-mapped proxy", "[::ffff:10.0.0.2]:5000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"ipv4-mapped client behind proxy", "10.0.0.2:5000", []string{"::ffff:203.0.113.7"}, "203.0.113.7"},
+		{"spoofed left values are ignored", "10.0.0.2:5000", []string{"1.1.1.1, 203.0.113.7, 192.168.1.1"}, "203.0.113.7"},
+		{"several header lines", "10.0.0.2:5000", []string{"1.1.1.1", "203.0.113.7, 10.1.2.3"}, "203.0.113.7"},
+		{"only proxies", "10.0.0.2:5000", []string{"10.0.0.5, 192.168.1.1"}, "10.0.0.5"},
+		{"unparsable hop gets its own key", "10.0.0.2:5000", []string{"203.0.113.7, garbage"}, "garbage"},
+		{"ipv6 client", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := limiter.ClientIP(r); got != tt.want {
+				t.Fatalf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
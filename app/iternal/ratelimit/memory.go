@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - корзины в памяти процесса, при нескольких репликах лимит действует на каждую отдельно
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{}
+		m.buckets[key] = b
+	}
+	return b.take(limit, now), nil
+}
+
+// Cleanup удаляет корзины, к которым не обращались дольше idle - они уже полные и ничего не ограничивают
+func (m *MemoryStore) Cleanup(now time.Time, idle time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, b := range m.buckets {
+		if now.Sub(b.At) > idle {
+			delete(m.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunCleanup - Cleanup раз в interval, до отмены ctx
+func (m *MemoryStore) RunCleanup(ctx context.Context, interval time.Duration, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.Cleanup(now, idle)
+		}
+	}
+}
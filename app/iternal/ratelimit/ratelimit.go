@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+/*Ограничение частоты запросов алгоритмом token bucket: в корзине до Burst токенов, они восполняются
+со скоростью Requests за Per, каждый запрос забирает один токен. Store хранит корзины, в памяти
+(MemoryStore) для одной реплики или в общем хранилище для нескольких
+*/
+
+type Limit struct {
+	Requests int // сколько запросов восполняется за Per
+	Per      time.Duration
+	Burst    int // ёмкость корзины, сколько запросов можно сделать подряд
+}
+
+// rate - токенов в секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result - результат попытки взять токен, поля нужны для заголовков RateLimit-*
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // через сколько корзина снова будет полной
+	RetryAfter time.Duration // через сколько появится токен, если запрос не разрешён
+}
+
+type Store interface {
+	// Take забирает токен из корзины key
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	Tokens float64
+	At     time.Time // когда Tokens было посчитано
+}
+
+// take - восполнение токенов с момента b.At и попытка взять один
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	rate := limit.rate()
+	if b.At.IsZero() {
+		b.Tokens = float64(limit.Burst)
+	} else if elapsed := now.Sub(b.At).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*rate)
+	}
+	b.At = now
+	res := Result{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((float64(limit.Burst) - b.Tokens) / rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
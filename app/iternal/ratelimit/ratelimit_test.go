@@ -0,0 +1,66 @@
+package ratelimit_test
+
+import (
+	"app/iternal/ratelimit"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	//10 запросов в минуту - токен каждые 6 секунд, подряд не больше 3
+	limit := ratelimit.Limit{Requests: 10, Per: time.Minute, Burst: 3}
+	steps := []struct {
+		name       string
+		after      time.Duration // от начала
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{"first request takes from full bucket", 0, true, 2, 6 * time.Second, 0},
+		{"burst", 0, true, 1, 12 * time.Second, 0},
+		{"burst exhausted", 0, true, 0, 18 * time.Second, 0},
+		{"empty bucket", 0, false, 0, 18 * time.Second, 6 * time.Second},
+		{"partial refill is not enough", 3 * time.Second, false, 0, 15 * time.Second, 3 * time.Second},
+		{"one token refilled", 6 * time.Second, true, 0, 18 * time.Second, 0},
+		{"refill is capped by burst", time.Hour, true, 2, 6 * time.Second, 0},
+	}
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	for _, step := range steps {
+		res, err := store.Take(ctx, "user:1", limit, start.Add(step.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != step.allowed || res.Remaining != step.remaining || res.Limit != 3 {
+			t.Fatalf("%s: allowed=%v remaining=%d limit=%d, want allowed=%v remaining=%d limit=3",
+				step.name, res.Allowed, res.Remaining, res.Limit, step.allowed, step.remaining)
+		}
+		if !near(res.Reset, step.reset) || !near(res.RetryAfter, step.retryAfter) {
+			t.Fatalf("%s: reset=%s retry_after=%s, want %s and %s", step.name, res.Reset, res.RetryAfter, step.reset, step.retryAfter)
+		}
+	}
+	//у другого ключа своя корзина
+	if res, _ := store.Take(ctx, "user:2", limit, start); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("another key shares the bucket: %+v", res)
+	}
+}
+
+// near - длительности из float секунд сравниваются с точностью до миллисекунды
+func near(got, want time.Duration) bool {
+	d := got - want
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limit := ratelimit.Limit{Requests: 1, Per: time.Second, Burst: 1}
+	store := ratelimit.NewMemoryStore()
+	store.Take(context.Background(), "old", limit, start)
+	store.Take(context.Background(), "fresh", limit, start.Add(time.Hour))
+	if removed := store.Cleanup(start.Add(time.Hour+time.Minute), 30*time.Minute); removed != 1 {
+		t.Fatalf("Cleanup() removed %d buckets, want 1", removed)
+	}
+}
@@ -0,0 +1,153 @@
+package skiplist
+
+import "math/rand"
+
+/*Упорядоченный skip list со счётчиками ширины ссылок (indexable skip list).
+Кроме вставки/удаления за O(log n) умеет отвечать "сколько элементов меньше ключа"
+и "какой элемент стоит на позиции i" тоже за O(log n). Не потокобезопасен.
+*/
+
+const (
+	maxLevel = 32
+	p        = 0.25
+)
+
+type node[K any] struct {
+	key   K
+	next  []*node[K]
+	width []int // сколько элементов перепрыгивает ссылка next[i]
+}
+
+type SkipList[K any] struct {
+	head   *node[K]
+	level  int
+	length int
+	less   func(a, b K) bool
+	rnd    *rand.Rand
+}
+
+// New - пустой список, less задаёт порядок элементов, ключи должны быть уникальными относительно less
+func New[K any](less func(a, b K) bool) *SkipList[K] {
+	return &SkipList[K]{
+		head:  &node[K]{next: make([]*node[K], maxLevel), width: make([]int, maxLevel)},
+		level: 1,
+		less:  less,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+func (s *SkipList[K]) Len() int {
+	return s.length
+}
+
+func (s *SkipList[K]) randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && s.rnd.Float64() < p {
+		lvl++
+	}
+	return lvl
+}
+
+// Insert добавляет ключ, повторная вставка равного ключа ничего не делает
+func (s *SkipList[K]) Insert(key K) {
+	var update [maxLevel]*node[K]
+	var rank [maxLevel]int
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		if i < s.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i] != nil && s.less(x.next[i].key, key) {
+			rank[i] += x.width[i]
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	if n := x.next[0]; n != nil && !s.less(key, n.key) {
+		return
+	}
+	lvl := s.randomLevel()
+	if lvl > s.level {
+		for i := s.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = s.head
+			update[i].width[i] = s.length
+		}
+		s.level = lvl
+	}
+	n := &node[K]{key: key, next: make([]*node[K], lvl), width: make([]int, lvl)}
+	for i := 0; i < lvl; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+		n.width[i] = update[i].width[i] - (rank[0] - rank[i])
+		update[i].width[i] = rank[0] - rank[i] + 1
+	}
+	for i := lvl; i < s.level; i++ {
+		update[i].width[i]++
+	}
+	s.length++
+}
+
+// Delete удаляет ключ, возвращает false если ключа не было
+func (s *SkipList[K]) Delete(key K) bool {
+	var update [maxLevel]*node[K]
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.less(x.next[i].key, key) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	n := x.next[0]
+	if n == nil || s.less(key, n.key) {
+		return false
+	}
+	for i := 0; i < s.level; i++ {
+		if update[i].next[i] == n {
+			update[i].width[i] += n.width[i] - 1
+			update[i].next[i] = n.next[i]
+		} else {
+			update[i].width[i]--
+		}
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.length--
+	return true
+}
+
+// CountLess - сколько элементов строго меньше key (ключ может и не быть в списке)
+func (s *SkipList[K]) CountLess(key K) int {
+	count := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.less(x.next[i].key, key) {
+			count += x.width[i]
+			x = x.next[i]
+		}
+	}
+	return count
+}
+
+// Range - до n элементов начиная с позиции offset (с нуля)
+func (s *SkipList[K]) Range(offset int, n int) []K {
+	if offset < 0 || offset >= s.length || n <= 0 {
+		return nil
+	}
+	//спускаемся к элементу на позиции offset, позиции в ширинах считаются с единицы
+	target := offset + 1
+	traversed := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && traversed+x.width[i] <= target {
+			traversed += x.width[i]
+			x = x.next[i]
+		}
+	}
+	keys := make([]K, 0, min(n, s.length-offset))
+	for ; x != nil && len(keys) < n; x = x.next[0] {
+		keys = append(keys, x.key)
+	}
+	return keys
+}
@@ -0,0 +1,101 @@
+package skiplist
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// oracle - отсортированный срез, с которым сверяется skip list
+type oracle []int
+
+func (o oracle) insert(key int) oracle {
+	i, found := slices.BinarySearch(o, key)
+	if found {
+		return o
+	}
+	return slices.Insert(o, i, key)
+}
+
+func (o oracle) delete(key int) (oracle, bool) {
+	i, found := slices.BinarySearch(o, key)
+	if !found {
+		return o, false
+	}
+	return slices.Delete(o, i, i+1), true
+}
+
+func (o oracle) countLess(key int) int {
+	i, _ := slices.BinarySearch(o, key)
+	return i
+}
+
+func (o oracle) rng(offset int, n int) []int {
+	if offset < 0 || offset >= len(o) || n <= 0 {
+		return nil
+	}
+	return o[offset:min(offset+n, len(o))]
+}
+
+func intLess(a, b int) bool {
+	return a < b
+}
+
+func TestSkipListMatchesSortedSlice(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	list := New(intLess)
+	var want oracle
+	for step := 0; step < 20000; step++ {
+		key := rnd.Intn(2000)
+		switch op := rnd.Intn(10); {
+		case op < 6:
+			list.Insert(key)
+			want = want.insert(key)
+		default:
+			var deleted bool
+			want, deleted = want.delete(key)
+			if got := list.Delete(key); got != deleted {
+				t.Fatalf("step %d: Delete(%d) = %v, want %v", step, key, got, deleted)
+			}
+		}
+		if list.Len() != len(want) {
+			t.Fatalf("step %d: Len() = %d, want %d", step, list.Len(), len(want))
+		}
+		probe := rnd.Intn(2100) - 50
+		if got := list.CountLess(probe); got != want.countLess(probe) {
+			t.Fatalf("step %d: CountLess(%d) = %d, want %d", step, probe, got, want.countLess(probe))
+		}
+		offset, n := rnd.Intn(len(want)+2)-1, rnd.Intn(30)
+		if got := list.Range(offset, n); !slices.Equal(got, want.rng(offset, n)) {
+			t.Fatalf("step %d: Range(%d, %d) = %v, want %v", step, offset, n, got, want.rng(offset, n))
+		}
+	}
+	if got := list.Range(0, list.Len()); !slices.Equal(got, want) {
+		t.Fatalf("Range over whole list = %v, want %v", got, want)
+	}
+}
+
+func TestSkipListEmpty(t *testing.T) {
+	list := New(intLess)
+	if list.Delete(1) {
+		t.Fatal("Delete on empty list returned true")
+	}
+	if got := list.CountLess(1); got != 0 {
+		t.Fatalf("CountLess on empty list = %d, want 0", got)
+	}
+	if got := list.Range(0, 10); got != nil {
+		t.Fatalf("Range on empty list = %v, want nil", got)
+	}
+}
+
+func TestSkipListDuplicateInsert(t *testing.T) {
+	list := New(intLess)
+	list.Insert(5)
+	list.Insert(5)
+	if list.Len() != 1 {
+		t.Fatalf("Len() after duplicate insert = %d, want 1", list.Len())
+	}
+	if !list.Delete(5) || list.Len() != 0 {
+		t.Fatalf("Delete(5) did not remove the only key, Len() = %d", list.Len())
+	}
+}